@@ -0,0 +1,137 @@
+package main
+
+import (
+	"application_template/internal/app"
+	"application_template/internal/config"
+	"application_template/internal/database/migrations"
+	"application_template/internal/database/postgres"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const usage = `usage: migrate <command> [args]
+
+commands:
+  up [n]         apply all or the next n pending migrations
+  down [n]       roll back the last n migrations (default 1)
+  status         list migrations and when they were applied
+  create <name>  create an empty pair of up/down files
+  diff <name>    create a migration from the difference between the database and the models
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conf, err := config.Load()
+	if err != nil {
+		fmt.Printf("err config.Load() %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := run(conf, args[0], args[1:]); err != nil {
+		fmt.Printf("err migrate %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+}
+
+func run(conf *config.Config, command string, args []string) error {
+	ctx := context.Background()
+
+	if command == "create" {
+		if len(args) == 0 {
+			return fmt.Errorf("migration name is required")
+		}
+		up, down, err := migrations.New(nil, conf.DBMigrations).Create(args[0], "", "")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return nil
+	}
+
+	var n int
+	if command == "up" || command == "down" {
+		var err error
+		if n, err = steps(args); err != nil {
+			return err
+		}
+	}
+
+	db, err := postgres.Connect(conf.DB)
+	if err != nil {
+		return err
+	}
+	m := migrations.New(db, conf.DBMigrations)
+
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx, n)
+		for _, mig := range applied {
+			fmt.Printf("applied %d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "down":
+		reverted, err := m.Down(ctx, n)
+		for _, mig := range reverted {
+			fmt.Printf("reverted %d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	case "diff":
+		if len(args) == 0 {
+			return fmt.Errorf("migration name is required")
+		}
+		models := postgres.Models
+		for _, module := range app.Modules() {
+			models = append(models, module.Models()...)
+		}
+		up, down, err := m.CreateDiff(ctx, args[0], models)
+		if err != nil {
+			return err
+		}
+		if up == "" {
+			fmt.Println("schema is up to date")
+			return nil
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %s", command)
+}
+
+// steps разбирает необязательное число шагов up и down, 0 если оно не задано.
+func steps(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+	}
+	return n, nil
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bitbucket.org/microret/oxus/internal/server"
+	"application_template/internal/server"
 	"context"
 	"log"
 	"os/signal"
@@ -24,6 +24,8 @@ func main() {
 	s := server.Server{}
 	r, e := s.Init()
 	if e != nil {
+		log.Println("Server init failed: ", e)
+		s.CloseAll()
 		return
 	}
 
@@ -33,13 +35,14 @@ func main() {
 
 	stop()
 	log.Println("Shutting down gracefully, press Ctrl+C again to force")
-	s.CloseAll()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := s.Srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown: ", err)
+		log.Println("Server forced to shutdown: ", err)
 	}
 
+	s.CloseAll()
+
 	log.Println("Server exiting")
 }
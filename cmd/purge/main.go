@@ -0,0 +1,54 @@
+package main
+
+import (
+	"application_template/internal/app"
+	"application_template/internal/config"
+	"application_template/internal/database/postgres"
+	"application_template/internal/database/retention"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	archive := flag.String("a", "", "Directory the purged rows are archived to, RETENTION_ARCHIVE_DIR by default")
+	noArchive := flag.Bool("n", false, "Do not archive the purged rows")
+	batch := flag.Int("b", 0, "Number of rows deleted per transaction, RETENTION_BATCH_SIZE by default")
+	flag.Parse()
+
+	conf, err := config.Load()
+	if err != nil {
+		fmt.Printf("err config.Load() %s\n", err)
+		os.Exit(1)
+	}
+
+	if *archive == "" {
+		*archive = conf.RetentionArchiveDir
+	}
+	if *noArchive {
+		*archive = ""
+	}
+	if *batch == 0 {
+		*batch = conf.RetentionBatchSize
+	}
+
+	dbase, err := postgres.Connect(conf.DB)
+	if err != nil {
+		fmt.Printf("err db.Connect() %s\n", err)
+		os.Exit(1)
+	}
+
+	reports, err := retention.New(dbase, *archive, *batch).Run(context.Background(), app.Models(app.Modules()))
+	for _, r := range reports {
+		fmt.Printf("%s\tdeleted %d\tskipped %d", r.Table, r.Deleted, r.Skipped)
+		if r.Archive != "" && r.Deleted > 0 {
+			fmt.Printf("\tarchived to %s", r.Archive)
+		}
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("err retention.Run() %s\n", err)
+		os.Exit(1)
+	}
+}
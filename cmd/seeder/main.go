@@ -1,12 +1,14 @@
 package main
 
 import (
-	"bitbucket.org/microret/oxus/internal/config"
-	"bitbucket.org/microret/oxus/internal/db/connect"
-	postgres "bitbucket.org/microret/oxus/internal/db/init"
-	"bitbucket.org/microret/oxus/seeds"
+	"application_template/internal/app"
+	"application_template/internal/config"
+	"application_template/internal/database/connect"
+	"application_template/internal/database/postgres"
+	"application_template/seeds"
 	"flag"
 	"fmt"
+	"os"
 	"strings"
 )
 
@@ -25,20 +27,42 @@ func main() {
 	var sources flags
 
 	flag.Var(&sources, "s", "Specify the source for seeding")
+	force := flag.Bool("f", false, "Apply the seeds again even if they were already applied")
+	list := flag.Bool("l", false, "List the registered seeds")
 	flag.Parse()
 
+	modules := app.Modules()
+	app.RegisterSeeds(modules)
+
+	if *list {
+		for _, name := range seeds.Names() {
+			fmt.Println(name)
+		}
+		return
+	}
+
 	conf, err := config.Load()
 	if err != nil {
 		fmt.Printf("err config.Load() %s\n", err)
-		return
+		os.Exit(1)
 	}
 
-	dbase, err := postgres.Connect(conf.Db)
+	dbase, err := postgres.Connect(conf.DB)
 	if err != nil {
 		fmt.Printf("err db.Connect() %s\n", err)
-		return
+		os.Exit(1)
+	}
+	connect.PostgresDB = dbase
+
+	for _, m := range modules {
+		if err := m.Init(conf); err != nil {
+			fmt.Printf("err %s.Init() %s\n", m.Name(), err)
+			os.Exit(1)
+		}
 	}
-	connect.DB = dbase
 
-	seeds.RunSeeds(sources)
+	if err := seeds.RunSeeds(dbase, sources, *force); err != nil {
+		fmt.Printf("err seeds.RunSeeds() %s\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"application_template/internal/server"
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+)
+
+// Воркер выполняет фоновые воркеры и задачи cron модулей без обслуживания API, для
+// развёртываний с WORKER_EMBEDDED=false на экземплярах API.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	s := server.Server{}
+	if err := s.Setup(); err != nil {
+		log.Println("Worker init failed: ", err)
+		s.CloseAll()
+		return
+	}
+
+	s.Work()
+
+	<-ctx.Done()
+
+	stop()
+	log.Println("Draining workers, press Ctrl+C again to force")
+
+	s.CloseAll()
+
+	log.Println("Worker exiting")
+}
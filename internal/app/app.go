@@ -0,0 +1,102 @@
+package app
+
+import (
+	"application_template/internal/app/auth"
+	"application_template/internal/config"
+	"application_template/internal/scheduler"
+	"application_template/internal/worker"
+	"application_template/seeds"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Module - функциональный пакет из internal/app, подключающий свои модели и маршруты к серверу.
+type Module interface {
+	Name() string
+	Models() []interface{}
+	Init(conf *config.Config) error
+	Register(r *gin.RouterGroup)
+}
+
+// Closer реализуют модули, держащие ресурсы, которые нужно освободить при остановке.
+type Closer interface {
+	Close() error
+}
+
+// Seeder реализуют модули, поставляющие начальные данные.
+type Seeder interface {
+	Seeds() []seeds.Seed
+}
+
+// Worker реализуют модули, читающие очереди RabbitMQ; периодическая работа идёт через Scheduler.
+type Worker interface {
+	Workers() []worker.Worker
+}
+
+// Scheduler реализуют модули с задачами по расписанию cron.
+type Scheduler interface {
+	Jobs() []scheduler.Job
+}
+
+// Guard реализует модуль, авторизующий запросы, для маршрутов, которые сервер регистрирует
+// сам: Guard возвращает middleware, пропускающие вызывающих с правом на target.
+type Guard interface {
+	Guard(target string) []gin.HandlerFunc
+}
+
+// RegisterSeeds добавляет seeds всех модулей в реестр.
+func RegisterSeeds(modules []Module) {
+	for _, m := range modules {
+		if s, ok := m.(Seeder); ok {
+			seeds.Register(s.Seeds()...)
+		}
+	}
+}
+
+// Models возвращает модели всех модулей.
+func Models(modules []Module) []interface{} {
+	var models []interface{}
+	for _, m := range modules {
+		models = append(models, m.Models()...)
+	}
+	return models
+}
+
+// Workers возвращает воркеры всех модулей.
+func Workers(modules []Module) []worker.Worker {
+	var workers []worker.Worker
+	for _, m := range modules {
+		if w, ok := m.(Worker); ok {
+			workers = append(workers, w.Workers()...)
+		}
+	}
+	return workers
+}
+
+// Jobs возвращает задачи cron всех модулей.
+func Jobs(modules []Module) []scheduler.Job {
+	var jobs []scheduler.Job
+	for _, m := range modules {
+		if s, ok := m.(Scheduler); ok {
+			jobs = append(jobs, s.Jobs()...)
+		}
+	}
+	return jobs
+}
+
+// Guards возвращает middleware модуля, охраняющего target, и false, если такого модуля нет.
+func Guards(modules []Module, target string) ([]gin.HandlerFunc, bool) {
+	for _, m := range modules {
+		if g, ok := m.(Guard); ok {
+			return g.Guard(target), true
+		}
+	}
+	return nil, false
+}
+
+// Modules возвращает все модули приложения в порядке регистрации.
+func Modules() []Module {
+	return []Module{
+		auth.NewModule(),
+	}
+}
@@ -1,6 +1,28 @@
 package dto
 
+// RegistrationDTO — данные самостоятельной регистрации. Активность и роли пользователя
+// назначает сервер, а не клиент.
+type RegistrationDTO struct {
+	UserName string `json:"user" binding:"required,max=255"`
+	Password string `json:"password" binding:"required"`
+}
+
 type AuthorizationDTO struct {
-	UserName string `json:"user"`
-	Password string `json:"password"`
+	UserName string `json:"user" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type RefreshDTO struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenDTO struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ChangePasswordDTO меняет пароль пользователя из access токена.
+type ChangePasswordDTO struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,nefield=OldPassword"`
 }
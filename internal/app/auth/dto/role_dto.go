@@ -0,0 +1,7 @@
+package dto
+
+type PermissionDTO struct {
+	Type   uint   `json:"type" binding:"required,permission_type"`
+	Target string `json:"target" binding:"required,max=255"`
+	Value  uint   `json:"value" binding:"max=15"`
+}
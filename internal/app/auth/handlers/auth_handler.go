@@ -1,42 +1,113 @@
 package handlers
 
 import (
-	"application_template/internal/app/auth/models"
+	"application_template/internal/app/auth/dto"
+	"application_template/internal/app/auth/middlewares"
 	"application_template/internal/app/auth/services"
-	"encoding/json"
-	"log"
-	"net/http"
+	"application_template/internal/base/base_postgres"
+	"application_template/utils"
+	"errors"
+
+	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService  *services.AuthService
+	authenticate gin.HandlerFunc
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+// NewAuthHandler создаёт обработчик; authenticate защищает маршруты, которым нужен пользователь из токена.
+func NewAuthHandler(authService *services.AuthService, authenticate gin.HandlerFunc) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:  authService,
+		authenticate: authenticate,
 	}
 }
 
-func (h *AuthHandler) RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
-	var dto models.User
+func (h *AuthHandler) Register(r *gin.RouterGroup, s string) *gin.RouterGroup {
+	g := r.Group(s)
+	g.POST("registration", base_postgres.AppHandler(h.RegisterUserHandler).Handle)
+	g.POST("login", base_postgres.AppHandler(h.Login).Handle)
+	g.POST("refresh", base_postgres.AppHandler(h.Refresh).Handle)
+	g.POST("password", h.authenticate, base_postgres.AppHandler(h.ChangePassword).Handle)
+	return g
+}
+
+func (h *AuthHandler) RegisterUserHandler(ctx *gin.Context) *base_postgres.AppError {
+	var body dto.RegistrationDTO
 
 	// Разбор данных запроса
-	err := json.NewDecoder(r.Body).Decode(&dto)
-	if err != nil {
-		log.Println("Error decoding request body:", err)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
-		return
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return base_postgres.LocalizeError(ctx, err)
 	}
 
 	// Вызов сервиса для регистрации нового пользователя
-	err = h.authService.Registration(&dto)
+	user, err := h.authService.Registration(body)
 	if err != nil {
-		log.Println("Error registering user:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return base_postgres.LocalizeError(ctx, err)
+	}
+
+	// Отправка успешного ответа, хеш пароля скрыт от JSON
+	return base_postgres.Ok(ctx, user)
+}
+
+func (h *AuthHandler) Login(ctx *gin.Context) *base_postgres.AppError {
+	var body dto.AuthorizationDTO
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return base_postgres.LocalizeError(ctx, err)
+	}
+
+	tokens, err := h.authService.Authorisation(body)
+	if err != nil {
+		return authError(ctx, err)
+	}
+
+	return base_postgres.Ok(ctx, tokens)
+}
+
+func (h *AuthHandler) Refresh(ctx *gin.Context) *base_postgres.AppError {
+	var body dto.RefreshDTO
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return base_postgres.LocalizeError(ctx, err)
 	}
 
-	// Отправка успешного ответа
-	w.WriteHeader(http.StatusOK)
+	tokens, err := h.authService.Refresh(body)
+	if err != nil {
+		return authError(ctx, err)
+	}
+
+	return base_postgres.Ok(ctx, tokens)
+}
+
+func (h *AuthHandler) ChangePassword(ctx *gin.Context) *base_postgres.AppError {
+	var body dto.ChangePasswordDTO
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return base_postgres.LocalizeError(ctx, err)
+	}
+
+	claims := middlewares.GetClaims(ctx)
+	if err := h.authService.ChangePassword(claims.IdUser, body); err != nil {
+		return authError(ctx, err)
+	}
+
+	return base_postgres.Ok(ctx, gin.H{"Success": true})
+}
+
+// unauthorized — ошибки отказа в аутентификации.
+var unauthorized = map[string]bool{
+	"exception:invalid-credentials":  true,
+	"exception:user-inactive":        true,
+	"exception:invalid-token":        true,
+	"exception:token-expired":        true,
+	"exception:refresh-token-reused": true,
+}
+
+// authError отвечает 401 на отказ в аутентификации и стандартной ошибкой на всё остальное,
+// в том числе на нарушение политики паролей.
+func authError(ctx *gin.Context, err error) *base_postgres.AppError {
+	var localized *utils.LocalizeError
+	if errors.As(err, &localized) && unauthorized[localized.Message] {
+		return base_postgres.ErrUnauthorized(ctx, err)
+	}
+	return base_postgres.LocalizeError(ctx, err)
 }
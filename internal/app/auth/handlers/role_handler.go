@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"application_template/internal/app/auth/dto"
+	"application_template/internal/app/auth/models"
+	"application_template/internal/app/auth/services"
+	"application_template/internal/base/base_postgres"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RoleHandler struct {
+	*base_postgres.CrudController
+	roleService *services.RoleService
+}
+
+func NewRoleHandler(roleService *services.RoleService) *RoleHandler {
+	h := &RoleHandler{
+		CrudController: base_postgres.NewCrudController(),
+		roleService:    roleService,
+	}
+	h.CrudInterface = h
+	h.ModelInterface = h
+	return h
+}
+
+func (h *RoleHandler) Register(r *gin.RouterGroup, s string) *gin.RouterGroup {
+	g := h.CrudController.Register(r, s)
+	g.GET(":id/permissions", base_postgres.AppHandler(h.Permissions).Handle)
+	g.PUT(":id/permissions", base_postgres.AppHandler(h.ReplacePermissions).Handle)
+	return g
+}
+
+func (h *RoleHandler) GetAll() interface{} {
+	return &[]models.Role{}
+}
+
+func (h *RoleHandler) GetOne() base_postgres.HasId {
+	return &models.Role{}
+}
+
+func (h *RoleHandler) ScopeAll(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+func (h *RoleHandler) ScopeOne(db *gorm.DB) *gorm.DB {
+	return db.Preload("Permissions")
+}
+
+func (h *RoleHandler) Permissions(ctx *gin.Context) *base_postgres.AppError {
+	permissions, err := h.roleService.RolePermissions(base_postgres.ParamUint(ctx.Param("id")))
+	if err != nil {
+		return roleError(ctx, err)
+	}
+	return base_postgres.Ok(ctx, permissions)
+}
+
+// ReplacePermissions заменяет всю матрицу прав роли телом запроса.
+func (h *RoleHandler) ReplacePermissions(ctx *gin.Context) *base_postgres.AppError {
+	var body []dto.PermissionDTO
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return base_postgres.LocalizeError(ctx, err)
+	}
+
+	permissions, err := h.roleService.ReplacePermissions(base_postgres.ParamUint(ctx.Param("id")), body)
+	if err != nil {
+		return roleError(ctx, err)
+	}
+	return base_postgres.Ok(ctx, permissions)
+}
+
+type PermissionHandler struct {
+	*base_postgres.CrudController
+}
+
+func NewPermissionHandler() *PermissionHandler {
+	h := &PermissionHandler{
+		CrudController: base_postgres.NewCrudController(),
+	}
+	h.CrudInterface = h
+	h.ModelInterface = h
+	return h
+}
+
+func (h *PermissionHandler) GetAll() interface{} {
+	return &[]models.Permission{}
+}
+
+func (h *PermissionHandler) GetOne() base_postgres.HasId {
+	return &models.Permission{}
+}
+
+func (h *PermissionHandler) ScopeAll(db *gorm.DB) *gorm.DB {
+	return db.Preload("Role")
+}
+
+func (h *PermissionHandler) ScopeOne(db *gorm.DB) *gorm.DB {
+	return db.Preload("Role")
+}
+
+type UserRoleHandler struct {
+	roleService *services.RoleService
+	Middlewares []gin.HandlerFunc
+}
+
+func NewUserRoleHandler(roleService *services.RoleService) *UserRoleHandler {
+	return &UserRoleHandler{
+		roleService: roleService,
+	}
+}
+
+func (h *UserRoleHandler) Register(r *gin.RouterGroup, s string) *gin.RouterGroup {
+	g := r.Group(s, h.Middlewares...)
+	g.GET(":id/roles", base_postgres.AppHandler(h.Roles).Handle)
+	g.POST(":id/roles/:id_role", base_postgres.AppHandler(h.Assign).Handle)
+	g.DELETE(":id/roles/:id_role", base_postgres.AppHandler(h.Revoke).Handle)
+	g.GET(":id/permissions", base_postgres.AppHandler(h.Permissions).Handle)
+	return g
+}
+
+func (h *UserRoleHandler) Roles(ctx *gin.Context) *base_postgres.AppError {
+	roles, err := h.roleService.UserRoles(base_postgres.ParamUint(ctx.Param("id")))
+	if err != nil {
+		return roleError(ctx, err)
+	}
+	return base_postgres.Ok(ctx, roles)
+}
+
+func (h *UserRoleHandler) Assign(ctx *gin.Context) *base_postgres.AppError {
+	idUser := base_postgres.ParamUint(ctx.Param("id"))
+	if err := h.roleService.AssignRole(idUser, base_postgres.ParamUint(ctx.Param("id_role"))); err != nil {
+		return roleError(ctx, err)
+	}
+	return h.Roles(ctx)
+}
+
+func (h *UserRoleHandler) Revoke(ctx *gin.Context) *base_postgres.AppError {
+	idUser := base_postgres.ParamUint(ctx.Param("id"))
+	if err := h.roleService.RevokeRole(idUser, base_postgres.ParamUint(ctx.Param("id_role"))); err != nil {
+		return roleError(ctx, err)
+	}
+	return h.Roles(ctx)
+}
+
+// Permissions возвращает эффективные права пользователя по всем его ролям.
+func (h *UserRoleHandler) Permissions(ctx *gin.Context) *base_postgres.AppError {
+	permissions, err := h.roleService.UserPermissions(ctx, base_postgres.ParamUint(ctx.Param("id")))
+	if err != nil {
+		return roleError(ctx, err)
+	}
+	return base_postgres.Ok(ctx, permissions)
+}
+
+func roleError(ctx *gin.Context, err error) *base_postgres.AppError {
+	appErr := base_postgres.LocalizeError(ctx, err)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		appErr.Code = http.StatusNotFound
+	}
+	return appErr
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"application_template/internal/app/auth/repositories"
+	"application_template/internal/scheduler"
+	"context"
+	"log"
+	"time"
+)
+
+// Jobs возвращает задачи модуля auth по расписанию.
+func Jobs(authRepository *repositories.AuthRepository) []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:     "auth.expired-tokens",
+			Schedule: "@hourly",
+			// удаление сразу догоняет все пропущенные запуски
+			CatchUp: scheduler.CatchUpOnce,
+			Run: func(ctx context.Context) error {
+				return deleteExpiredTokens(ctx, authRepository)
+			},
+		},
+	}
+}
+
+// deleteExpiredTokens удаляет истёкшие refresh токены, иначе таблица растёт с каждым входом.
+func deleteExpiredTokens(ctx context.Context, authRepository *repositories.AuthRepository) error {
+	deleted, err := authRepository.DeleteExpiredTokens(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("auth: deleted %d expired refresh tokens\n", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"application_template/internal/app/auth/models"
+	"application_template/internal/app/auth/services"
+	"application_template/internal/base/base_postgres"
+	"application_template/internal/config"
+	"application_template/pkg/security"
+	"application_template/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const claimsKey = "claims"
+
+type AuthMiddleware struct {
+	conf        config.JWT
+	permissions *services.PermissionService
+}
+
+func NewAuthMiddleware(conf config.JWT, permissions *services.PermissionService) *AuthMiddleware {
+	return &AuthMiddleware{
+		conf:        conf,
+		permissions: permissions,
+	}
+}
+
+// Authenticate проверяет access токен из заголовка Authorization и сохраняет claims в контексте.
+func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
+	return base_postgres.AppHandler(func(ctx *gin.Context) *base_postgres.AppError {
+		header := ctx.GetHeader("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			return base_postgres.ErrUnauthorized(ctx, utils.NewLocalizeError(nil, "exception:missing-token", nil))
+		}
+
+		claims, err := security.ParseToken(m.conf.JWTSecret, token, security.AccessToken)
+		if err != nil {
+			return base_postgres.ErrUnauthorized(ctx, err)
+		}
+
+		ctx.Set(claimsKey, claims)
+		ctx.Set("id_user", claims.IdUser)
+		return nil
+	}).Guard
+}
+
+// Authorize проверяет право ролей вызывающего на цель; действие выводится из HTTP метода.
+func (m *AuthMiddleware) Authorize(target string) gin.HandlerFunc {
+	return m.authorize(target, func(ctx *gin.Context) models.Action {
+		return MethodAction(ctx.Request.Method)
+	})
+}
+
+// AuthorizeAction проверяет право ролей вызывающего на конкретное действие над целью.
+func (m *AuthMiddleware) AuthorizeAction(target string, action models.Action) gin.HandlerFunc {
+	return m.authorize(target, func(*gin.Context) models.Action {
+		return action
+	})
+}
+
+func (m *AuthMiddleware) authorize(target string, action func(ctx *gin.Context) models.Action) gin.HandlerFunc {
+	return base_postgres.AppHandler(func(ctx *gin.Context) *base_postgres.AppError {
+		claims := GetClaims(ctx)
+		if claims == nil {
+			return base_postgres.ErrUnauthorized(ctx, utils.NewLocalizeError(nil, "exception:missing-token", nil))
+		}
+
+		roles, err := m.permissions.UserRoles(ctx, claims.IdUser)
+		if err != nil {
+			return base_postgres.LocalizeError(ctx, err)
+		}
+
+		allowed, err := m.permissions.Allowed(ctx, roles, models.PermissionTypeRoute, target, action(ctx))
+		if err != nil {
+			return base_postgres.LocalizeError(ctx, err)
+		}
+		if !allowed {
+			appErr := base_postgres.ErrDenied(utils.NewLocalizeError(nil, "exception:access-denied", nil))
+			appErr.Message = utils.Localize(ctx, "exception:access-denied", map[string]interface{}{
+				"Target": target,
+			})
+			return appErr
+		}
+		return nil
+	}).Guard
+}
+
+func GetClaims(ctx *gin.Context) *security.Claims {
+	c, exists := ctx.Get(claimsKey)
+	if !exists {
+		return nil
+	}
+	claims, _ := c.(*security.Claims)
+	return claims
+}
+
+func MethodAction(method string) models.Action {
+	switch method {
+	case http.MethodPost:
+		return models.ActionCreate
+	case http.MethodPut, http.MethodPatch:
+		return models.ActionUpdate
+	case http.MethodDelete:
+		return models.ActionDelete
+	default:
+		return models.ActionRead
+	}
+}
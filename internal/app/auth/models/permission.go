@@ -1,12 +1,112 @@
 package models
 
-import "application_template/internal/base/base_postgres"
+import (
+	"application_template/internal/base/base_postgres"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Action - бит в Permission.Value; строка права разрешает все действия, чьи биты выставлены.
+type Action uint
+
+const (
+	ActionRead Action = 1 << iota
+	ActionCreate
+	ActionUpdate
+	ActionDelete
+)
+
+const ActionAll = ActionRead | ActionCreate | ActionUpdate | ActionDelete
+
+const (
+	PermissionTypeRoute uint = iota + 1
+)
+
+// TargetAll подходит под любую цель данного типа права.
+const TargetAll = "*"
 
 type Permission struct {
 	base_postgres.Entity
-	IdRole uint   `gorm:"index:idx_permission_unique,unique,where:deleted_at is null"`
-	Role   Role   `gorm:"foreignKey:IdRole"`
-	Type   uint   `gorm:"index:idx_permission_unique"`
-	Target string `gorm:"index:idx_permission_unique"`
-	Value  uint
+	IdRole uint   `gorm:"index:idx_permission_unique,unique,where:deleted_at is null" binding:"required"`
+	Role   Role   `gorm:"foreignKey:IdRole" binding:"-"`
+	Type   uint   `gorm:"index:idx_permission_unique" binding:"required,permission_type"`
+	Target string `gorm:"index:idx_permission_unique" binding:"required,max=255"`
+	Value  uint   `binding:"max=15"`
+
+	// storedRole - роль строки до обновления, её загружает BeforeUpdate.
+	storedRole uint
+}
+
+// SearchRelations позволяет искать права по их роли.
+func (Permission) SearchRelations() []string {
+	return []string{"Role"}
+}
+
+func (p *Permission) Allows(action Action) bool {
+	return Action(p.Value)&action == action
+}
+
+// BeforeUpdate запоминает сохранённую роль: частичное обновление оставляет IdRole пустым, а при
+// переносе права устаревает и кеш роли, из которой оно ушло.
+func (p *Permission) BeforeUpdate(tx *gorm.DB) error {
+	if p.ID == 0 {
+		return nil
+	}
+	var ids []uint
+	res := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Permission{}).
+		Where("id = ?", p.ID).
+		Pluck("id_role", &ids)
+	if res.Error != nil {
+		return res.Error
+	}
+	if len(ids) > 0 {
+		p.storedRole = ids[0]
+	}
+	return nil
+}
+
+func (p *Permission) AfterSave(tx *gorm.DB) error {
+	return invalidateRoleIds(tx, p.IdRole, p.storedRole)
+}
+
+func (p *Permission) AfterDelete(tx *gorm.DB) error {
+	return invalidateRoleIds(tx, p.IdRole)
+}
+
+func (Permission) Retention() time.Duration {
+	return 90 * 24 * time.Hour
+}
+
+// PermissionSet сопоставляет тип и цель права разрешённым действиям.
+type PermissionSet map[string]Action
+
+func PermissionKey(t uint, target string) string {
+	return strconv.FormatUint(uint64(t), 10) + ":" + target
+}
+
+func NewPermissionSet(permissions []Permission) PermissionSet {
+	set := PermissionSet{}
+	for _, p := range permissions {
+		key := PermissionKey(p.Type, p.Target)
+		set[key] |= Action(p.Value)
+	}
+	return set
+}
+
+func (s PermissionSet) Merge(other PermissionSet) {
+	for k, v := range other {
+		s[k] |= v
+	}
+}
+
+func (s PermissionSet) Allows(t uint, target string, action Action) bool {
+	granted := s[PermissionKey(t, target)] | s[PermissionKey(t, TargetAll)]
+	return granted&action == action
+}
+
+// UpsertConstraint позволяет импорту обновить значение существующей роли, типа и цели.
+func (Permission) UpsertConstraint() string {
+	return "cons_uniq"
 }
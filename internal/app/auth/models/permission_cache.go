@@ -0,0 +1,121 @@
+package models
+
+import (
+	"application_template/internal/base/base_postgres"
+	"application_template/internal/database/connect"
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const PermissionCacheTTL = time.Hour
+
+// RoleCache - закешированные ответы /roles. Роль отдаётся вместе с правами, поэтому изменение
+// прав сбрасывает и их; модуль задаёт его при инициализации.
+var RoleCache base_postgres.RedisInterface
+
+func PermissionCacheKey(role string) string {
+	return "permissions:role:" + role
+}
+
+// InvalidateRolePermissions сбрасывает закешированные наборы прав указанных ролей.
+func InvalidateRolePermissions(ctx context.Context, roles ...string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(roles))
+	for _, role := range roles {
+		keys = append(keys, PermissionCacheKey(role))
+	}
+	return connect.Cache.Delete(ctx, keys...)
+}
+
+func UserRolesCacheKey(idUser uint) string {
+	return "roles:user:" + strconv.FormatUint(uint64(idUser), 10)
+}
+
+// InvalidateUserRoles сбрасывает закешированные имена ролей указанных пользователей.
+func InvalidateUserRoles(ctx context.Context, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, UserRolesCacheKey(id))
+	}
+	return connect.Cache.Delete(ctx, keys...)
+}
+
+// invalidateRoleUsers сбрасывает закешированные имена ролей пользователей, у которых есть
+// указанные роли, после фиксации изменения.
+func invalidateRoleUsers(tx *gorm.DB, ids ...uint) error {
+	var users []uint
+	if res := tx.Session(&gorm.Session{NewDB: true}).Model(&UserRole{}).Where("id_role in ?", ids).Distinct().Pluck("id_user", &users); res.Error != nil {
+		return res.Error
+	}
+	afterCommitUserRoles(tx, users...)
+	return nil
+}
+
+// invalidateRoleIds сбрасывает закешированные наборы прав указанных ролей по их именам на момент
+// вызова, после фиксации изменения.
+func invalidateRoleIds(tx *gorm.DB, ids ...uint) error {
+	var names []string
+	if res := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Role{}).Where("id in ?", ids).Pluck("name", &names); res.Error != nil {
+		return res.Error
+	}
+	afterCommitRolePermissions(tx, names...)
+	afterCommitRoles(tx, ids...)
+	return nil
+}
+
+// afterCommitRolePermissions сбрасывает закешированные наборы прав ролей после коммита: при
+// сбросе раньше параллельная проверка могла бы снова закешировать старые строки.
+func afterCommitRolePermissions(tx *gorm.DB, roles ...string) {
+	if len(roles) == 0 {
+		return
+	}
+	base_postgres.AfterCommit(tx, func(ctx context.Context) {
+		if err := InvalidateRolePermissions(ctx, roles...); err != nil {
+			log.Printf("auth: failed to invalidate permissions of roles %v: %s\n", roles, err)
+		}
+	})
+}
+
+// afterCommitRoles сбрасывает закешированные ответы /roles с указанными ролями после коммита.
+func afterCommitRoles(tx *gorm.DB, ids ...uint) {
+	if RoleCache == nil {
+		return
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 {
+			keys = append(keys, strconv.FormatUint(uint64(id), 10))
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	base_postgres.AfterCommit(tx, func(ctx context.Context) {
+		if err := base_postgres.InvalidateCache(ctx, RoleCache, keys...); err != nil {
+			log.Printf("auth: failed to invalidate cached roles %v: %s\n", keys, err)
+		}
+	})
+}
+
+// afterCommitUserRoles сбрасывает закешированные имена ролей пользователей после коммита.
+func afterCommitUserRoles(tx *gorm.DB, ids ...uint) {
+	if len(ids) == 0 {
+		return
+	}
+	base_postgres.AfterCommit(tx, func(ctx context.Context) {
+		if err := InvalidateUserRoles(ctx, ids...); err != nil {
+			log.Printf("auth: failed to invalidate roles of users %v: %s\n", ids, err)
+		}
+	})
+}
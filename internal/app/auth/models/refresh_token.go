@@ -0,0 +1,19 @@
+package models
+
+import (
+	"application_template/internal/base/base_postgres"
+	"time"
+)
+
+type RefreshToken struct {
+	base_postgres.Entity
+	IdUser    uint   `gorm:"index"`
+	User      User   `gorm:"foreignKey:IdUser;constraint:OnDelete:CASCADE" binding:"-"`
+	Jti       string `gorm:"index:idx_refresh_token_jti,unique"`
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+func (t *RefreshToken) IsActive() bool {
+	return t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
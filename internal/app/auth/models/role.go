@@ -2,12 +2,56 @@ package models
 
 import (
 	"application_template/internal/base/base_postgres"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type Role struct {
 	base_postgres.Entity
-	Name  string `gorm:"index:idx_role_unique,unique,where:deleted_at is null"`
-	Users []User `gorm:"many2many:user_roles;"`
+	Name        string       `gorm:"index:idx_role_unique,unique,where:deleted_at is null" binding:"required,max=255"`
+	Users       []User       `gorm:"many2many:user_roles;joinForeignKey:IdRole;joinReferences:IdUser" json:",omitempty"`
+	Permissions []Permission `gorm:"foreignKey:IdRole" json:",omitempty"`
+}
+
+// BeforeUpdate сбрасывает кеш под сохранённым именем на случай переименования роли, а также
+// закешированные имена ролей её пользователей, после фиксации обновления.
+func (t *Role) BeforeUpdate(tx *gorm.DB) error {
+	if err := invalidateRoleIds(tx, t.ID); err != nil {
+		return err
+	}
+	return invalidateRoleUsers(tx, t.ID)
+}
+
+func (t *Role) AfterSave(tx *gorm.DB) error {
+	afterCommitRolePermissions(tx, t.Name)
+	return nil
+}
+
+func (t *Role) AfterDelete(tx *gorm.DB) error {
+	if err := invalidateRoleIds(tx, t.ID); err != nil {
+		return err
+	}
+	return invalidateRoleUsers(tx, t.ID)
+}
+
+// SearchRelations позволяет искать роли по их правам и пользователям.
+func (Role) SearchRelations() []string {
+	return []string{"Permissions", "Users"}
+}
+
+// EventType публикует изменения ролей как role.created, role.updated и role.deleted.
+func (Role) EventType() string {
+	return "role"
+}
+
+func (Role) EventVersion() int {
+	return 1
+}
+
+// Retention хранит удалённые роли квартал, чтобы их можно было восстановить.
+func (Role) Retention() time.Duration {
+	return 90 * 24 * time.Hour
 }
 
 //func (t *Role) BeforeCreate(tx *gorm.DB) error {
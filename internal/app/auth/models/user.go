@@ -2,28 +2,67 @@ package models
 
 import (
 	"application_template/internal/base/base_postgres"
-	"errors"
+	"application_template/pkg/security"
+	"application_template/utils"
 	"html"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type User struct {
 	base_postgres.Entity
-	UserName     string `gorm:"index:idx_user_unique,unique,where:deleted_at is null"`
-	UserPassword string
+	UserName     string `gorm:"index:idx_user_unique,unique,where:deleted_at is null" binding:"required,max=255"`
+	UserPassword string `json:"-"`
 	Active       bool
-	Roles        []Role `gorm:"many2many:user_roles;"`
+	Roles        []Role `gorm:"many2many:user_roles;joinForeignKey:IdUser;joinReferences:IdRole"`
+
+	// Password - новый пароль в открытом виде, при сохранении проверяется и хешируется в UserPassword.
+	Password string `gorm:"-" json:"-"`
+}
+
+// SearchRelations позволяет искать пользователей по их ролям.
+func (User) SearchRelations() []string {
+	return []string{"Roles"}
+}
+
+// BeforeSave хеширует новый пароль, если он задан. Сам UserPassword сохраняется как есть,
+// поэтому значение, лишь похожее на хеш, не обходит политику паролей.
+func (u *User) BeforeSave(*gorm.DB) error {
+	if u.Password == "" {
+		return nil
+	}
+	if err := u.SetPassword(u.Password); err != nil {
+		return err
+	}
+	u.Password = ""
+	return nil
 }
 
-//func (u *User) BeforeCreate(*gorm.DB) error {
-//	hashedPassword, err := security.Hash(u.UserPassword)
-//	if err != nil {
-//		return err
-//	}
-//	u.UserPassword = string(hashedPassword)
-//	return nil
-//}
+// SetPassword проверяет пароль по политике и сохраняет его хеш.
+func (u *User) SetPassword(password string) error {
+	if ContainsUserName(password, u.UserName) {
+		return utils.NewLocalizeError(nil, "exception:password-contains-user-name", nil)
+	}
+
+	hashed, err := security.HashValidPassword(password)
+	if err != nil {
+		return err
+	}
+	u.UserPassword = hashed
+	return nil
+}
+
+func ContainsUserName(password, userName string) bool {
+	userName = strings.TrimSpace(userName)
+	return userName != "" && strings.Contains(strings.ToLower(password), strings.ToLower(userName))
+}
+
+// Retention хранит удалённых пользователей год.
+func (User) Retention() time.Duration {
+	return 365 * 24 * time.Hour
+}
 
 func (u *User) Prepare() {
 	u.UserName = html.EscapeString(strings.TrimSpace(u.UserName))
@@ -31,20 +70,10 @@ func (u *User) Prepare() {
 	u.UpdatedAt = time.Now()
 }
 
-func (u *User) Validate(action string) map[string]string {
-	var errorMessages = make(map[string]string)
-	var err error
-
-	switch strings.ToLower(action) {
-	case "login":
-		if u.UserName == "" {
-			err = errors.New("required Username")
-			errorMessages["Required_username"] = err.Error()
-		}
-		if u.UserPassword == "" {
-			err = errors.New("required Password")
-			errorMessages["Required_password"] = err.Error()
-		}
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
 	}
-	return errorMessages
+	return names
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserRole struct {
+	IdUser    uint `gorm:"primaryKey"`
+	IdRole    uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// AfterCreate сбрасывает закешированные имена ролей пользователя после фиксации назначения.
+func (u *UserRole) AfterCreate(tx *gorm.DB) error {
+	afterCommitUserRoles(tx, u.IdUser)
+	return nil
+}
+
+// AfterDelete сбрасывает закешированные имена ролей пользователя после фиксации отзыва.
+func (u *UserRole) AfterDelete(tx *gorm.DB) error {
+	afterCommitUserRoles(tx, u.IdUser)
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"application_template/pkg/security"
+	"application_template/utils"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserBeforeSave(t *testing.T) {
+	prevPolicy := security.Policy()
+	security.Configure(security.PasswordPolicy{MinLength: 8, RequireDigit: true}, bcrypt.MinCost)
+	t.Cleanup(func() { security.Configure(prevPolicy, 0) })
+
+	hashed, err := security.Hash("password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		user     User
+		want     string
+		verifies string
+	}{
+		{"no new password keeps the stored one", User{UserName: "bob", UserPassword: string(hashed)}, "", "password1"},
+		{"new password is hashed", User{UserName: "bob", Password: "password2"}, "", "password2"},
+		{"hash as a new password is hashed again", User{UserName: "bob", Password: string(hashed)}, "", string(hashed)},
+		{"policy applies", User{UserName: "bob", Password: "password"}, "exception:password-requires-digit", ""},
+		{"user name in password", User{UserName: "Bob", Password: "xbob12345"}, "exception:password-contains-user-name", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := tt.user
+			err := u.BeforeSave(nil)
+			if tt.want != "" {
+				var localized *utils.LocalizeError
+				if !errors.As(err, &localized) || localized.Message != tt.want {
+					t.Fatalf("err = %v, want %s", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if u.Password != "" {
+				t.Error("the plain password is kept after save")
+			}
+			if err := security.VerifyPassword(u.UserPassword, tt.verifies); err != nil {
+				t.Errorf("stored password does not verify: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"application_template/internal/app/auth/handlers"
+	"application_template/internal/app/auth/jobs"
+	"application_template/internal/app/auth/middlewares"
+	"application_template/internal/app/auth/models"
+	"application_template/internal/app/auth/repositories"
+	"application_template/internal/app/auth/seeders"
+	"application_template/internal/app/auth/services"
+	"application_template/internal/app/auth/validators"
+	"application_template/internal/base/base_postgres"
+	"application_template/internal/config"
+	"application_template/internal/database/connect"
+	"application_template/internal/scheduler"
+	"application_template/pkg/security"
+	"application_template/seeds"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Module struct {
+	Middleware *middlewares.AuthMiddleware
+
+	authHandler       *handlers.AuthHandler
+	roleHandler       *handlers.RoleHandler
+	permissionHandler *handlers.PermissionHandler
+	userRoleHandler   *handlers.UserRoleHandler
+
+	authRepository *repositories.AuthRepository
+}
+
+func NewModule() *Module {
+	return &Module{}
+}
+
+func (m *Module) Name() string {
+	return "auth"
+}
+
+func (m *Module) Models() []interface{} {
+	return []interface{}{
+		&models.User{},
+		&models.Role{},
+		&models.Permission{},
+		&models.UserRole{},
+		&models.RefreshToken{},
+	}
+}
+
+func (m *Module) Seeds() []seeds.Seed {
+	return seeders.Seeds()
+}
+
+func (m *Module) Init(conf *config.Config) error {
+	security.Configure(security.PasswordPolicy{
+		MinLength:      conf.PasswordMinLength,
+		RequireUpper:   conf.PasswordRequireUpper,
+		RequireLower:   conf.PasswordRequireLower,
+		RequireDigit:   conf.PasswordRequireDigit,
+		RequireSpecial: conf.PasswordRequireSpecial,
+	}, conf.PasswordHashCost)
+
+	if err := validators.Register(base_postgres.Validator()); err != nil {
+		return err
+	}
+
+	db := connect.PostgresDB
+	if err := db.SetupJoinTable(&models.User{}, "Roles", &models.UserRole{}); err != nil {
+		return err
+	}
+	if err := db.SetupJoinTable(&models.Role{}, "Users", &models.UserRole{}); err != nil {
+		return err
+	}
+
+	permissionService := services.NewPermissionService(repositories.NewPermissionRepository(db))
+	roleService := services.NewRoleService(repositories.NewRoleRepository(db), permissionService)
+
+	m.Middleware = middlewares.NewAuthMiddleware(conf.JWT, permissionService)
+	m.authRepository = repositories.NewAuthRepository(db, conf.JWT)
+	m.authHandler = handlers.NewAuthHandler(services.NewAuthService(*m.authRepository), m.Middleware.Authenticate())
+	m.roleHandler = handlers.NewRoleHandler(roleService)
+	models.RoleCache = m.roleHandler
+	m.permissionHandler = handlers.NewPermissionHandler()
+	m.userRoleHandler = handlers.NewUserRoleHandler(roleService)
+
+	return nil
+}
+
+func (m *Module) Jobs() []scheduler.Job {
+	return jobs.Jobs(m.authRepository)
+}
+
+// Guard пропускает аутентифицированных пользователей с правом на target.
+func (m *Module) Guard(target string) []gin.HandlerFunc {
+	return []gin.HandlerFunc{m.Middleware.Authenticate(), m.Middleware.Authorize(target)}
+}
+
+func (m *Module) Register(r *gin.RouterGroup) {
+	m.authHandler.Register(r, "auth")
+
+	api := r.Group("", m.Middleware.Authenticate())
+
+	m.roleHandler.Middlewares = []gin.HandlerFunc{m.Middleware.Authorize("roles")}
+	m.roleHandler.Register(api, "roles")
+
+	m.permissionHandler.Middlewares = []gin.HandlerFunc{m.Middleware.Authorize("permissions")}
+	m.permissionHandler.Register(api, "permissions")
+
+	m.userRoleHandler.Middlewares = []gin.HandlerFunc{m.Middleware.Authorize("user_roles")}
+	m.userRoleHandler.Register(api, "users")
+}
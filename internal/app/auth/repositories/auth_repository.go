@@ -3,36 +3,219 @@ package repositories
 import (
 	"application_template/internal/app/auth/dto"
 	"application_template/internal/app/auth/models"
+	"application_template/internal/config"
+	"application_template/pkg/security"
+	"application_template/utils"
+	"context"
+	"errors"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthRepositoryInterface interface {
 	Registration(user *models.User) error
 	Authorisation(auth dto.AuthorizationDTO) (access, refresh string, err error)
+	Refresh(token string) (access, refresh string, err error)
+	ChangePassword(idUser uint, change dto.ChangePasswordDTO) error
 }
 
 type AuthRepository struct {
-	db *gorm.DB
+	db   *gorm.DB
+	conf config.JWT
 }
 
-func NewAuthRepository(db *gorm.DB) *AuthRepository {
+func NewAuthRepository(db *gorm.DB, conf config.JWT) *AuthRepository {
 	return &AuthRepository{
-		db: db,
+		db:   db,
+		conf: conf,
 	}
 }
 
+// Registration создаёт пользователя без связей: роли назначаются только через RoleService.
 func (r *AuthRepository) Registration(user *models.User) error {
-	return r.db.Create(user).Error
+	return r.db.Omit(clause.Associations).Create(user).Error
 }
 
 func (r *AuthRepository) Authorisation(auth dto.AuthorizationDTO) (access, refresh string, err error) {
-	//var user models.User
-	//err = r.db.Where("email =?", auth.Email).First(&user).Error
-	//if err != nil {
-	//	return "", "", err
-	//}
-	//
-	//access, refresh, err = r.db.Model(&user).Association("Access").Get(), r.db.Model(&user).Association("Refresh").Get(), nil
-	//return access, refresh, err
-	return "", "", nil
+	var user models.User
+	err = r.db.Preload("Roles").Where("user_name = ?", auth.UserName).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Проверка пароля против фиктивного хеша уравнивает время ответа для
+		// несуществующего пользователя и неверного пароля.
+		security.CompareDummy(auth.Password)
+		return "", "", utils.NewLocalizeError(err, "exception:invalid-credentials", nil)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if err = security.VerifyPassword(user.UserPassword, auth.Password); err != nil {
+		if errors.Is(err, security.ErrPasswordMismatch) {
+			return "", "", utils.NewLocalizeError(err, "exception:invalid-credentials", nil)
+		}
+		return "", "", err
+	}
+
+	if !user.Active {
+		return "", "", utils.NewLocalizeError(nil, "exception:user-inactive", nil)
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if security.NeedsRehash(user.UserPassword) {
+			if err := r.rehash(tx, &user, auth.Password); err != nil {
+				return err
+			}
+		}
+
+		access, refresh, err = r.issue(tx, &user)
+		return err
+	})
+	return access, refresh, err
+}
+
+// Refresh обменивает refresh токен на новую пару токенов. Старый refresh токен
+// отзывается, а повторное предъявление отозванного токена отзывает все сессии пользователя.
+func (r *AuthRepository) Refresh(token string) (access, refresh string, err error) {
+	claims, err := security.ParseToken(r.conf.JWTSecret, token, security.RefreshToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	var reused bool
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("jti = ? and id_user = ?", claims.ID, claims.IdUser).
+			First(&stored)
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return utils.NewLocalizeError(res.Error, "exception:invalid-token", nil)
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if stored.RevokedAt != nil {
+			reused = true
+			return nil
+		}
+		if !stored.IsActive() {
+			return utils.NewLocalizeError(nil, "exception:token-expired", nil)
+		}
+
+		var user models.User
+		if res := tx.Preload("Roles").First(&user, stored.IdUser); res.Error != nil {
+			return res.Error
+		}
+		if !user.Active {
+			return utils.NewLocalizeError(nil, "exception:user-inactive", nil)
+		}
+
+		if res := tx.Model(&stored).Update("revoked_at", time.Now()); res.Error != nil {
+			return res.Error
+		}
+
+		access, refresh, err = r.issue(tx, &user)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if reused {
+		if err := r.RevokeAll(claims.IdUser); err != nil {
+			return "", "", err
+		}
+		return "", "", utils.NewLocalizeError(nil, "exception:refresh-token-reused", nil)
+	}
+
+	return access, refresh, nil
+}
+
+// ChangePassword проверяет текущий пароль пользователя, сохраняет новый и отзывает все refresh токены.
+func (r *AuthRepository) ChangePassword(idUser uint, change dto.ChangePasswordDTO) error {
+	var user models.User
+	err := r.db.First(&user, idUser).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return utils.NewLocalizeError(err, "exception:invalid-token", nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = security.VerifyPassword(user.UserPassword, change.OldPassword); err != nil {
+		if errors.Is(err, security.ErrPasswordMismatch) {
+			return utils.NewLocalizeError(err, "exception:invalid-credentials", nil)
+		}
+		return err
+	}
+
+	if err = user.SetPassword(change.NewPassword); err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if res := tx.Model(&user).UpdateColumn("user_password", user.UserPassword); res.Error != nil {
+			return res.Error
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("id_user = ? and revoked_at is null", user.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+func (r *AuthRepository) RevokeAll(idUser uint) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("id_user = ? and revoked_at is null", idUser).
+		Update("revoked_at", time.Now()).Error
+}
+
+// DeleteExpiredTokens удаляет refresh токены, истёкшие до before. Отозванные токены
+// хранятся до истечения, чтобы распознавать их повторное предъявление.
+func (r *AuthRepository) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Unscoped().
+		Where("expires_at < ?", before).
+		Delete(&models.RefreshToken{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *AuthRepository) issue(tx *gorm.DB, user *models.User) (access, refresh string, err error) {
+	roles := user.RoleNames()
+
+	access, _, err = security.NewToken(r.conf.JWTSecret, seconds(r.conf.JWTExpiration), security.AccessToken, user.ID, roles)
+	if err != nil {
+		return "", "", err
+	}
+
+	refresh, claims, err := security.NewToken(r.conf.JWTSecret, seconds(r.conf.JWTRefreshExpiration), security.RefreshToken, user.ID, roles)
+	if err != nil {
+		return "", "", err
+	}
+
+	stored := &models.RefreshToken{
+		IdUser:    user.ID,
+		Jti:       claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if res := tx.Create(stored); res.Error != nil {
+		return "", "", res.Error
+	}
+
+	return access, refresh, nil
+}
+
+// rehash переводит пароль на текущий алгоритм и стоимость без проверки политики,
+// чтобы старые пароли продолжали работать после её ужесточения.
+func (r *AuthRepository) rehash(tx *gorm.DB, user *models.User, password string) error {
+	hashed, err := security.Hash(password)
+	if err != nil {
+		return err
+	}
+	user.UserPassword = string(hashed)
+	return tx.Model(user).UpdateColumn("user_password", user.UserPassword).Error
+}
+
+func seconds(s int) time.Duration {
+	return time.Duration(s) * time.Second
 }
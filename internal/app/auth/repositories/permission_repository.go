@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"application_template/internal/app/auth/models"
+	"gorm.io/gorm"
+)
+
+type PermissionRepositoryInterface interface {
+	FindByRoleName(role string) ([]models.Permission, error)
+	FindUserRoleNames(idUser uint) ([]string, error)
+}
+
+type PermissionRepository struct {
+	db *gorm.DB
+}
+
+func NewPermissionRepository(db *gorm.DB) *PermissionRepository {
+	return &PermissionRepository{
+		db: db,
+	}
+}
+
+func (r *PermissionRepository) FindByRoleName(role string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	res := r.db.Joins("join roles on roles.id = permissions.id_role and roles.deleted_at is null").
+		Where("roles.name = ?", role).
+		Find(&permissions)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return permissions, nil
+}
+
+// FindUserRoleNames возвращает имена действующих ролей пользователя.
+func (r *PermissionRepository) FindUserRoleNames(idUser uint) ([]string, error) {
+	names := []string{}
+	res := r.db.Model(&models.Role{}).
+		Joins("join user_roles on user_roles.id_role = roles.id").
+		Where("user_roles.id_user = ?", idUser).
+		Order("roles.name").
+		Pluck("roles.name", &names)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return names, nil
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"application_template/internal/app/auth/dto"
+	"application_template/internal/app/auth/models"
+	"application_template/internal/base/base_postgres"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepositoryInterface interface {
+	FindUser(idUser uint) (*models.User, error)
+	FindRole(idRole uint) (*models.Role, error)
+	AssignRole(idUser, idRole uint) error
+	RevokeRole(idUser, idRole uint) error
+	ReplacePermissions(idRole uint, permissions []dto.PermissionDTO) ([]models.Permission, error)
+}
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{
+		db: db,
+	}
+}
+
+func (r *RoleRepository) FindUser(idUser uint) (*models.User, error) {
+	var user models.User
+	if res := r.db.Preload("Roles").First(&user, idUser); res.Error != nil {
+		return nil, res.Error
+	}
+	return &user, nil
+}
+
+func (r *RoleRepository) FindRole(idRole uint) (*models.Role, error) {
+	var role models.Role
+	if res := r.db.Preload("Permissions").First(&role, idRole); res.Error != nil {
+		return nil, res.Error
+	}
+	return &role, nil
+}
+
+func (r *RoleRepository) AssignRole(idUser, idRole uint) error {
+	userRole := &models.UserRole{IdUser: idUser, IdRole: idRole}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(userRole).Error
+}
+
+func (r *RoleRepository) RevokeRole(idUser, idRole uint) error {
+	return r.db.Where("id_user = ? and id_role = ?", idUser, idRole).
+		Delete(&models.UserRole{IdUser: idUser, IdRole: idRole}).Error
+}
+
+// ReplacePermissions заменяет матрицу прав роли целиком. Старые строки удаляются физически,
+// потому что cons_uniq распространяется и на мягко удалённые записи.
+func (r *RoleRepository) ReplacePermissions(idRole uint, permissions []dto.PermissionDTO) ([]models.Permission, error) {
+	rows := make([]models.Permission, 0, len(permissions))
+	for _, p := range permissions {
+		rows = append(rows, models.Permission{
+			IdRole: idRole,
+			Type:   p.Type,
+			Target: p.Target,
+			Value:  p.Value,
+		})
+	}
+
+	err := base_postgres.Transaction(r.db, func(tx *gorm.DB) error {
+		// IdRole у удаляемой модели сбрасывает кеш роли даже при пустой новой матрице
+		if res := tx.Unscoped().Where("id_role = ?", idRole).Delete(&models.Permission{IdRole: idRole}); res.Error != nil {
+			return res.Error
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Omit("Role").Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,111 @@
+package seeders
+
+import (
+	"application_template/internal/app/auth/models"
+	"application_template/internal/base/base_postgres"
+	"application_template/internal/config"
+	"application_template/pkg/security"
+	"application_template/seeds"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const AdminRole = "admin"
+
+// shippedAdminPassword - пароль администратора, который раньше поставлялся в .env. Он всем известен,
+// поэтому администратора с ним не создаём.
+const shippedAdminPassword = "Admin12345"
+
+const (
+	SeedAdminRole        = "auth.admin-role"
+	SeedAdminPermissions = "auth.admin-permissions"
+	SeedAdminUser        = "auth.admin-user"
+)
+
+func Seeds() []seeds.Seed {
+	return []seeds.Seed{
+		{
+			Name: SeedAdminRole,
+			Run:  seedAdminRole,
+		},
+		{
+			Name:      SeedAdminPermissions,
+			DependsOn: []string{SeedAdminRole},
+			Run:       seedAdminPermissions,
+		},
+		{
+			Name:      SeedAdminUser,
+			DependsOn: []string{SeedAdminRole},
+			Run:       seedAdminUser,
+		},
+	}
+}
+
+func adminRole(tx *gorm.DB) (*models.Role, error) {
+	var role models.Role
+	if res := tx.Where(models.Role{Name: AdminRole}).FirstOrCreate(&role); res.Error != nil {
+		return nil, res.Error
+	}
+	return &role, nil
+}
+
+func seedAdminRole(tx *gorm.DB) error {
+	_, err := adminRole(tx)
+	return err
+}
+
+// seedAdminPermissions даёт роли admin все действия на всех маршрутах.
+func seedAdminPermissions(tx *gorm.DB) error {
+	role, err := adminRole(tx)
+	if err != nil {
+		return err
+	}
+
+	permissions := []models.Permission{
+		{
+			IdRole: role.ID,
+			Type:   models.PermissionTypeRoute,
+			Target: models.TargetAll,
+			Value:  uint(models.ActionAll),
+		},
+	}
+
+	repo := base_postgres.NewWithDB(tx.Omit("Role"))
+	return repo.CreateOrUpdate(base_postgres.NoScope, &permissions, "cons_uniq", []string{"value", "updated_at"})
+}
+
+// seedAdminUser создаёт администратора с ADMIN_PASSWORD, который должен быть задан, отличаться
+// от поставлявшегося по умолчанию и проходить политику паролей.
+func seedAdminUser(tx *gorm.DB) error {
+	conf := config.Get()
+	switch conf.AdminPassword {
+	case "":
+		return errors.New("ADMIN_PASSWORD is not set")
+	case shippedAdminPassword:
+		return errors.New("ADMIN_PASSWORD is the publicly known default, set another one")
+	}
+
+	role, err := adminRole(tx)
+	if err != nil {
+		return err
+	}
+
+	hashed, err := security.HashValidPassword(conf.AdminPassword)
+	if err != nil {
+		return fmt.Errorf("ADMIN_PASSWORD does not meet the password policy: %w", err)
+	}
+
+	var user models.User
+	res := tx.Where(models.User{UserName: conf.AdminUserName}).
+		Attrs(models.User{UserPassword: hashed, Active: true}).
+		FirstOrCreate(&user)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	userRole := &models.UserRole{IdUser: user.ID, IdRole: role.ID}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(userRole).Error
+}
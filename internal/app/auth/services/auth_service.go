@@ -1,6 +1,7 @@
 package services
 
 import (
+	"application_template/internal/app/auth/dto"
 	"application_template/internal/app/auth/models"
 	"application_template/internal/app/auth/repositories"
 )
@@ -15,9 +16,38 @@ func NewAuthService(authRepo repositories.AuthRepository) *AuthService {
 	}
 }
 
-// Registration регистрирует нового пользователя.
-func (s *AuthService) Registration(user *models.User) error {
-	// Валидация данных
-	// бизнес логика
-	return s.authRepo.Registration(user)
+// Registration регистрирует нового активного пользователя без ролей.
+func (s *AuthService) Registration(body dto.RegistrationDTO) (*models.User, error) {
+	user := &models.User{
+		UserName: body.UserName,
+		Password: body.Password,
+		Active:   true,
+	}
+	if err := s.authRepo.Registration(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Authorisation проверяет учётные данные и выдаёт пару access/refresh токенов.
+func (s *AuthService) Authorisation(auth dto.AuthorizationDTO) (*dto.TokenDTO, error) {
+	access, refresh, err := s.authRepo.Authorisation(auth)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TokenDTO{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+// Refresh ротирует refresh токен и выдаёт новую пару токенов.
+func (s *AuthService) Refresh(refresh dto.RefreshDTO) (*dto.TokenDTO, error) {
+	access, rotated, err := s.authRepo.Refresh(refresh.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TokenDTO{AccessToken: access, RefreshToken: rotated}, nil
+}
+
+// ChangePassword меняет пароль пользователя после проверки текущего.
+func (s *AuthService) ChangePassword(idUser uint, change dto.ChangePasswordDTO) error {
+	return s.authRepo.ChangePassword(idUser, change)
 }
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"application_template/internal/app/auth/models"
+	"application_template/internal/app/auth/repositories"
+	"application_template/internal/database/connect"
+	"context"
+	"encoding/json"
+)
+
+type PermissionService struct {
+	permissionRepo repositories.PermissionRepositoryInterface
+}
+
+func NewPermissionService(permissionRepo repositories.PermissionRepositoryInterface) *PermissionService {
+	return &PermissionService{
+		permissionRepo: permissionRepo,
+	}
+}
+
+// Allowed сообщает, разрешено ли хотя бы одной из ролей действие над целью.
+func (s *PermissionService) Allowed(ctx context.Context, roles []string, t uint, target string, action models.Action) (bool, error) {
+	for _, role := range roles {
+		set, err := s.RolePermissions(ctx, role)
+		if err != nil {
+			return false, err
+		}
+		if set.Allows(t, target, action) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Effective объединяет наборы прав всех ролей.
+func (s *PermissionService) Effective(ctx context.Context, roles []string) (models.PermissionSet, error) {
+	result := models.PermissionSet{}
+	for _, role := range roles {
+		set, err := s.RolePermissions(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		result.Merge(set)
+	}
+	return result, nil
+}
+
+// UserRoles возвращает имена ролей пользователя, сначала из кэша, затем из базы. Роли берутся
+// не из токена, чтобы назначение и отзыв роли действовали сразу, а не после его истечения.
+func (s *PermissionService) UserRoles(ctx context.Context, idUser uint) ([]string, error) {
+	data, err := connect.Cache.Fetch(ctx, models.UserRolesCacheKey(idUser), models.PermissionCacheTTL, func(context.Context) ([]byte, error) {
+		names, err := s.permissionRepo.FindUserRoleNames(idUser)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(names)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// RolePermissions возвращает набор прав роли, сначала из кэша, затем из базы.
+func (s *PermissionService) RolePermissions(ctx context.Context, role string) (models.PermissionSet, error) {
+	data, err := connect.Cache.Fetch(ctx, models.PermissionCacheKey(role), models.PermissionCacheTTL, func(context.Context) ([]byte, error) {
+		permissions, err := s.permissionRepo.FindByRoleName(role)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(models.NewPermissionSet(permissions))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var set models.PermissionSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
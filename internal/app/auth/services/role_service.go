@@ -0,0 +1,112 @@
+package services
+
+import (
+	"application_template/internal/app/auth/dto"
+	"application_template/internal/app/auth/models"
+	"application_template/internal/app/auth/repositories"
+	"application_template/internal/base/base_postgres"
+	"application_template/internal/database/connect"
+	"application_template/utils"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type RoleService struct {
+	roleRepo    repositories.RoleRepositoryInterface
+	permissions *PermissionService
+}
+
+func NewRoleService(roleRepo repositories.RoleRepositoryInterface, permissions *PermissionService) *RoleService {
+	return &RoleService{
+		roleRepo:    roleRepo,
+		permissions: permissions,
+	}
+}
+
+func (s *RoleService) UserRoles(idUser uint) ([]models.Role, error) {
+	user, err := s.roleRepo.FindUser(idUser)
+	if err != nil {
+		return nil, notFound(err, &models.User{}, idUser)
+	}
+	return user.Roles, nil
+}
+
+// AssignRole назначает роль пользователю; повторное назначение ничего не меняет.
+func (s *RoleService) AssignRole(idUser, idRole uint) error {
+	if _, err := s.roleRepo.FindUser(idUser); err != nil {
+		return notFound(err, &models.User{}, idUser)
+	}
+	if _, err := s.roleRepo.FindRole(idRole); err != nil {
+		return notFound(err, &models.Role{}, idRole)
+	}
+	return s.roleRepo.AssignRole(idUser, idRole)
+}
+
+func (s *RoleService) RevokeRole(idUser, idRole uint) error {
+	return s.roleRepo.RevokeRole(idUser, idRole)
+}
+
+func (s *RoleService) RolePermissions(idRole uint) ([]models.Permission, error) {
+	role, err := s.roleRepo.FindRole(idRole)
+	if err != nil {
+		return nil, notFound(err, &models.Role{}, idRole)
+	}
+	return role.Permissions, nil
+}
+
+// ReplacePermissions заменяет матрицу прав роли. Кеш прав и ответы /roles с ролью сбрасывают
+// хуки Permission после фиксации замены.
+func (s *RoleService) ReplacePermissions(idRole uint, permissions []dto.PermissionDTO) ([]models.Permission, error) {
+	if _, err := s.roleRepo.FindRole(idRole); err != nil {
+		return nil, notFound(err, &models.Role{}, idRole)
+	}
+	return s.roleRepo.ReplacePermissions(idRole, permissions)
+}
+
+// UserPermissions возвращает объединённые права всех ролей пользователя.
+func (s *RoleService) UserPermissions(ctx context.Context, idUser uint) ([]dto.PermissionDTO, error) {
+	user, err := s.roleRepo.FindUser(idUser)
+	if err != nil {
+		return nil, notFound(err, &models.User{}, idUser)
+	}
+
+	set, err := s.permissions.Effective(ctx, user.RoleNames())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.PermissionDTO, 0, len(set))
+	for key, value := range set {
+		t, target, _ := strings.Cut(key, ":")
+		typ, _ := strconv.ParseUint(t, 10, 64)
+		result = append(result, dto.PermissionDTO{
+			Type:   uint(typ),
+			Target: target,
+			Value:  uint(value),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].Target < result[j].Target
+	})
+
+	return result, nil
+}
+
+func notFound(err error, instance interface{}, id uint) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return utils.NewLocalizeError(err, "exception:failed-to-fetch-one-record", map[string]interface{}{
+		"Table": base_postgres.GetTableName(instance, connect.PostgresDB),
+		"ID":    id,
+	})
+}
@@ -1 +1,33 @@
 package validators
+
+import (
+	"application_template/internal/app/auth/dto"
+	"application_template/internal/app/auth/models"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Register добавляет в v проверки тел запросов модуля auth.
+func Register(v *validator.Validate) error {
+	if err := v.RegisterValidation("permission_type", permissionType); err != nil {
+		return err
+	}
+	v.RegisterStructValidation(registration, dto.RegistrationDTO{})
+	return nil
+}
+
+func permissionType(fl validator.FieldLevel) bool {
+	switch uint(fl.Field().Uint()) {
+	case models.PermissionTypeRoute:
+		return true
+	}
+	return false
+}
+
+// registration не пропускает пароль, содержащий имя пользователя.
+func registration(sl validator.StructLevel) {
+	body := sl.Current().Interface().(dto.RegistrationDTO)
+	if models.ContainsUserName(body.Password, body.UserName) {
+		sl.ReportError(body.Password, "password", "Password", "excludes_user", "")
+	}
+}
@@ -0,0 +1,102 @@
+package base_postgres
+
+import (
+	"application_template/internal/database/connect"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Списки кешируются под KeyList, хешем запроса, вместе с поколением списков модели.
+// Запись повышает поколение вместо поиска всех закешированных страниц; устаревшие истекают по TTL.
+
+// cacheBypass - параметр запроса, читающий записи в обход кеша.
+const cacheBypass = "redisStop"
+
+// QueryHash хеширует нормализованные параметры запроса вместе со scope:
+// порядок параметров и форматирование JSON поиска не меняют хеш.
+func QueryHash(c *gin.Context, scope string) string {
+	query := c.Request.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k != cacheBypass {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.WriteString(scope)
+	for _, k := range keys {
+		for _, v := range query[k] {
+			if k == "search" {
+				v = normalizeJson(v)
+			}
+			b.WriteString("\x00" + k + "=" + v)
+		}
+	}
+
+	sum := sha256.Sum256(b.Bytes())
+	return hex.EncodeToString(sum[:16])
+}
+
+func normalizeJson(s string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return s
+	}
+	return string(normalized)
+}
+
+func generationKey(ri RedisInterface) string {
+	return ri.KeyAll() + ":generation"
+}
+
+// listKey возвращает ключ кеша запрошенного списка, пустой, если список не кешируется.
+func (ct *CrudTemplate) listKey(c *gin.Context) string {
+	if ct.ri.ListTTL() < 0 || c.Query(cacheBypass) != "" {
+		return ""
+	}
+	key := ct.ri.KeyList(c)
+	if key == "" {
+		return ""
+	}
+
+	gen, err := connect.Cache.Counter(c, generationKey(ct.ri))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", key, gen)
+}
+
+// invalidate сбрасывает все закешированные списки модели и записи ids, на всех экземплярах,
+// если у кеша есть локальный уровень.
+func (ct *CrudTemplate) invalidate(c *gin.Context, ids ...string) {
+	_ = InvalidateCache(c, ct.ri, ids...)
+}
+
+// InvalidateCache сбрасывает закешированные ответы контроллера ri, как его собственная запись:
+// все списки и записи ids. Нужна, когда ответы включают данные, изменённые в обход контроллера,
+// например права, загруженные вместе с ролью.
+func InvalidateCache(ctx context.Context, ri RedisInterface, ids ...string) error {
+	_, err := connect.Cache.Incr(ctx, generationKey(ri))
+
+	keys := []string{ri.KeyAll()}
+	for _, id := range ids {
+		keys = append(keys, ri.KeyOne(id))
+	}
+	if delErr := connect.Cache.Delete(ctx, keys...); err == nil {
+		err = delErr
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package base_postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type commitHooksKey struct{}
+
+// commitHooksSetting хранит хуки запроса, выполняемого в своей транзакции по умолчанию.
+const commitHooksSetting = "base_postgres:commit_hooks"
+
+// commitHooks - функции, выполняемые после фиксации транзакции.
+type commitHooks struct {
+	mu  sync.Mutex
+	fns []func(ctx context.Context)
+}
+
+func (h *commitHooks) add(fn func(ctx context.Context)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fns = append(h.fns, fn)
+}
+
+func (h *commitHooks) run(ctx context.Context) {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
+
+// Transaction выполняет fc в транзакции db, а после её фиксации - функции, зарегистрированные
+// в ней через AfterCommit. Во вложенной Transaction они ждут внешнюю.
+func Transaction(db *gorm.DB, fc func(tx *gorm.DB) error) error {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Value(commitHooksKey{}).(*commitHooks); ok {
+		return db.Transaction(fc)
+	}
+
+	hooks := &commitHooks{}
+	if err := db.WithContext(context.WithValue(ctx, commitHooksKey{}, hooks)).Transaction(fc); err != nil {
+		return err
+	}
+	hooks.run(ctx)
+	return nil
+}
+
+// AfterCommit выполняет fn после фиксации изменения, которое делает tx, чтобы сброшенное fn из
+// кеша тем временем не прочитали обратно из старых строк. Внутри Transaction fn ждёт её коммита,
+// иначе - транзакции запроса по умолчанию; при неудачном изменении fn отбрасывается. В транзакции,
+// открытой не через Transaction, fn выполняется после запроса: когда она зафиксируется, неизвестно.
+func AfterCommit(tx *gorm.DB, fn func(ctx context.Context)) {
+	if hooks, ok := tx.Statement.Context.Value(commitHooksKey{}).(*commitHooks); ok {
+		hooks.add(fn)
+		return
+	}
+	hooks, _ := tx.Statement.Settings.LoadOrStore(commitHooksSetting, &commitHooks{})
+	hooks.(*commitHooks).add(fn)
+}
+
+// RegisterCallbacks выполняет функции, зарегистрированные через AfterCommit вне Transaction,
+// по завершении транзакции запроса по умолчанию.
+func RegisterCallbacks(db *gorm.DB) error {
+	const after = "gorm:commit_or_rollback_transaction"
+	const name = "base_postgres:after_commit"
+
+	if err := db.Callback().Create().After(after).Register(name, runCommitHooks); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After(after).Register(name, runCommitHooks); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After(after).Register(name, runCommitHooks)
+}
+
+func runCommitHooks(db *gorm.DB) {
+	hooks, ok := db.Statement.Settings.LoadAndDelete(commitHooksSetting)
+	if !ok || db.Error != nil {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(*sql.Tx); inTx {
+		log.Println("base_postgres: commit hooks run before the commit of a transaction opened outside Transaction")
+	}
+	hooks.(*commitHooks).run(db.Statement.Context)
+}
@@ -0,0 +1,185 @@
+package base_postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// recordingPool - соединение, которое ничего не выполняет и записывает запрошенные у него
+// запросы, транзакции, коммиты и откаты.
+type recordingPool struct {
+	log  *[]string
+	fail bool
+}
+
+func (p *recordingPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *recordingPool) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	*p.log = append(*p.log, "exec")
+	if p.fail {
+		return nil, errors.New("exec failed")
+	}
+	return recordingResult{}, nil
+}
+
+func (p *recordingPool) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *recordingPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func (p *recordingPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	*p.log = append(*p.log, "begin")
+	return &recordingTx{p: p, log: p.log}, nil
+}
+
+// recordingTx работает на соединении своего пула; в отличие от пула, он не может начать транзакцию.
+type recordingTx struct {
+	p   *recordingPool
+	log *[]string
+}
+
+func (tx *recordingTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return tx.p.PrepareContext(ctx, query)
+}
+
+func (tx *recordingTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.p.ExecContext(ctx, query, args...)
+}
+
+func (tx *recordingTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.p.QueryContext(ctx, query, args...)
+}
+
+func (tx *recordingTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.p.QueryRowContext(ctx, query, args...)
+}
+
+func (tx *recordingTx) Commit() error {
+	*tx.log = append(*tx.log, "commit")
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	*tx.log = append(*tx.log, "rollback")
+	return nil
+}
+
+type recordingResult struct{}
+
+func (recordingResult) LastInsertId() (int64, error) { return 0, nil }
+func (recordingResult) RowsAffected() (int64, error) { return 1, nil }
+
+type commitTask struct {
+	Entity
+	Name string
+	log  *[]string
+}
+
+func (t *commitTask) AfterDelete(tx *gorm.DB) error {
+	log := t.log
+	AfterCommit(tx, func(context.Context) {
+		*log = append(*log, "hook")
+	})
+	return nil
+}
+
+func commitDB(t *testing.T, log *[]string, fail bool) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &recordingPool{log: log, fail: fail}}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestAfterCommit(t *testing.T) {
+	tests := []struct {
+		name string
+		fail bool
+		run  func(db *gorm.DB, task *commitTask) error
+		want []string
+	}{
+		{
+			name: "default transaction",
+			run: func(db *gorm.DB, task *commitTask) error {
+				return db.Delete(task).Error
+			},
+			want: []string{"begin", "exec", "commit", "hook"},
+		},
+		{
+			name: "failed statement",
+			fail: true,
+			run: func(db *gorm.DB, task *commitTask) error {
+				return db.Delete(task).Error
+			},
+			want: []string{"begin", "exec", "rollback"},
+		},
+		{
+			name: "transaction",
+			run: func(db *gorm.DB, task *commitTask) error {
+				return Transaction(db, func(tx *gorm.DB) error {
+					if err := tx.Delete(task).Error; err != nil {
+						return err
+					}
+					*task.log = append(*task.log, "done")
+					return nil
+				})
+			},
+			want: []string{"begin", "exec", "done", "commit", "hook"},
+		},
+		{
+			name: "rolled back transaction",
+			run: func(db *gorm.DB, task *commitTask) error {
+				return Transaction(db, func(tx *gorm.DB) error {
+					if err := tx.Delete(task).Error; err != nil {
+						return err
+					}
+					return errors.New("abort")
+				})
+			},
+			want: []string{"begin", "exec", "rollback"},
+		},
+		{
+			name: "nested transaction",
+			run: func(db *gorm.DB, task *commitTask) error {
+				return Transaction(db, func(tx *gorm.DB) error {
+					err := Transaction(tx, func(tx *gorm.DB) error {
+						return tx.Delete(task).Error
+					})
+					*task.log = append(*task.log, "inner done")
+					return err
+				})
+			},
+			// вложенная транзакция - это savepoint, первый exec
+			want: []string{"begin", "exec", "exec", "inner done", "commit", "hook"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			db := commitDB(t, &log, tt.fail)
+			task := &commitTask{log: &log}
+			task.ID = 1
+
+			_ = tt.run(db, task)
+			if !reflect.DeepEqual(log, tt.want) {
+				t.Errorf("log = %v, want %v", log, tt.want)
+			}
+		})
+	}
+}
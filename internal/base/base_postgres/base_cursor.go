@@ -0,0 +1,312 @@
+package base_postgres
+
+import (
+	"application_template/utils"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Курсорная пагинация читает строки после (или до) строки, на которую указывает курсор, вместо
+// пропуска строк через OFFSET. Курсор непрозрачен для клиентов: он хранит активные колонки сортировки
+// и значения той строки, поэтому действителен только вместе с order_by, с которым создан.
+// NULL сортируется как наибольшее значение, как в Postgres по умолчанию: последним по возрастанию,
+// первым по убыванию.
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type cursorToken struct {
+	Columns   []string          `json:"c"`
+	Values    []json.RawMessage `json:"v"`
+	Direction string            `json:"d"`
+}
+
+type CursorPager interface {
+	Pager
+
+	getCursors() (next, prev string)
+	find(db *gorm.DB, o OrderFilter, a interface{}, scopes ...func(*gorm.DB) *gorm.DB) error
+}
+
+type CursorPage struct {
+	pageSize   int
+	noCount    bool
+	token      *cursorToken
+	next, prev string
+}
+
+// NewCursorPager возвращает пейджер страницы, на которую указывает курсор, первой при пустом курсоре.
+func NewCursorPager(cursor string, pageSize int, count bool) (CursorPager, error) {
+	p := &CursorPage{
+		pageSize: pageSize,
+		noCount:  !count,
+	}
+	if cursor == "" {
+		return p, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalidCursor(err)
+	}
+	token := &cursorToken{}
+	if err := json.Unmarshal(raw, token); err != nil {
+		return nil, invalidCursor(err)
+	}
+	if len(token.Columns) == 0 || len(token.Columns) != len(token.Values) {
+		return nil, invalidCursor(errInvalidCursor)
+	}
+	if token.Direction != cursorNext && token.Direction != cursorPrev {
+		return nil, invalidCursor(errInvalidCursor)
+	}
+
+	p.token = token
+	return p, nil
+}
+
+func invalidCursor(err error) error {
+	return utils.NewLocalizeError(err, "exception:invalid-cursor", nil)
+}
+
+func (p *CursorPage) getPage() int {
+	return 0
+}
+
+func (p *CursorPage) getPageSize() int {
+	return p.pageSize
+}
+
+func (p *CursorPage) getOffset() int {
+	return 0
+}
+
+func (p *CursorPage) skipCount() bool {
+	return p.noCount
+}
+
+func (p *CursorPage) getCursors() (string, string) {
+	return p.next, p.prev
+}
+
+func (p *CursorPage) paginate() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Limit(p.pageSize)
+	}
+}
+
+func (p *CursorPage) backward() bool {
+	return p.token != nil && p.token.Direction == cursorPrev
+}
+
+// find читает на одну строку больше размера страницы, чтобы знать, есть ли следующая,
+// и сохраняет курсоры первой и последней строки страницы.
+func (p *CursorPage) find(db *gorm.DB, o OrderFilter, a interface{}, scopes ...func(*gorm.DB) *gorm.DB) error {
+	st := &gorm.Statement{DB: db}
+	if err := st.Parse(a); err != nil {
+		return err
+	}
+	columns := keysetColumns(o.columns(db), st.Schema)
+	backward := p.backward()
+
+	tx := db
+	if p.token != nil {
+		query, vars, err := p.condition(st.Schema, columns, backward)
+		if err != nil {
+			return err
+		}
+		tx = tx.Where(query, vars...)
+	}
+	for _, column := range columns {
+		tx = tx.Order(orderBy(st.Schema.Table, column.name, column.desc != backward))
+	}
+
+	if res := tx.Scopes(scopes...).Limit(p.pageSize + 1).Find(a); res.Error != nil {
+		return res.Error
+	}
+
+	rows := reflect.Indirect(reflect.ValueOf(a))
+	more := rows.Len() > p.pageSize
+	if more {
+		rows.Set(rows.Slice(0, p.pageSize))
+	}
+	if backward {
+		swap := reflect.Swapper(rows.Interface())
+		for i, j := 0, rows.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	if rows.Len() == 0 {
+		return nil
+	}
+
+	first, err := encodeCursor(db.Statement.Context, st.Schema, columns, rows.Index(0), cursorPrev)
+	if err != nil {
+		return err
+	}
+	last, err := encodeCursor(db.Statement.Context, st.Schema, columns, rows.Index(rows.Len()-1), cursorNext)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case backward:
+		p.next = last
+		if more {
+			p.prev = first
+		}
+	default:
+		if more {
+			p.next = last
+		}
+		if p.token != nil {
+			p.prev = first
+		}
+	}
+	return nil
+}
+
+// condition строит сравнение (a > ?) OR (a = ? AND b > ?) OR ... для строки курсора.
+func (p *CursorPage) condition(s *schema.Schema, columns []orderColumn, backward bool) (string, []interface{}, error) {
+	if len(columns) != len(p.token.Columns) {
+		return "", nil, invalidCursor(errInvalidCursor)
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		if p.token.Columns[i] != column.name {
+			return "", nil, invalidCursor(errInvalidCursor)
+		}
+		value := reflect.New(s.LookUpField(column.name).FieldType)
+		if err := json.Unmarshal(p.token.Values[i], value.Interface()); err != nil {
+			return "", nil, invalidCursor(err)
+		}
+		values[i] = value.Elem().Interface()
+	}
+
+	var ors []string
+	var vars []interface{}
+	for i, column := range columns {
+		ands := make([]string, 0, i+1)
+		var andVars []interface{}
+		for j := 0; j < i; j++ {
+			col := quote(s.Table) + "." + quote(columns[j].name)
+			if isNull(values[j]) {
+				ands = append(ands, col+" IS NULL")
+				continue
+			}
+			ands = append(ands, col+" = ?")
+			andVars = append(andVars, values[j])
+		}
+
+		col := quote(s.Table) + "." + quote(column.name)
+		greater := column.desc == backward
+		switch {
+		case isNull(values[i]) && greater:
+			// после NULL ничего не сортируется
+			continue
+		case isNull(values[i]):
+			ands = append(ands, col+" IS NOT NULL")
+		case greater && nullableColumn(s.LookUpField(column.name)):
+			ands = append(ands, "("+col+" > ? OR "+col+" IS NULL)")
+			andVars = append(andVars, values[i])
+		case greater:
+			ands = append(ands, col+" > ?")
+			andVars = append(andVars, values[i])
+		default:
+			ands = append(ands, col+" < ?")
+			andVars = append(andVars, values[i])
+		}
+
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+		vars = append(vars, andVars...)
+	}
+	if len(ors) == 0 {
+		return "1 = 0", nil, nil
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", vars, nil
+}
+
+// orderBy сортирует по колонке, считая NULL наибольшим значением.
+func orderBy(table, name string, desc bool) string {
+	if desc {
+		return quote(table) + "." + quote(name) + " DESC NULLS FIRST"
+	}
+	return quote(table) + "." + quote(name) + " ASC NULLS LAST"
+}
+
+// nullableColumn сообщает, может ли колонка хранить NULL: указатель или сканирующий его тип,
+// не объявленный NOT NULL.
+func nullableColumn(field *schema.Field) bool {
+	if field.PrimaryKey || field.NotNull {
+		return false
+	}
+	if field.FieldType.Kind() == reflect.Ptr {
+		return true
+	}
+	_, scanner := reflect.New(field.FieldType).Interface().(sql.Scanner)
+	return scanner
+}
+
+func isNull(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return true
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		return err == nil && value == nil
+	}
+	return false
+}
+
+// keysetColumns оставляет известные схеме колонки сортировки и завершает их первичным ключом,
+// чтобы у каждой строки была своя позиция.
+func keysetColumns(columns []orderColumn, s *schema.Schema) []orderColumn {
+	result := make([]orderColumn, 0, len(columns)+1)
+	seen := map[string]bool{}
+	for _, column := range columns {
+		if seen[column.name] || s.LookUpField(column.name) == nil {
+			continue
+		}
+		seen[column.name] = true
+		result = append(result, column)
+	}
+
+	if id := s.PrioritizedPrimaryField; id != nil && !seen[id.DBName] {
+		result = append(result, orderColumn{name: id.DBName})
+	}
+	return result
+}
+
+func encodeCursor(ctx context.Context, s *schema.Schema, columns []orderColumn, row reflect.Value, direction string) (string, error) {
+	token := cursorToken{Direction: direction}
+	for _, column := range columns {
+		value, _ := s.LookUpField(column.name).ValueOf(ctx, reflect.Indirect(row))
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		token.Columns = append(token.Columns, column.name)
+		token.Values = append(token.Values, raw)
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,101 @@
+package base_postgres
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type cursorTask struct {
+	Entity
+	Name       string
+	FinishedAt *time.Time
+}
+
+func TestCursorCondition(t *testing.T) {
+	s := filterSchema(t, &cursorTask{})
+	finished := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		columns  []orderColumn
+		values   []interface{}
+		backward bool
+		query    string
+		vars     []interface{}
+	}{
+		{
+			name:    "not null column",
+			columns: []orderColumn{{name: "name"}, {name: "id"}},
+			values:  []interface{}{"b", 7},
+			query:   `(("cursor_tasks"."name" > ?) OR ("cursor_tasks"."name" = ? AND "cursor_tasks"."id" > ?))`,
+			vars:    []interface{}{"b", "b", uint(7)},
+		},
+		{
+			name:    "nullable column after a value",
+			columns: []orderColumn{{name: "finished_at"}, {name: "id"}},
+			values:  []interface{}{finished, 7},
+			query: `((("cursor_tasks"."finished_at" > ? OR "cursor_tasks"."finished_at" IS NULL)) OR ` +
+				`("cursor_tasks"."finished_at" = ? AND "cursor_tasks"."id" > ?))`,
+			vars: []interface{}{&finished, &finished, uint(7)},
+		},
+		{
+			name:    "nullable column after NULL",
+			columns: []orderColumn{{name: "finished_at"}, {name: "id"}},
+			values:  []interface{}{nil, 7},
+			query:   `(("cursor_tasks"."finished_at" IS NULL AND "cursor_tasks"."id" > ?))`,
+			vars:    []interface{}{uint(7)},
+		},
+		{
+			name:     "nullable column before NULL",
+			columns:  []orderColumn{{name: "finished_at"}, {name: "id"}},
+			values:   []interface{}{nil, 7},
+			backward: true,
+			query:    `(("cursor_tasks"."finished_at" IS NOT NULL) OR ("cursor_tasks"."finished_at" IS NULL AND "cursor_tasks"."id" < ?))`,
+			vars:     []interface{}{uint(7)},
+		},
+		{
+			name:    "descending nullable column after NULL",
+			columns: []orderColumn{{name: "finished_at", desc: true}, {name: "id"}},
+			values:  []interface{}{nil, 7},
+			query:   `(("cursor_tasks"."finished_at" IS NOT NULL) OR ("cursor_tasks"."finished_at" IS NULL AND "cursor_tasks"."id" > ?))`,
+			vars:    []interface{}{uint(7)},
+		},
+		{
+			name:    "descending nullable column after a value",
+			columns: []orderColumn{{name: "finished_at", desc: true}, {name: "id"}},
+			values:  []interface{}{finished, 7},
+			query:   `(("cursor_tasks"."finished_at" < ?) OR ("cursor_tasks"."finished_at" = ? AND "cursor_tasks"."id" > ?))`,
+			vars:    []interface{}{&finished, &finished, uint(7)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &cursorToken{Direction: cursorNext}
+			if tt.backward {
+				token.Direction = cursorPrev
+			}
+			for i, column := range tt.columns {
+				raw, err := json.Marshal(tt.values[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				token.Columns = append(token.Columns, column.name)
+				token.Values = append(token.Values, raw)
+			}
+
+			p := &CursorPage{token: token}
+			query, vars, err := p.condition(s, tt.columns, p.backward())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.query {
+				t.Errorf("query\n got %s\nwant %s", query, tt.query)
+			}
+			if !reflect.DeepEqual(vars, tt.vars) {
+				t.Errorf("vars = %#v, want %#v", vars, tt.vars)
+			}
+		})
+	}
+}
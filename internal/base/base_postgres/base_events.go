@@ -0,0 +1,171 @@
+package base_postgres
+
+import (
+	"application_template/internal/database/outbox"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// EventSource реализуют модели, изменения которых через CrudService публикуются как доменные
+// события, например user.created. Событие пишется в outbox в транзакции изменения и передаётся
+// в RabbitMQ, поэтому подключённым моделям нужен RABBITMQ_ENABLED.
+type EventSource interface {
+	// EventType - префикс действий событий модели, например user.
+	EventType() string
+	// EventVersion - версия схемы снимков, повышается при изменении их формы.
+	EventVersion() int
+}
+
+// withEvent выполняет write и для EventSource пишет событие изменения в outbox в той же
+// транзакции, с экспортируемыми колонками записи до и после него.
+func (c *CrudService) withEvent(one HasId, action string, write func(repo CrudRepository) error) error {
+	if _, ok := one.(EventSource); !ok {
+		return write(c.repo)
+	}
+
+	return c.repo.Transaction(func(tx *gorm.DB) error {
+		var before HasId
+		if action != outbox.Created {
+			var err error
+			if before, err = lockRecord(tx, one, map[string]interface{}{"id": one.GetId()}); err != nil {
+				return err
+			}
+		}
+		return writeEvent(tx, one, action, before, write)
+	})
+}
+
+// lockRecord блокирует и возвращает запись модели one по условию where, мягко удалённую тоже.
+// Блокировка сохраняет в снимке то, что заменяет изменение.
+func lockRecord(tx *gorm.DB, one HasId, where map[string]interface{}) (HasId, error) {
+	record := newLike(one)
+	res := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where(where).First(record)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return record, nil
+}
+
+// writeEvent выполняет write в транзакции tx и пишет в outbox событие изменения EventSource one
+// со снимком before, прочитанным до write, nil для созданной записи.
+func writeEvent(tx *gorm.DB, one HasId, action string, before HasId, write func(repo CrudRepository) error) error {
+	source := one.(EventSource)
+	st := &gorm.Statement{DB: tx}
+	if err := st.Parse(one); err != nil {
+		return err
+	}
+	columns := ExportColumns(st.Schema)
+
+	if err := write(NewWithDB(tx)); err != nil {
+		return err
+	}
+
+	var after HasId
+	if action != outbox.Deleted {
+		after = newLike(one)
+		if res := tx.Unscoped().Where("id = ?", one.GetId()).First(after); res.Error != nil {
+			return res.Error
+		}
+	}
+
+	ctx := tx.Statement.Context
+	event, err := outbox.New(source.EventType()+"."+action, source.EventVersion(), st.Schema.Table, one.GetId(),
+		snapshot(ctx, columns, before), snapshot(ctx, columns, after))
+	if err != nil {
+		return err
+	}
+	return outbox.Add(tx, event)
+}
+
+// importRow сохраняет строку импорта, для EventSource - с событием. При upsert строка, с которой
+// конфликтует импортированная, ищется по колонкам ограничения keys, и событие становится её обновлением.
+func importRow(tx *gorm.DB, row HasId, conflict *Conflict, keys []string) error {
+	write := func(repo CrudRepository) error {
+		if conflict != nil {
+			return repo.CreateOrUpdate(NoScope, row, conflict.Constraint, conflict.Columns)
+		}
+		return repo.Insert(row)
+	}
+	if _, ok := row.(EventSource); !ok {
+		return write(NewWithDB(tx))
+	}
+
+	action, before := outbox.Created, HasId(nil)
+	if conflict != nil {
+		existing, err := conflictingRecord(tx, row, keys)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			action, before = outbox.Updated, existing
+		}
+	}
+	return writeEvent(tx, row, action, before, write)
+}
+
+// conflictingRecord блокирует и возвращает сохранённую запись с теми же значениями колонок keys, что у row,
+// nil, если такой нет.
+func conflictingRecord(tx *gorm.DB, row HasId, keys []string) (HasId, error) {
+	st := &gorm.Statement{DB: tx}
+	if err := st.Parse(row); err != nil {
+		return nil, err
+	}
+	where := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		field := st.Schema.LookUpField(key)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no column %s", st.Schema.Table, key)
+		}
+		where[field.DBName], _ = field.ValueOf(tx.Statement.Context, reflect.ValueOf(row))
+	}
+
+	existing, err := lockRecord(tx, row, where)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return existing, err
+}
+
+// constraintColumns возвращает колонки ограничения таблицы модели one.
+func constraintColumns(tx *gorm.DB, one HasId, constraint string) ([]string, error) {
+	st := &gorm.Statement{DB: tx}
+	if err := st.Parse(one); err != nil {
+		return nil, err
+	}
+	var columns []string
+	res := tx.Raw(`SELECT a.attname FROM pg_constraint c
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+		WHERE c.conname = ? AND c.conrelid = ?::regclass`, constraint, st.Schema.Table).Scan(&columns)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s has no constraint %s", st.Schema.Table, constraint)
+	}
+	return columns, nil
+}
+
+// newLike возвращает новую запись модели one.
+func newLike(one HasId) HasId {
+	return reflect.New(reflect.TypeOf(one).Elem()).Interface().(HasId)
+}
+
+// snapshot возвращает значения колонок записи по именам колонок, nil без записи.
+// Колонки - экспортируемые, поэтому пароли и скрытые от JSON поля в события не попадают.
+func snapshot(ctx context.Context, columns []*schema.Field, record HasId) interface{} {
+	if record == nil {
+		return nil
+	}
+	row := reflect.ValueOf(record)
+	values := make(map[string]interface{}, len(columns))
+	for _, field := range columns {
+		values[field.DBName], _ = field.ValueOf(ctx, row)
+	}
+	return values
+}
@@ -0,0 +1,40 @@
+package base_postgres
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshotKeepsHiddenFieldsOut(t *testing.T) {
+	s := filterSchema(t, &filterUser{})
+	user := &filterUser{UserName: "admin", UserPassword: "$2a$10$hash", Token: "secret", Active: true}
+	user.ID = 7
+
+	raw, err := json.Marshal(snapshot(context.Background(), ExportColumns(s), user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, column := range []string{"user_password", "token", "roles", "UserPassword", "Token"} {
+		if _, ok := got[column]; ok {
+			t.Errorf("snapshot has %s: %s", column, raw)
+		}
+	}
+	if got["id"] != 7.0 || got["user_name"] != "admin" || got["active"] != true {
+		t.Errorf("snapshot = %s", raw)
+	}
+}
+
+func TestSnapshotWithoutRecord(t *testing.T) {
+	s := filterSchema(t, &filterUser{})
+
+	var record HasId
+	if got := snapshot(context.Background(), ExportColumns(s), record); got != nil {
+		t.Errorf("snapshot = %v, want nil", got)
+	}
+}
@@ -0,0 +1,324 @@
+package base_postgres
+
+import (
+	"application_template/internal/database/connect"
+	"application_template/utils"
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	exportBatchSize  = 500
+	exportTimeFormat = "2006-01-02 15:04:05"
+	exportSheet      = "Sheet1"
+
+	formatCSV  = "csv"
+	formatXLSX = "xlsx"
+)
+
+var exportContentTypes = map[string]string{
+	formatCSV:  "text/csv; charset=utf-8",
+	formatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type exportWriter interface {
+	writeRow(values []interface{}) error
+	// flush отправляет клиенту записанное к концу пачки строк.
+	flush() error
+	close() error
+}
+
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) writeRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			record[i] = escapeFormula(v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExport) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) close() error {
+	return e.flush()
+}
+
+// escapeFormula экранирует текстовую ячейку, которую таблица иначе выполнила бы как формулу.
+// Числа пишутся как есть, поэтому отрицательные остаются числами.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// xlsxExport пишет строки через потоковый writer excelize, который держит большие листы
+// во временном файле, а не в памяти, и отправляет книгу при закрытии.
+type xlsxExport struct {
+	out io.Writer
+	f   *excelize.File
+	sw  *excelize.StreamWriter
+	row int
+}
+
+func newXlsxExport(out io.Writer) (*xlsxExport, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter(exportSheet)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxExport{out: out, f: f, sw: sw}, nil
+}
+
+func (e *xlsxExport) writeRow(values []interface{}) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	return e.sw.SetRow(cell, values)
+}
+
+// flush ничего не делает: книга отправляется целиком при закрытии.
+func (e *xlsxExport) flush() error {
+	return nil
+}
+
+func (e *xlsxExport) close() error {
+	if err := e.sw.Flush(); err != nil {
+		return err
+	}
+	return e.f.Write(e.out)
+}
+
+// ExportColumns возвращает колонки схемы модели, которые стоит экспортировать:
+// связи, скрытые от JSON поля и пароли исключаются.
+func ExportColumns(s *schema.Schema) []*schema.Field {
+	var columns []*schema.Field
+	for _, field := range s.Fields {
+		if field.DBName == "" || !field.Readable || hiddenField(field) {
+			continue
+		}
+		columns = append(columns, field)
+	}
+	return columns
+}
+
+// hiddenField сообщает, что поле не должно покидать сервер и служить для поиска: оно
+// скрыто от JSON или хранит пароль.
+func hiddenField(field *schema.Field) bool {
+	if strings.Split(field.Tag.Get("json"), ",")[0] == "-" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(field.DBName), "password") ||
+		strings.Contains(strings.ToLower(field.Name), "password")
+}
+
+// exportHeader локализует заголовок колонки по "column:<table>.<column>", затем по "column:<column>".
+func exportHeader(c *gin.Context, table string, field *schema.Field) string {
+	return utils.LocalizeOr(c, field.Name, nil, "column:"+table+"."+field.DBName, "column:"+field.DBName)
+}
+
+// exportValue превращает значение поля в значение ячейки. Переводимые JSON поля выводятся
+// на языке запроса, затем на английском, затем на любом доступном.
+func exportValue(ctx context.Context, field *schema.Field, row reflect.Value, lang string) interface{} {
+	value, zero := field.ValueOf(ctx, row)
+	if zero && field.FieldType.Kind() == reflect.Ptr {
+		return nil
+	}
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		value = v
+	}
+	for rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr; rv = rv.Elem() {
+		if rv.IsNil() {
+			return nil
+		}
+		value = rv.Elem().Interface()
+	}
+
+	if isJsonField(field) {
+		return translation(value, lang)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.Format(exportTimeFormat)
+	case []byte:
+		return string(v)
+	case bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+func translation(value interface{}, lang string) interface{} {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		raw = b
+	}
+
+	var translations map[string]interface{}
+	if err := json.Unmarshal(raw, &translations); err != nil {
+		return string(raw)
+	}
+	for _, key := range []string{lang, "en"} {
+		if t, ok := translations[key]; ok && t != nil && t != "" {
+			return fmt.Sprint(t)
+		}
+	}
+	for _, t := range translations {
+		if t != nil && t != "" {
+			return fmt.Sprint(t)
+		}
+	}
+	return nil
+}
+
+// GetExcelFunc потоково отдаёт записи по поиску, сортировке и scope запроса в XLSX
+// или в CSV при format=csv. Записи читаются пачками по курсору, а не все сразу.
+func (ct *CrudTemplate) GetExcelFunc(c *gin.Context, findAll FindAll) *AppError {
+	format := strings.ToLower(c.DefaultQuery("format", formatXLSX))
+	if _, ok := exportContentTypes[format]; !ok {
+		format = formatXLSX
+	}
+
+	a := ct.mi.GetAll()
+	st := &gorm.Statement{DB: connect.PostgresDB}
+	if err := st.Parse(a); err != nil {
+		return LocalizeError(c, err)
+	}
+
+	se, err := getQuery(c, a)
+	if err != nil {
+		return LocalizeError(c, err)
+	}
+	order := getOrder(c, ct.mi.GetOne())
+
+	var w exportWriter
+	switch format {
+	case formatCSV:
+		w = &csvExport{w: csv.NewWriter(c.Writer)}
+	default:
+		xw, err := newXlsxExport(c.Writer)
+		if err != nil {
+			return LocalizeError(c, err)
+		}
+		defer xw.f.Close()
+		w = xw
+	}
+
+	columns := ExportColumns(st.Schema)
+	headers := make([]interface{}, len(columns))
+	for i, field := range columns {
+		headers[i] = exportHeader(c, st.Schema.Table, field)
+	}
+
+	// Заголовки уходят с первыми байтами, поэтому ошибка до них ещё получает JSON ответ:
+	// сразу для CSV, и пока все строки не в книге для XLSX.
+	attach := func() {
+		c.Header("Content-Type", exportContentTypes[format])
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, st.Schema.Table, format))
+	}
+	if format == formatCSV {
+		attach()
+	}
+
+	fail := func(err error) *AppError {
+		if c.Writer.Written() {
+			_ = c.Error(err)
+			return nil
+		}
+		return I18nError(c, a, "exception:could-not-fetch-records")
+	}
+
+	if err := w.writeRow(headers); err != nil {
+		return fail(err)
+	}
+
+	lang := c.GetString("lang")
+	var p CursorPager = &CursorPage{pageSize: exportBatchSize, noCount: true}
+	for {
+		batch := ct.mi.GetAll()
+		var total int64
+		if err := findAll(batch, ct.mi.ScopeAll, p, order, &total, se); err != nil {
+			return fail(err)
+		}
+
+		rows := reflect.Indirect(reflect.ValueOf(batch))
+		for i := 0; i < rows.Len(); i++ {
+			row := reflect.Indirect(rows.Index(i))
+			values := make([]interface{}, len(columns))
+			for j, field := range columns {
+				values[j] = exportValue(c, field, row, lang)
+			}
+			if err := w.writeRow(values); err != nil {
+				return fail(err)
+			}
+		}
+		if err := w.flush(); err != nil {
+			return fail(err)
+		}
+
+		next, _ := p.getCursors()
+		if next == "" {
+			break
+		}
+		if p, err = NewCursorPager(next, exportBatchSize, false); err != nil {
+			return fail(err)
+		}
+	}
+
+	if format == formatXLSX {
+		attach()
+	}
+	if err := w.close(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+func (ct *CrudTemplate) GetExcel(c *gin.Context, allInter FindAllInterface) *AppError {
+	return ct.GetExcelFunc(c, allInter.FindAll)
+}
@@ -0,0 +1,47 @@
+package base_postgres
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestCsvExportEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"text", "admin", "admin"},
+		{"formula", "=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"plus", "+1+1", "'+1+1"},
+		{"minus", "-2+3", "'-2+3"},
+		{"at", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\t=1", "'\t=1"},
+		{"carriage return", "\r=1", "'\r=1"},
+		{"inner equals", "a=b", "a=b"},
+		{"negative number", -5, "-5"},
+		{"empty", "", ""},
+		{"null", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := &csvExport{w: csv.NewWriter(&buf)}
+			if err := e.writeRow([]interface{}{tt.value, "end"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := csv.NewReader(&buf).Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record[0] != tt.want {
+				t.Errorf("cell = %q, want %q", record[0], tt.want)
+			}
+		})
+	}
+}
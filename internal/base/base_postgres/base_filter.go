@@ -0,0 +1,456 @@
+package base_postgres
+
+import (
+	"application_template/utils"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// Параметр search - JSON объект. Каждый ключ - поле, путь по связи, например "roles.name",
+// переводимое JSON поле с языком, например "title.en", или один из группирующих ключей
+// "and"/"or" с массивом таких объектов:
+//
+//	{"active": true, "user_name": {"like": "adm"}, "or": [{"id": {"in": [1, 2]}}, {"created_at": {"between": ["2023-01-01", "2023-02-01"]}}]}
+//
+// Поле сопоставляется объекту операторов или простому значению. Простая строка означает "like",
+// любое другое простое значение - "eq", а прежняя строка "not_null" - {"is_null": false}.
+// Объект без ключей операторов - связь: {"roles": {"name": "admin"}}.
+// Каждое поле проверяется по схеме gorm, каждое значение привязывается как параметр.
+// Поля, не попадающие в экспорт, пароли и скрытые от JSON поля, поиску неизвестны
+// как у модели, так и у её связей.
+
+const (
+	opEq      = "eq"
+	opNe      = "ne"
+	opLt      = "lt"
+	opLte     = "lte"
+	opGt      = "gt"
+	opGte     = "gte"
+	opIn      = "in"
+	opNotIn   = "not_in"
+	opLike    = "like"
+	opBetween = "between"
+	opIsNull  = "is_null"
+
+	groupAnd = "and"
+	groupOr  = "or"
+)
+
+const (
+	maxFilterDepth      = 6
+	maxFilterConditions = 50
+)
+
+var operators = map[string]string{
+	opEq:  "=",
+	opNe:  "<>",
+	opLt:  "<",
+	opLte: "<=",
+	opGt:  ">",
+	opGte: ">=",
+}
+
+type Filter interface {
+	compile(c *filterCompiler, s *schema.Schema, table string) (string, error)
+}
+
+type filterGroup struct {
+	or    bool
+	items []Filter
+}
+
+type filterCondition struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+type filterError struct {
+	reason string
+}
+
+func (e filterError) Error() string {
+	return e.reason
+}
+
+func invalidFilter(format string, a ...interface{}) error {
+	reason := fmt.Sprintf(format, a...)
+	return utils.NewLocalizeError(filterError{reason: reason}, "exception:invalid-search", map[string]interface{}{
+		"Reason": reason,
+	})
+}
+
+// ParseFilter разбирает JSON поиска в дерево фильтра без проверки полей.
+func ParseFilter(raw []byte) (Filter, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, invalidFilter("search must be a JSON object")
+	}
+
+	count := 0
+	return parseGroup(obj, nil, false, 0, &count)
+}
+
+func parseGroup(obj map[string]interface{}, prefix []string, or bool, depth int, count *int) (Filter, error) {
+	if depth > maxFilterDepth {
+		return nil, invalidFilter("search is nested deeper than %d levels", maxFilterDepth)
+	}
+
+	g := &filterGroup{or: or}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := obj[key]
+
+		if key == groupAnd || key == groupOr {
+			items, ok := value.([]interface{})
+			if !ok {
+				return nil, invalidFilter("%s must be an array of objects", key)
+			}
+			sub := &filterGroup{or: key == groupOr}
+			for _, item := range items {
+				o, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, invalidFilter("%s must be an array of objects", key)
+				}
+				f, err := parseGroup(o, prefix, false, depth+1, count)
+				if err != nil {
+					return nil, err
+				}
+				sub.items = append(sub.items, f)
+			}
+			g.items = append(g.items, sub)
+			continue
+		}
+
+		path := append(append([]string{}, prefix...), strings.Split(key, ".")...)
+		f, err := parseField(path, value, depth, count)
+		if err != nil {
+			return nil, err
+		}
+		g.items = append(g.items, f)
+	}
+
+	return g, nil
+}
+
+func parseField(path []string, value interface{}, depth int, count *int) (Filter, error) {
+	obj, isObject := value.(map[string]interface{})
+	if isObject && !hasOperator(obj) {
+		return parseGroup(obj, path, false, depth+1, count)
+	}
+
+	*count++
+	if *count > maxFilterConditions {
+		return nil, invalidFilter("search has more than %d conditions", maxFilterConditions)
+	}
+
+	if !isObject {
+		switch v := value.(type) {
+		case string:
+			if v == "not_null" {
+				return &filterCondition{path: path, op: opIsNull, value: false}, nil
+			}
+			return &filterCondition{path: path, op: opLike, value: v}, nil
+		case nil:
+			return &filterCondition{path: path, op: opIsNull, value: true}, nil
+		case []interface{}:
+			return &filterCondition{path: path, op: opIn, value: v}, nil
+		default:
+			return &filterCondition{path: path, op: opEq, value: v}, nil
+		}
+	}
+
+	g := &filterGroup{}
+	for op, v := range obj {
+		if !isOperator(op) {
+			return nil, invalidFilter("unknown operator %s for %s", op, strings.Join(path, "."))
+		}
+		g.items = append(g.items, &filterCondition{path: path, op: op, value: v})
+	}
+	if len(g.items) == 1 {
+		return g.items[0], nil
+	}
+	sort.Slice(g.items, func(i, j int) bool {
+		return g.items[i].(*filterCondition).op < g.items[j].(*filterCondition).op
+	})
+	return g, nil
+}
+
+func isOperator(op string) bool {
+	if _, ok := operators[op]; ok {
+		return true
+	}
+	switch op {
+	case opIn, opNotIn, opLike, opBetween, opIsNull:
+		return true
+	}
+	return false
+}
+
+func hasOperator(obj map[string]interface{}) bool {
+	for k := range obj {
+		if isOperator(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCompiler превращает дерево фильтра в параметризованное условие SQL.
+type filterCompiler struct {
+	vars    []interface{}
+	aliases int
+}
+
+func (c *filterCompiler) bind(v interface{}) string {
+	c.vars = append(c.vars, v)
+	return "?"
+}
+
+func (c *filterCompiler) alias() string {
+	c.aliases++
+	return fmt.Sprintf("f%d", c.aliases)
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func column(table string, field *schema.Field) string {
+	return quote(table) + "." + quote(field.DBName)
+}
+
+func (g *filterGroup) compile(c *filterCompiler, s *schema.Schema, table string) (string, error) {
+	parts := make([]string, 0, len(g.items))
+	for _, item := range g.items {
+		sql, err := item.compile(c, s, table)
+		if err != nil {
+			return "", err
+		}
+		if sql != "" {
+			parts = append(parts, sql)
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return "", nil
+	case 1:
+		return parts[0], nil
+	}
+
+	sep := " AND "
+	if g.or {
+		sep = " OR "
+	}
+	return "(" + strings.Join(parts, sep) + ")", nil
+}
+
+func (f *filterCondition) compile(c *filterCompiler, s *schema.Schema, table string) (string, error) {
+	return f.compilePath(c, s, table, f.path)
+}
+
+func (f *filterCondition) compilePath(c *filterCompiler, s *schema.Schema, table string, path []string) (string, error) {
+	name := path[0]
+
+	if field := lookupField(s, name); field != nil {
+		switch {
+		case len(path) == 1:
+			return f.compileOperator(c, column(table, field), field)
+		case len(path) == 2 && isJsonField(field):
+			col := "(" + column(table, field) + " ->> " + c.bind(path[1]) + ")"
+			return f.compileOperator(c, col, nil)
+		}
+		return "", invalidFilter("%s is not a relation", strings.Join(f.path, "."))
+	}
+
+	rel := lookupRelation(s, name)
+	if rel == nil {
+		return "", invalidFilter("unknown field %s", strings.Join(f.path, "."))
+	}
+	if len(path) == 1 {
+		return "", invalidFilter("%s is a relation, a field of it is required", strings.Join(f.path, "."))
+	}
+
+	target := c.alias()
+	from, where := joinRelation(c, rel, table, target)
+
+	inner, err := f.compilePath(c, rel.FieldSchema, target, path[1:])
+	if err != nil {
+		return "", err
+	}
+	if inner != "" {
+		where = append(where, inner)
+	}
+
+	return "EXISTS (SELECT 1 FROM " + from + " WHERE " + strings.Join(where, " AND ") + ")", nil
+}
+
+// joinRelation возвращает части FROM и WHERE подзапроса EXISTS, связывающего
+// связанную таблицу с текущей. Значения привязываются раньше значений внутреннего условия.
+func joinRelation(c *filterCompiler, rel *schema.Relationship, table, target string) (string, []string) {
+	var from string
+	var where []string
+
+	if rel.JoinTable != nil {
+		join := c.alias()
+		var on []string
+		for _, ref := range rel.References {
+			if ref.PrimaryKey == nil {
+				continue
+			}
+			if ref.OwnPrimaryKey {
+				where = append(where, column(join, ref.ForeignKey)+" = "+column(table, ref.PrimaryKey))
+			} else {
+				on = append(on, column(target, ref.PrimaryKey)+" = "+column(join, ref.ForeignKey))
+			}
+		}
+		from = quote(rel.JoinTable.Table) + " AS " + quote(join) +
+			" JOIN " + quote(rel.FieldSchema.Table) + " AS " + quote(target) + " ON " + strings.Join(on, " AND ")
+	} else {
+		from = quote(rel.FieldSchema.Table) + " AS " + quote(target)
+		for _, ref := range rel.References {
+			switch {
+			case ref.PrimaryKey == nil:
+				where = append(where, column(target, ref.ForeignKey)+" = "+c.bind(ref.PrimaryValue))
+			case ref.OwnPrimaryKey:
+				where = append(where, column(target, ref.ForeignKey)+" = "+column(table, ref.PrimaryKey))
+			default:
+				where = append(where, column(target, ref.PrimaryKey)+" = "+column(table, ref.ForeignKey))
+			}
+		}
+	}
+
+	if deletedAt := rel.FieldSchema.LookUpField("deleted_at"); deletedAt != nil {
+		where = append(where, column(target, deletedAt)+" IS NULL")
+	}
+
+	return from, where
+}
+
+func (f *filterCondition) compileOperator(c *filterCompiler, col string, field *schema.Field) (string, error) {
+	name := strings.Join(f.path, ".")
+
+	if sqlOp, ok := operators[f.op]; ok {
+		if !isScalar(f.value) || f.value == nil {
+			return "", invalidFilter("%s %s needs a single value", name, f.op)
+		}
+		return col + " " + sqlOp + " " + c.bind(f.value), nil
+	}
+
+	switch f.op {
+	case opIn, opNotIn:
+		values, ok := f.value.([]interface{})
+		if !ok || len(values) == 0 {
+			return "", invalidFilter("%s %s needs a non-empty array", name, f.op)
+		}
+		for _, v := range values {
+			if !isScalar(v) || v == nil {
+				return "", invalidFilter("%s %s needs an array of values", name, f.op)
+			}
+		}
+		if f.op == opNotIn {
+			return col + " NOT IN " + c.bind(values), nil
+		}
+		return col + " IN " + c.bind(values), nil
+	case opLike:
+		v, ok := f.value.(string)
+		if !ok {
+			v = fmt.Sprintf("%v", f.value)
+		}
+		if field != nil && field.DataType != schema.String {
+			col = col + "::text"
+		}
+		return col + " ILIKE " + c.bind("%"+escapeLike(v)+"%"), nil
+	case opBetween:
+		values, ok := f.value.([]interface{})
+		if !ok || len(values) != 2 || !isScalar(values[0]) || !isScalar(values[1]) {
+			return "", invalidFilter("%s between needs an array of two values", name)
+		}
+		return col + " BETWEEN " + c.bind(values[0]) + " AND " + c.bind(values[1]), nil
+	case opIsNull:
+		isNull, ok := f.value.(bool)
+		if !ok {
+			return "", invalidFilter("%s is_null needs true or false", name)
+		}
+		if isNull {
+			return col + " IS NULL", nil
+		}
+		return col + " IS NOT NULL", nil
+	}
+
+	return "", invalidFilter("unknown operator %s for %s", f.op, name)
+}
+
+func lookupField(s *schema.Schema, name string) *schema.Field {
+	field := s.LookUpField(name)
+	if field == nil {
+		field = s.LookUpField(utils.ToSnakeCase(name))
+	}
+	if field == nil || field.DBName == "" || hiddenField(field) {
+		return nil
+	}
+	return field
+}
+
+// RelationSearcher реализуют модели, поиск по которым может заходить в их связи, например
+// {"roles": {"name": "admin"}}. Он перечисляет такие связи по именам полей; остальные связи,
+// как и все связи моделей без него, поиску неизвестны.
+type RelationSearcher interface {
+	SearchRelations() []string
+}
+
+// lookupRelation находит связь модели, открытую поиску через RelationSearcher.
+func lookupRelation(s *schema.Schema, name string) *schema.Relationship {
+	searcher, ok := reflect.New(s.ModelType).Interface().(RelationSearcher)
+	if !ok {
+		return nil
+	}
+
+	for _, key := range searcher.SearchRelations() {
+		rel, ok := s.Relationships.Relations[key]
+		if !ok || hiddenField(rel.Field) {
+			continue
+		}
+		if strings.EqualFold(key, name) || utils.ToSnakeCase(key) == name {
+			return rel
+		}
+	}
+	return nil
+}
+
+func isJsonField(field *schema.Field) bool {
+	if strings.Contains(strings.ToLower(string(field.DataType)), "json") {
+		return true
+	}
+	t := field.FieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.Contains(strings.ToLower(t.String()), "json")
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, float64, bool, json.Number:
+		return true
+	}
+	return false
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,238 @@
+package base_postgres
+
+import (
+	"application_template/utils"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type filterUser struct {
+	Entity
+	UserName     string
+	UserPassword string
+	Token        string `json:"-"`
+	Active       bool
+	Roles        []filterRole `gorm:"many2many:filter_user_roles"`
+	Manager      *filterUser  `gorm:"foreignKey:IdManager"`
+	IdManager    *uint
+}
+
+func (filterUser) SearchRelations() []string {
+	return []string{"Roles"}
+}
+
+type filterRole struct {
+	Entity
+	Name   string
+	Title  json.RawMessage `gorm:"type:jsonb"`
+	Users  []filterUser    `gorm:"many2many:filter_user_roles"`
+	Owner  *filterUser     `gorm:"foreignKey:IdUser" json:"-"`
+	IdUser uint
+}
+
+func (filterRole) SearchRelations() []string {
+	return []string{"Users", "Owner"}
+}
+
+func filterSchema(t *testing.T, model interface{}) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func compileFilter(t *testing.T, s *schema.Schema, search string) (string, []interface{}, error) {
+	t.Helper()
+	filter, err := ParseFilter([]byte(search))
+	if err != nil {
+		return "", nil, err
+	}
+	c := &filterCompiler{}
+	query, err := filter.compile(c, s, s.Table)
+	return query, c.vars, err
+}
+
+func TestFilterCompile(t *testing.T) {
+	users := filterSchema(t, &filterUser{})
+
+	tests := []struct {
+		name   string
+		search string
+		query  string
+		vars   []interface{}
+	}{
+		{
+			name:   "bare string is like",
+			search: `{"user_name": "adm"}`,
+			query:  `"filter_users"."user_name" ILIKE ?`,
+			vars:   []interface{}{"%adm%"},
+		},
+		{
+			name:   "like escapes wildcards",
+			search: `{"user_name": {"like": "50%_\\"}}`,
+			query:  `"filter_users"."user_name" ILIKE ?`,
+			vars:   []interface{}{`%50\%\_\\%`},
+		},
+		{
+			name:   "bare value is eq",
+			search: `{"active": true}`,
+			query:  `"filter_users"."active" = ?`,
+			vars:   []interface{}{true},
+		},
+		{
+			name:   "camel case field",
+			search: `{"userName": {"eq": "admin"}}`,
+			query:  `"filter_users"."user_name" = ?`,
+			vars:   []interface{}{"admin"},
+		},
+		{
+			name:   "null and not_null",
+			search: `{"deleted_at": null, "updated_at": "not_null"}`,
+			query:  `("filter_users"."deleted_at" IS NULL AND "filter_users"."updated_at" IS NOT NULL)`,
+		},
+		{
+			name:   "in and between",
+			search: `{"id": {"in": [1, 2]}, "created_at": {"between": ["2023-01-01", "2023-02-01"]}}`,
+			query:  `("filter_users"."created_at" BETWEEN ? AND ? AND "filter_users"."id" IN ?)`,
+			vars:   []interface{}{"2023-01-01", "2023-02-01", []interface{}{1.0, 2.0}},
+		},
+		{
+			name:   "several operators on a field",
+			search: `{"id": {"gte": 1, "lt": 10}}`,
+			query:  `("filter_users"."id" >= ? AND "filter_users"."id" < ?)`,
+			vars:   []interface{}{1.0, 10.0},
+		},
+		{
+			name:   "or group",
+			search: `{"or": [{"id": 1}, {"user_name": {"eq": "admin"}}]}`,
+			query:  `("filter_users"."id" = ? OR "filter_users"."user_name" = ?)`,
+			vars:   []interface{}{1.0, "admin"},
+		},
+		{
+			name:   "like on a non text column",
+			search: `{"id": {"like": 12}}`,
+			query:  `"filter_users"."id"::text ILIKE ?`,
+			vars:   []interface{}{"%12%"},
+		},
+		{
+			name:   "relation",
+			search: `{"roles": {"name": {"eq": "admin"}}}`,
+			query: `EXISTS (SELECT 1 FROM "filter_user_roles" AS "f2" JOIN "filter_roles" AS "f1" ON "f1"."id" = "f2"."filter_role_id" ` +
+				`WHERE "f2"."filter_user_id" = "filter_users"."id" AND "f1"."deleted_at" IS NULL AND "f1"."name" = ?)`,
+			vars: []interface{}{"admin"},
+		},
+		{
+			name:   "relation path",
+			search: `{"roles.name": {"eq": "admin"}}`,
+			query: `EXISTS (SELECT 1 FROM "filter_user_roles" AS "f2" JOIN "filter_roles" AS "f1" ON "f1"."id" = "f2"."filter_role_id" ` +
+				`WHERE "f2"."filter_user_id" = "filter_users"."id" AND "f1"."deleted_at" IS NULL AND "f1"."name" = ?)`,
+			vars: []interface{}{"admin"},
+		},
+		{
+			name:   "json field with a language",
+			search: `{"roles": {"title.en": {"eq": "Admin"}}}`,
+			query: `EXISTS (SELECT 1 FROM "filter_user_roles" AS "f2" JOIN "filter_roles" AS "f1" ON "f1"."id" = "f2"."filter_role_id" ` +
+				`WHERE "f2"."filter_user_id" = "filter_users"."id" AND "f1"."deleted_at" IS NULL AND ("f1"."title" ->> ?) = ?)`,
+			vars: []interface{}{"en", "Admin"},
+		},
+		{
+			name:   "values stay out of the query",
+			search: `{"user_name": {"eq": "x' OR '1'='1"}}`,
+			query:  `"filter_users"."user_name" = ?`,
+			vars:   []interface{}{"x' OR '1'='1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, vars, err := compileFilter(t, users, tt.search)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.query {
+				t.Errorf("query\n got %s\nwant %s", query, tt.query)
+			}
+			if len(vars) != 0 || len(tt.vars) != 0 {
+				if !reflect.DeepEqual(vars, tt.vars) {
+					t.Errorf("vars = %#v, want %#v", vars, tt.vars)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterReject(t *testing.T) {
+	users := filterSchema(t, &filterUser{})
+	roles := filterSchema(t, &filterRole{})
+
+	deep := `{"id": 1}`
+	for i := 0; i <= maxFilterDepth; i++ {
+		deep = `{"and": [` + deep + `]}`
+	}
+	many := make([]string, 0, maxFilterConditions+1)
+	for i := 0; i <= maxFilterConditions; i++ {
+		many = append(many, `{"id": 1}`)
+	}
+
+	tests := []struct {
+		name   string
+		schema *schema.Schema
+		search string
+		reason string
+	}{
+		{"not json", users, `user_name=admin`, "search must be a JSON object"},
+		{"not an object", users, `[1, 2]`, "search must be a JSON object"},
+		{"unknown field", users, `{"nickname": "adm"}`, "unknown field nickname"},
+		{"quote in a field name", users, `{"user_name\" OR 1=1 --": "x"}`, `unknown field user_name" OR 1=1 --`},
+		{"sql in a field name", users, `{"id = id; drop table users; --": 1}`, "unknown field id = id; drop table users; --"},
+		{"unknown operator", users, `{"id": {"eq": 1, "; drop": 2}}`, "unknown operator ; drop for id"},
+		{"object on a plain field", users, `{"user_name": {"raw": "1=1"}}`, "user_name.raw is not a relation"},
+		{"password", users, `{"user_password": {"like": "$2a$"}}`, "unknown field user_password"},
+		{"password in camel case", users, `{"UserPassword": "$2a$"}`, "unknown field UserPassword"},
+		{"field hidden from json", users, `{"token": "abc"}`, "unknown field token"},
+		{"password through a relation", roles, `{"users": {"user_password": {"like": "$2a$"}}}`, "unknown field users.user_password"},
+		{"password through a relation path", roles, `{"users.user_password": "$2a$"}`, "unknown field users.user_password"},
+		{"password through nested relations", users, `{"roles": {"users": {"user_password": "$2a$"}}}`, "unknown field roles.users.user_password"},
+		{"password in a group", users, `{"or": [{"id": 1}, {"user_password": "$2a$"}]}`, "unknown field user_password"},
+		{"relation not searchable", users, `{"manager": {"user_name": "adm"}}`, "unknown field manager.user_name"},
+		{"relation path not searchable", users, `{"manager.user_name": "adm"}`, "unknown field manager.user_name"},
+		{"relation hidden from json", roles, `{"owner": {"user_name": "adm"}}`, "unknown field owner.user_name"},
+		{"relation without a field", users, `{"roles": {}}`, ""},
+		{"group of values", users, `{"or": [1, 2]}`, "or must be an array of objects"},
+		{"eq with an array", users, `{"id": {"eq": [1]}}`, "id eq needs a single value"},
+		{"eq with an object", users, `{"id": {"eq": {"lt": 1}}}`, "id eq needs a single value"},
+		{"empty in", users, `{"id": {"in": []}}`, "id in needs a non-empty array"},
+		{"nested in", users, `{"id": {"in": [[1]]}}`, "id in needs an array of values"},
+		{"short between", users, `{"id": {"between": [1]}}`, "id between needs an array of two values"},
+		{"is_null with a string", users, `{"id": {"is_null": "yes"}}`, "id is_null needs true or false"},
+		{"too deep", users, deep, "search is nested deeper than 6 levels"},
+		{"too many conditions", users, `{"or": [` + strings.Join(many, ",") + `]}`, "search has more than 50 conditions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := compileFilter(t, tt.schema, tt.search)
+			if tt.reason == "" {
+				if err != nil || query != "" {
+					t.Fatalf("query = %q, err = %v; want neither", query, err)
+				}
+				return
+			}
+
+			var localized *utils.LocalizeError
+			if !errors.As(err, &localized) || localized.Message != "exception:invalid-search" {
+				t.Fatalf("err = %v, want exception:invalid-search", err)
+			}
+			var reason filterError
+			if !errors.As(err, &reason) || reason.reason != tt.reason {
+				t.Errorf("reason = %q, want %q", reason.reason, tt.reason)
+			}
+		})
+	}
+}
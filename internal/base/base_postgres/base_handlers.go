@@ -2,6 +2,8 @@ package base_postgres
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -27,17 +29,34 @@ type CrudInterface interface {
 	Create(ctx *gin.Context) *AppError
 	Update(ctx *gin.Context) *AppError
 	Delete(ctx *gin.Context) *AppError
+	GetExcel(ctx *gin.Context) *AppError
+	Import(ctx *gin.Context) *AppError
+
+	FindAllDeleted(ctx *gin.Context) *AppError
+	FindOneDeleted(ctx *gin.Context) *AppError
+	Recover(ctx *gin.Context) *AppError
+	Purge(ctx *gin.Context) *AppError
 }
 
 type RedisInterface interface {
 	KeyAll() string
 	KeyOne(id string) string
+	// KeyList возвращает ключ запрошенного списка, пустой, чтобы не кешировать его.
+	KeyList(c *gin.Context) string
+	ListTTL() time.Duration
 }
 
 type CrudController struct {
 	CrudInterface  CrudInterface
 	ModelInterface ModelInterface
 	Service        CrudServiceInterface
+	Middlewares    []gin.HandlerFunc
+
+	// ListCacheTTL - время жизни списка в кеше: 0 берёт TTL кеша, отрицательное значение отключает кеширование списков.
+	ListCacheTTL time.Duration
+	// CacheScope различает вызывающих, которые могут видеть разные списки по одному запросу.
+	// По умолчанию у каждого пользователя свои закешированные списки.
+	CacheScope func(c *gin.Context) string
 }
 
 func NewCrudController() *CrudController {
@@ -48,12 +67,20 @@ func NewCrudController() *CrudController {
 }
 
 func (cc *CrudController) Register(r *gin.RouterGroup, s string) *gin.RouterGroup {
-	g := r.Group(s)
+	g := r.Group(s, cc.Middlewares...)
 	g.GET("", AppHandler(cc.CrudInterface.FindAll).Handle)
+	g.GET("export", AppHandler(cc.CrudInterface.GetExcel).Handle)
 	g.GET(":id", AppHandler(cc.CrudInterface.FindOne).Handle)
 	g.POST("", AppHandler(cc.CrudInterface.Create).Handle)
+	g.POST("import", AppHandler(cc.CrudInterface.Import).Handle)
+	g.PUT(":id", AppHandler(cc.CrudInterface.Update).Handle)
 	g.PATCH(":id", AppHandler(cc.CrudInterface.Update).Handle)
 	g.DELETE(":id", AppHandler(cc.CrudInterface.Delete).Handle)
+
+	g.GET("trash", AppHandler(cc.CrudInterface.FindAllDeleted).Handle)
+	g.GET("trash/:id", AppHandler(cc.CrudInterface.FindOneDeleted).Handle)
+	g.POST(":id/restore", AppHandler(cc.CrudInterface.Recover).Handle)
+	g.DELETE(":id/purge", AppHandler(cc.CrudInterface.Purge).Handle)
 	return g
 }
 
@@ -89,6 +116,36 @@ func (cc *CrudController) Delete(ctx *gin.Context) *AppError {
 	return ct.Delete(ctx, cc.Service)
 }
 
+func (cc *CrudController) GetExcel(ctx *gin.Context) *AppError {
+	ct := NewCrudTemplate(cc)
+	return ct.GetExcel(ctx, cc.Service)
+}
+
+func (cc *CrudController) Import(ctx *gin.Context) *AppError {
+	ct := NewCrudTemplate(cc)
+	return ct.Import(ctx, cc.Service)
+}
+
+func (cc *CrudController) FindAllDeleted(ctx *gin.Context) *AppError {
+	ct := NewCrudTemplate(cc)
+	return ct.FindAllDeleted(ctx, cc.Service)
+}
+
+func (cc *CrudController) FindOneDeleted(ctx *gin.Context) *AppError {
+	ct := NewCrudTemplate(cc)
+	return ct.FindOneDeleted(ctx, cc.Service)
+}
+
+func (cc *CrudController) Recover(ctx *gin.Context) *AppError {
+	ct := NewCrudTemplate(cc)
+	return ct.Recover(ctx, cc.Service)
+}
+
+func (cc *CrudController) Purge(ctx *gin.Context) *AppError {
+	ct := NewCrudTemplate(cc)
+	return ct.Purge(ctx, cc.Service)
+}
+
 func (cc *CrudController) KeyAll() string {
 	return fmt.Sprintf("%T:all", cc.CrudInterface)
 }
@@ -96,3 +153,17 @@ func (cc *CrudController) KeyAll() string {
 func (cc *CrudController) KeyOne(id string) string {
 	return fmt.Sprintf("%T:%s", cc.CrudInterface, id)
 }
+
+func (cc *CrudController) KeyList(c *gin.Context) string {
+	var scope string
+	if cc.CacheScope != nil {
+		scope = cc.CacheScope(c)
+	} else if idUser, exists := c.Get("id_user"); exists {
+		scope = fmt.Sprint(idUser)
+	}
+	return fmt.Sprintf("%T:list:%s", cc.CrudInterface, QueryHash(c, scope))
+}
+
+func (cc *CrudController) ListTTL() time.Duration {
+	return cc.ListCacheTTL
+}
@@ -0,0 +1,341 @@
+package base_postgres
+
+import (
+	"application_template/internal/database/connect"
+	"application_template/utils"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	importMaxRows  = 10000
+	importMaxBytes = 20 << 20
+)
+
+var importTimeFormats = []string{exportTimeFormat, time.RFC3339, "2006-01-02"}
+
+// Upserter реализуют модели, импортируемые с upsert=true. Он называет уникальное ограничение,
+// по которому импортированная строка конфликтует с сохранённой.
+type Upserter interface {
+	UpsertConstraint() string
+}
+
+type ImportError struct {
+	Row       int
+	Error     string
+	Message   string
+	Detailed  string
+	FieldName string
+}
+
+type ImportReport struct {
+	Total    int
+	Imported int
+	Failed   int
+	Errors   []ImportError
+}
+
+// importColumns сопоставляет полям импортируемых полей их имена колонок, полей и JSON в нижнем
+// регистре и локализованные заголовки экспорта. Ключи, метки времени и поля только для чтения исключаются.
+func importColumns(c *gin.Context, s *schema.Schema, readOnly []string) map[string]*schema.Field {
+	skip := map[string]bool{}
+	for _, name := range readOnly {
+		skip[name] = true
+	}
+
+	columns := map[string]*schema.Field{}
+	for _, field := range ExportColumns(s) {
+		if field.PrimaryKey || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 || field.DBName == "deleted_at" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if skip[field.Name] || (jsonName != "" && skip[jsonName]) {
+			continue
+		}
+
+		for _, name := range []string{field.DBName, field.Name, jsonName, exportHeader(c, s.Table, field)} {
+			if name != "" {
+				columns[strings.ToLower(strings.TrimSpace(name))] = field
+			}
+		}
+	}
+	return columns
+}
+
+// readImport возвращает строки загруженного файла CSV или XLSX, первой - строку заголовков.
+func readImport(file io.Reader, format string) ([][]string, error) {
+	if format == formatCSV {
+		r := csv.NewReader(file)
+		r.FieldsPerRecord = -1
+		var rows [][]string
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				return rows, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if rows = append(rows, record); len(rows) > importMaxRows+1 {
+				return nil, errTooManyRows
+			}
+		}
+	}
+
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	it, err := f.Rows(f.GetSheetName(0))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var rows [][]string
+	for it.Next() {
+		record, err := it.Columns()
+		if err != nil {
+			return nil, err
+		}
+		if rows = append(rows, record); len(rows) > importMaxRows+1 {
+			return nil, errTooManyRows
+		}
+	}
+	return rows, it.Error()
+}
+
+var errTooManyRows = errors.New("too many rows")
+
+// importValue декодирует ячейку в тип поля через JSON, чтобы типы со своим
+// разбором декодировались так же, как в JSON теле.
+func importValue(field *schema.Field, cell string) (interface{}, error) {
+	target := reflect.New(field.FieldType)
+
+	t := field.FieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var raw []byte
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		for _, layout := range importTimeFormats {
+			if parsed, err := time.ParseInLocation(layout, cell, time.Local); err == nil {
+				raw, _ = json.Marshal(parsed)
+				break
+			}
+		}
+		if raw == nil {
+			return nil, errors.New("invalid time " + cell)
+		}
+	case t.Kind() == reflect.String:
+		raw, _ = json.Marshal(cell)
+	case isJsonField(field) && json.Valid([]byte(cell)):
+		if err := json.Unmarshal([]byte(cell), target.Interface()); err == nil {
+			return target.Elem().Interface(), nil
+		}
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && field.FieldType == t {
+			return reflect.ValueOf([]byte(cell)).Convert(t).Interface(), nil
+		}
+		raw = []byte(cell)
+	case json.Valid([]byte(cell)):
+		raw = []byte(cell)
+		if err := json.Unmarshal(raw, target.Interface()); err == nil {
+			return target.Elem().Interface(), nil
+		}
+		raw, _ = json.Marshal(cell)
+	default:
+		raw, _ = json.Marshal(cell)
+	}
+
+	if err := json.Unmarshal(raw, target.Interface()); err != nil {
+		return nil, err
+	}
+	return target.Elem().Interface(), nil
+}
+
+func importError(c *gin.Context, row int, field *schema.Field, err error, message string) ImportError {
+	return ImportError{
+		Row:   row,
+		Error: err.Error(),
+		Message: utils.Localize(c, message, map[string]interface{}{
+			"Field": exportHeader(c, field.Schema.Table, field),
+		}),
+		FieldName: field.Name,
+	}
+}
+
+// ImportFunc читает файл CSV или XLSX из поля формы "file" в модели, строка заголовков называет
+// поля. Строки, не прошедшие декодирование или проверку, попадают в отчёт и пропускаются, остальные
+// сохраняет функция импорта, с upsert=true - по ограничению модели.
+func (ct *CrudTemplate) ImportFunc(c *gin.Context, imp Import) *AppError {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, importMaxBytes)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return ErrBadRequest(c, errors.New("exception:import-file-required"), nil)
+	}
+
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		return ErrBadRequest(c, errors.New("exception:import-unsupported-format"), nil)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return LocalizeError(c, err)
+	}
+	defer file.Close()
+
+	records, err := readImport(file, format)
+	if err != nil {
+		if errors.Is(err, errTooManyRows) {
+			return ErrBadRequest(c, errors.New("exception:import-too-many-rows"), map[string]interface{}{
+				"Max": importMaxRows,
+			})
+		}
+		return ErrBadRequest(c, errors.New("exception:import-unreadable-file"), nil)
+	}
+	if len(records) < 2 {
+		return ErrBadRequest(c, errors.New("exception:import-no-rows"), nil)
+	}
+
+	one := ct.mi.GetOne()
+	st := &gorm.Statement{DB: connect.PostgresDB}
+	if err := st.Parse(one); err != nil {
+		return LocalizeError(c, err)
+	}
+
+	var conflict *Conflict
+	if c.Query("upsert") == "true" {
+		upserter, ok := one.(Upserter)
+		if !ok {
+			return ErrBadRequest(c, errors.New("exception:import-upsert-unsupported"), nil)
+		}
+		conflict = &Conflict{Constraint: upserter.UpsertConstraint()}
+	}
+
+	available := importColumns(c, st.Schema, one.GetReadOnlyFields())
+	fields := make([]*schema.Field, len(records[0]))
+	mapped := 0
+	for i, name := range records[0] {
+		if field, ok := available[strings.ToLower(strings.TrimSpace(name))]; ok {
+			fields[i] = field
+			mapped++
+			if conflict != nil {
+				conflict.Columns = append(conflict.Columns, field.DBName)
+			}
+		}
+	}
+	if mapped == 0 {
+		return ErrBadRequest(c, errors.New("exception:import-no-columns"), nil)
+	}
+	if conflict != nil {
+		if updatedAt := st.Schema.LookUpField("updated_at"); updatedAt != nil {
+			conflict.Columns = append(conflict.Columns, updatedAt.DBName)
+		}
+	}
+
+	report := ImportReport{Total: len(records) - 1}
+	rows := make([]HasId, 0, len(records)-1)
+	numbers := make([]int, 0, len(records)-1)
+
+	for i, record := range records[1:] {
+		number := i + 2
+		row := ct.mi.GetOne()
+		value := reflect.ValueOf(row)
+
+		var rowErrors []ImportError
+		for j, cell := range record {
+			if j >= len(fields) || fields[j] == nil || strings.TrimSpace(cell) == "" {
+				continue
+			}
+			v, err := importValue(fields[j], strings.TrimSpace(cell))
+			if err == nil {
+				err = fields[j].Set(c, value, v)
+			}
+			if err != nil {
+				rowErrors = append(rowErrors, importError(c, number, fields[j], err, "exception:import-invalid-value"))
+			}
+		}
+
+		if len(rowErrors) == 0 {
+			var invalid validator.ValidationErrors
+			if err := binding.Validator.ValidateStruct(row); errors.As(err, &invalid) {
+				for _, fe := range invalid {
+					rowErrors = append(rowErrors, ImportError{
+						Row:       number,
+						Error:     fe.Error(),
+						Message:   validationMessage(c, fe),
+						FieldName: fe.StructField(),
+					})
+				}
+			} else if err != nil {
+				rowErrors = append(rowErrors, ImportError{Row: number, Error: err.Error(), Message: utils.DefaultError(c)})
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			report.Failed++
+			report.Errors = append(report.Errors, rowErrors...)
+			continue
+		}
+		rows = append(rows, row)
+		numbers = append(numbers, number)
+	}
+
+	errs, err := imp(rows, conflict)
+	if err != nil {
+		return LocalizeError(c, err)
+	}
+	for i, err := range errs {
+		if err == nil {
+			report.Imported++
+			continue
+		}
+		appErr := LocalizeError(c, err)
+		report.Failed++
+		report.Errors = append(report.Errors, ImportError{
+			Row:       numbers[i],
+			Error:     appErr.Error,
+			Message:   appErr.Message,
+			Detailed:  appErr.Detailed,
+			FieldName: appErr.FieldName,
+		})
+	}
+
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+
+	if report.Imported > 0 {
+		ct.invalidate(c)
+	}
+
+	return Ok(c, report)
+}
+
+func (ct *CrudTemplate) Import(c *gin.Context, impInter ImportInterface) *AppError {
+	return ct.ImportFunc(c, impInter.Import)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -15,7 +16,16 @@ type HasId interface {
 	GetReadOnlyFields() []string
 }
 
-type Entity gorm.Model
+// Entity хранит колонки gorm.Model.
+type Entity struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	// expected - версии, которые принимает условная запись, пусто для безусловной.
+	expected []time.Time
+}
 
 func (e *Entity) GetId() uint {
 	return e.ID
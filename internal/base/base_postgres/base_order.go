@@ -3,14 +3,21 @@ package base_postgres
 import (
 	"application_template/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 	"strings"
 )
 
 type OrderFilter interface {
 	processValues(db *gorm.DB) []string
+	columns(db *gorm.DB) []orderColumn
 	sort() func(db *gorm.DB) *gorm.DB
 }
 
+type orderColumn struct {
+	name string
+	desc bool
+}
+
 type Order struct {
 	Values []string
 	Model  HasId
@@ -23,21 +30,43 @@ func NewOrder(values []string, model HasId) *Order {
 	}
 }
 
-func (o *Order) processValues(db *gorm.DB) []string {
-	var results []string
-	var order string = "asc"
+// columns возвращает запрошенные колонки сортировки, которые есть в схеме модели. Скрытые
+// от JSON поля и пароли сортировке недоступны: порядок строк выдал бы их значения.
+func (o *Order) columns(db *gorm.DB) []orderColumn {
+	st := &gorm.Statement{DB: db}
+	if err := st.Parse(o.Model); err != nil {
+		return nil
+	}
+	return orderColumns(o.Values, st.Schema)
+}
 
-	for _, value := range o.Values {
+func orderColumns(values []string, s *schema.Schema) []orderColumn {
+	var results []orderColumn
+
+	for _, value := range values {
 		attribute := utils.ToSnakeCase(value)
+		desc := strings.HasPrefix(attribute, "-")
+		attribute = strings.TrimPrefix(attribute, "-")
 
-		if strings.HasPrefix(attribute, "-") {
-			order = "desc"
-			attribute = strings.TrimPrefix(attribute, "-")
+		field := s.LookUpField(attribute)
+		if field == nil || field.DBName == "" || hiddenField(field) {
+			continue
 		}
+		results = append(results, orderColumn{name: field.DBName, desc: desc})
+	}
+
+	return results
+}
 
-		if db.Migrator().HasColumn(o.Model, attribute) {
-			results = append(results, strings.Join([]string{attribute, order}, " "))
+func (o *Order) processValues(db *gorm.DB) []string {
+	var results []string
+
+	for _, column := range o.columns(db) {
+		order := "asc"
+		if column.desc {
+			order = "desc"
 		}
+		results = append(results, strings.Join([]string{column.name, order}, " "))
 	}
 
 	return results
@@ -0,0 +1,33 @@
+package base_postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderColumns(t *testing.T) {
+	users := filterSchema(t, &filterUser{})
+
+	tests := []struct {
+		name   string
+		values []string
+		want   []orderColumn
+	}{
+		{"ascending", []string{"user_name"}, []orderColumn{{name: "user_name"}}},
+		{"descending", []string{"-id"}, []orderColumn{{name: "id", desc: true}}},
+		{"field name", []string{"UserName"}, []orderColumn{{name: "user_name"}}},
+		{"several", []string{"active", "-created_at"}, []orderColumn{{name: "active"}, {name: "created_at", desc: true}}},
+		{"unknown column", []string{"nickname"}, nil},
+		{"password", []string{"user_password"}, nil},
+		{"field hidden from json", []string{"-token"}, nil},
+		{"relation", []string{"roles"}, nil},
+		{"sql", []string{"id; drop table users"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orderColumns(tt.values, users); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,12 +6,14 @@ type Pager interface {
 	getPage() int
 	getPageSize() int
 	getOffset() int
+	skipCount() bool
 
 	paginate() func(db *gorm.DB) *gorm.DB
 }
 
 type Page struct {
 	page, pageSize, offset int
+	noCount                bool
 }
 
 func NewPager(page, pageSize, offset int) Pager {
@@ -34,8 +36,25 @@ func (p *Page) getOffset() int {
 	return p.offset
 }
 
+func (p *Page) skipCount() bool {
+	return p.noCount
+}
+
 func (p *Page) paginate() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
 		return db.Offset(p.getOffset()).Limit(p.getPageSize())
 	}
 }
+
+// findPage читает одну страницу a в срез, по смещению или по курсору в зависимости от пейджера.
+func findPage(db *gorm.DB, p Pager, o OrderFilter, a interface{}, scopes ...func(*gorm.DB) *gorm.DB) error {
+	if cp, ok := p.(CursorPager); ok {
+		return cp.find(db, o, a, scopes...)
+	}
+
+	scopes = append([]func(*gorm.DB) *gorm.DB{p.paginate(), o.sort()}, scopes...)
+	if res := db.Scopes(scopes...).Find(a); res.Error != nil {
+		return res.Error
+	}
+	return nil
+}
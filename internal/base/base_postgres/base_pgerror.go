@@ -0,0 +1,115 @@
+package base_postgres
+
+import (
+	"application_template/utils"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды SQLSTATE ошибок Postgres, которые разбирает приложение.
+const (
+	PgUniqueViolation      = "23505"
+	PgForeignKeyViolation  = "23503"
+	PgNotNullViolation     = "23502"
+	PgInvalidText          = "22P02"
+	PgSerializationFailure = "40001"
+	PgDeadlockDetected     = "40P01"
+)
+
+const serializationRetryAfter = time.Second
+
+// PgCode возвращает код SQLSTATE ошибки Postgres, пустой для любой другой ошибки.
+func PgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+var (
+	// pgDetailKey находит первую колонку ключа в detail нарушения ограничения,
+	// например Key (user_name)=(admin) already exists.
+	pgDetailKey = regexp.MustCompile(`Key \(([^,)]+)`)
+	// pgDetailTable находит другую таблицу в detail нарушения внешнего ключа, например
+	// Key (id_role)=(7) is not present in table "roles".
+	pgDetailTable = regexp.MustCompile(`table "([^"]+)"`)
+)
+
+// pgError превращает ошибки Postgres, которые клиент может вызвать или повторить, в собственные
+// ошибки, nil для любой другой ошибки.
+func pgError(ctx *gin.Context, err error) *AppError {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+
+	appErr := &AppError{
+		Error:    err.Error(),
+		Detailed: pgErr.Detail,
+	}
+	data := map[string]interface{}{
+		"Table": pgErr.TableName,
+	}
+
+	switch pgErr.Code {
+	case PgUniqueViolation:
+		column := pgColumn(pgErr.Detail)
+		appErr.Code = http.StatusConflict
+		appErr.Type = "exception:unique-violation"
+		appErr.FieldName = toCamelCase(column)
+		data["Field"] = columnName(ctx, pgErr.TableName, column)
+	case PgForeignKeyViolation:
+		column := pgColumn(pgErr.Detail)
+		data["Field"] = columnName(ctx, pgErr.TableName, column)
+		if match := pgDetailTable.FindStringSubmatch(pgErr.Detail); match != nil {
+			data["Reference"] = match[1]
+		}
+		// Ссылка на отсутствующую запись необрабатываема, удаление записи, на которую ссылаются, - конфликт.
+		if strings.Contains(pgErr.Detail, "is not present in table") {
+			appErr.Code = http.StatusUnprocessableEntity
+			appErr.Type = "exception:foreign-key-missing"
+			appErr.FieldName = toCamelCase(column)
+		} else {
+			appErr.Code = http.StatusConflict
+			appErr.Type = "exception:foreign-key-referenced"
+		}
+	case PgNotNullViolation:
+		appErr.Code = http.StatusUnprocessableEntity
+		appErr.Type = "exception:not-null-violation"
+		appErr.FieldName = toCamelCase(pgErr.ColumnName)
+		data["Field"] = columnName(ctx, pgErr.TableName, pgErr.ColumnName)
+	case PgInvalidText:
+		appErr.Code = http.StatusBadRequest
+		appErr.Type = "exception:invalid-input"
+	case PgSerializationFailure, PgDeadlockDetected:
+		appErr.Code = http.StatusServiceUnavailable
+		appErr.Type = "exception:serialization-failure"
+		appErr.RetryAfter = serializationRetryAfter
+		data["Seconds"] = int(serializationRetryAfter.Seconds())
+	default:
+		return nil
+	}
+
+	appErr.Message = utils.Localize(ctx, appErr.Type, data)
+	return appErr
+}
+
+// pgColumn возвращает первую колонку ключа в detail нарушения ограничения.
+func pgColumn(detail string) string {
+	if match := pgDetailKey.FindStringSubmatch(detail); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// columnName локализует колонку так же, как заголовки экспорта.
+func columnName(ctx *gin.Context, table, column string) string {
+	return utils.LocalizeOr(ctx, column, nil, "column:"+table+"."+column, "column:"+column)
+}
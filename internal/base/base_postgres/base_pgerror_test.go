@@ -0,0 +1,60 @@
+package base_postgres
+
+import (
+	"application_template/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestLocalizeErrorPostgres(t *testing.T) {
+	unique := &pgconn.PgError{Code: PgUniqueViolation, TableName: "roles", Detail: "Key (name)=(admin) already exists."}
+	serialization := &pgconn.PgError{Code: PgSerializationFailure}
+	other := &pgconn.PgError{Code: "XX000", Detail: "Key (name)=(admin) internal"}
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+		typ  string
+	}{
+		{"unique violation", unique, http.StatusConflict, "exception:unique-violation"},
+		{"wrapped unique violation", fmt.Errorf("save: %w", unique), http.StatusConflict, "exception:unique-violation"},
+		{"localized unique violation", utils.NewLocalizeError(unique, "exception:failed-to-create-record", nil), http.StatusConflict, "exception:unique-violation"},
+		{"localized serialization failure", utils.NewLocalizeError(serialization, "exception:failed-to-update-record", nil), http.StatusServiceUnavailable, "exception:serialization-failure"},
+		{"localized unmapped error", utils.NewLocalizeError(other, "exception:failed-to-update-record", nil), http.StatusBadRequest, "exception:failed-to-update-record"},
+		{"localized error", utils.NewLocalizeError(nil, "exception:invalid-search", nil), http.StatusBadRequest, "exception:invalid-search"},
+		{"plain error", errors.New("boom"), http.StatusBadRequest, "exception:default-message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+			appErr := LocalizeError(ctx, tt.err)
+			if appErr.Code != tt.code || appErr.Type != tt.typ {
+				t.Errorf("got %d %s, want %d %s", appErr.Code, appErr.Type, tt.code, tt.typ)
+			}
+		})
+	}
+}
+
+func TestErrNotFoundOneMapsOtherErrors(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ct := &CrudTemplate{}
+
+	err := &pgconn.PgError{Code: PgSerializationFailure}
+	appErr := ct.errNotFoundOne(ctx, fmt.Errorf("find: %w", err), nil, "1")
+	if appErr.Code != http.StatusServiceUnavailable {
+		t.Errorf("code = %d, want %d", appErr.Code, http.StatusServiceUnavailable)
+	}
+
+	appErr = ct.errNotFoundOne(ctx, errors.New("connection reset"), nil, "1")
+	if appErr.Code == http.StatusNotFound {
+		t.Error("a failed read answers 404")
+	}
+}
@@ -0,0 +1,81 @@
+package base_postgres
+
+import (
+	"application_template/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ошибки пишутся как problem details RFC 7807 клиентам, принимающим application/problem+json,
+// и в прежнем виде {"error": AppError} всем остальным.
+
+const problemMIME = "application/problem+json"
+
+// ProblemTypeBase - префикс кода исключения ошибки в URI типа проблемы.
+var ProblemTypeBase = "/problems/"
+
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Errors   []ProblemField `json:"errors,omitempty"`
+}
+
+type ProblemField struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+// NewProblem описывает ошибку без исходного сообщения Go или базы данных.
+func NewProblem(ctx *gin.Context, err *AppError) *Problem {
+	p := &Problem{
+		Type:     problemType(err.Type),
+		Title:    http.StatusText(err.Code),
+		Status:   err.Code,
+		Detail:   err.Message,
+		Instance: utils.GetRequestId(ctx),
+	}
+
+	for field, detail := range err.Fields {
+		p.Errors = append(p.Errors, ProblemField{Field: field, Detail: detail})
+	}
+	sort.Slice(p.Errors, func(i, j int) bool {
+		return p.Errors[i].Field < p.Errors[j].Field
+	})
+	if _, listed := err.Fields[err.FieldName]; err.FieldName != "" && !listed {
+		p.Errors = append(p.Errors, ProblemField{Field: err.FieldName, Detail: err.Message})
+	}
+
+	return p
+}
+
+func problemType(code string) string {
+	if code == "" {
+		return "about:blank"
+	}
+	return ProblemTypeBase + strings.TrimPrefix(code, "exception:")
+}
+
+func wantsProblem(ctx *gin.Context) bool {
+	return ctx.NegotiateFormat(gin.MIMEJSON, problemMIME) == problemMIME
+}
+
+// writeError пишет ошибку в виде, который принимает клиент.
+func writeError(ctx *gin.Context, err *AppError) {
+	if err.RetryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(err.RetryAfter.Seconds())))
+	}
+	if wantsProblem(ctx) {
+		// gin сохраняет заданный заранее content type.
+		ctx.Header("Content-Type", problemMIME+"; charset=utf-8")
+		ctx.JSON(err.Code, NewProblem(ctx, err))
+		return
+	}
+	ctx.JSON(err.Code, gin.H{"error": err})
+}
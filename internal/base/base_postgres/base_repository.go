@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"strings"
 )
 
 type PgError struct {
@@ -21,9 +22,11 @@ type CrudRepository interface {
 	Create(s func(*gorm.DB) *gorm.DB, i interface{}) error
 	Update(id uint, s func(*gorm.DB) *gorm.DB, o, u interface{}) error
 	Delete(entity HasId) error
+	Insert(entity HasId) error
 	Save(entity HasId) error
 	PartialUpdate(entity HasId) error
 	Recover(entity HasId) error
+	Purge(entity HasId) error
 	CreateOrUpdate(s func(db *gorm.DB) *gorm.DB, i interface{}, cons string, cols []string) error
 	Where(query interface{}, args ...interface{}) (tx *gorm.DB)
 	FindWhere(o interface{}, w ...interface{}) error
@@ -38,40 +41,33 @@ type CrudRepo struct {
 }
 
 func New() CrudRepository {
+	return NewWithDB(connect.PostgresDB)
+}
+
+// NewWithDB возвращает репозиторий, привязанный к db, например к транзакции.
+func NewWithDB(db *gorm.DB) CrudRepository {
 	return &CrudRepo{
-		db: connect.PostgresDB,
+		db: db,
 	}
 }
 
 func (cr *CrudRepo) FindAll(p Pager, o OrderFilter, s Scope, total *int64, a interface{}, se Searcher) error {
-	if res := cr.db.Model(a).Scopes(s).Count(total); res.Error != nil {
-		return res.Error
-	}
-
-	if res := cr.db.Scopes(p.paginate(), o.sort(), s).Find(a); res.Error != nil {
-		return res.Error
-	}
-	if se != nil {
-		if res := cr.db.Where(se.getQueryJoin()).Joins(se.getJoinModels()).Scopes(p.paginate(), o.sort(), s).Find(a); res.Error != nil {
+	if !p.skipCount() {
+		if res := cr.db.Model(a).Scopes(s, searchScope(se)).Count(total); res.Error != nil {
 			return res.Error
 		}
 	}
-	return nil
+
+	return findPage(cr.db, p, o, a, s, searchScope(se))
 }
 func (cr *CrudRepo) FindAllDeleted(p Pager, o OrderFilter, s Scope, total *int64, a interface{}, se Searcher) error {
-	if res := cr.db.Unscoped().Where("deleted_at IS NOT NULL").Model(a).Scopes(s).Count(total); res.Error != nil {
-		return res.Error
-	}
-
-	if res := cr.db.Unscoped().Where("deleted_at IS NOT NULL").Scopes(p.paginate(), o.sort(), s).Find(a); res.Error != nil {
-		return res.Error
-	}
-	if se != nil {
-		if res := cr.db.Unscoped().Where("deleted_at IS NOT NULL").Where(se.getQueryJoin()).Joins(se.getJoinModels()).Scopes(p.paginate(), o.sort(), s).Find(a); res.Error != nil {
+	if !p.skipCount() {
+		if res := cr.db.Unscoped().Where("deleted_at IS NOT NULL").Model(a).Scopes(s, searchScope(se)).Count(total); res.Error != nil {
 			return res.Error
 		}
 	}
-	return nil
+
+	return findPage(cr.db.Unscoped().Where("deleted_at IS NOT NULL"), p, o, a, s, searchScope(se))
 }
 
 func (cr *CrudRepo) GetFull(s Scope, a interface{}) error {
@@ -126,33 +122,118 @@ func (cr *CrudRepo) Delete(entity HasId) error {
 	if err := cr.FindOne(entity.GetId(), NoScope, entity); err != nil {
 		return err
 	}
-	if res := cr.db.Delete(entity); res.Error != nil {
+	tx, conditional := versioned(cr.db, entity)
+	res := tx.Delete(entity)
+	if res.Error != nil {
 		return res.Error
 	}
+	if conditional && res.RowsAffected == 0 {
+		return ErrVersionMismatch
+	}
 	return nil
 }
 
+// Insert создаёт новую запись без связей. Идентификатор из тела запроса сбрасывается,
+// чтобы создание не перезаписало существующую запись.
+func (cr *CrudRepo) Insert(entity HasId) error {
+	entity.SetId(0)
+	return cr.db.Omit(clause.Associations).Create(entity).Error
+}
+
+// Save записывает все поля существующей записи, кроме первичного ключа, времени создания,
+// скрытых полей, полей только для чтения и связей, до которых тело запроса не должно дотягиваться.
+// Вставкой он никогда не становится: отсутствующая запись даёт gorm.ErrRecordNotFound,
+// условное сохранение изменённой - ErrVersionMismatch.
 func (cr *CrudRepo) Save(entity HasId) error {
-	res := cr.db.Save(entity)
-	return res.Error
+	if entity.GetId() == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	omit, err := saveOmits(cr.db, entity)
+	if err != nil {
+		return err
+	}
+	tx, _ := versioned(cr.db, entity)
+	res := tx.Select("*").Omit(omit...).Save(entity)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return cr.versionError(entity)
+	}
+	return nil
 }
 
+// saveOmits возвращает колонки, которые Save не записывает.
+func saveOmits(db *gorm.DB, entity HasId) ([]string, error) {
+	st := &gorm.Statement{DB: db}
+	if err := st.Parse(entity); err != nil {
+		return nil, err
+	}
+	readOnly := map[string]bool{}
+	for _, name := range entity.GetReadOnlyFields() {
+		readOnly[name] = true
+	}
+
+	omit := []string{"created_at", clause.Associations}
+	for _, field := range st.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PrimaryKey || hiddenField(field) || readOnly[field.Name] || (jsonName != "" && readOnly[jsonName]) {
+			omit = append(omit, field.DBName)
+		}
+	}
+	return omit, nil
+}
+
+// PartialUpdate записывает ненулевые поля сущности, не трогая связи.
 func (cr *CrudRepo) PartialUpdate(entity HasId) error {
-	res := cr.db.Updates(entity)
-	return res.Error
+	tx, conditional := versioned(cr.db, entity)
+	res := tx.Omit(clause.Associations).Updates(entity)
+	if res.Error != nil {
+		return res.Error
+	}
+	if conditional && res.RowsAffected == 0 {
+		return cr.versionError(entity)
+	}
+	return nil
+}
+
+// versionError отличает промах условной записи по изменённой тем временем записи от отсутствующей записи.
+func (cr *CrudRepo) versionError(entity HasId) error {
+	var count int64
+	if res := cr.db.Model(entity).Where("id = ?", entity.GetId()).Count(&count); res.Error != nil {
+		return res.Error
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return ErrVersionMismatch
 }
 func (cr *CrudRepo) Recover(entity HasId) error {
 	if err := cr.FindOneDeleted(entity.GetId(), NoScope, entity); err != nil {
 		return err
 	}
 
-	if res := cr.db.Unscoped().Where("deleted_at IS NOT NULL").Model(&entity).Update("deleted_at", nil); res.Error != nil {
+	if res := cr.db.Unscoped().Where("deleted_at IS NOT NULL").Model(entity).Update("deleted_at", nil); res.Error != nil {
 		return res.Error
 	}
 
 	return nil
 }
 
+// Purge удаляет запись навсегда, мягко удалена она или нет.
+func (cr *CrudRepo) Purge(entity HasId) error {
+	if res := cr.db.Unscoped().Where("id = ?", entity.GetId()).First(entity); res.Error != nil {
+		return res.Error
+	}
+	if res := cr.db.Unscoped().Delete(entity); res.Error != nil {
+		return res.Error
+	}
+	return nil
+}
+
 func (cr *CrudRepo) CreateOrUpdate(s func(db *gorm.DB) *gorm.DB, i interface{}, cons string, cols []string) error {
 	res := cr.db.Debug().Clauses(clause.OnConflict{
 		OnConstraint: cons,
@@ -185,7 +266,7 @@ func (cr *CrudRepo) FindWhere(o interface{}, w ...interface{}) error {
 }
 
 func (cr *CrudRepo) Transaction(fc func(tx *gorm.DB) error) error {
-	return cr.db.Transaction(fc)
+	return Transaction(cr.db, fc)
 }
 
 func (cr *CrudRepo) Raw(sql string, values ...interface{}) (tx *gorm.DB) {
@@ -0,0 +1,63 @@
+package base_postgres
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type saveNote struct {
+	Entity
+	Title    string
+	Number   string
+	Secret   string `json:"-"`
+	Password string
+	Author   *filterUser `gorm:"foreignKey:IdAuthor"`
+	IdAuthor *uint
+}
+
+func (*saveNote) GetReadOnlyFields() []string {
+	return []string{"Number"}
+}
+
+func TestSaveOmits(t *testing.T) {
+	var log []string
+	db := commitDB(t, &log, false)
+
+	omit, err := saveOmits(db, &saveNote{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"created_at", clause.Associations, "id", "number", "secret", "password"}
+	if !reflect.DeepEqual(omit, want) {
+		t.Errorf("omit = %v, want %v", omit, want)
+	}
+}
+
+func TestInsertResetsId(t *testing.T) {
+	var log []string
+	repo := NewWithDB(commitDB(t, &log, false))
+
+	note := &saveNote{Title: "note"}
+	note.ID = 7
+	// записывающий пул не выполняет запросы с RETURNING, важно лишь, что id из тела сброшен до вставки
+	_ = repo.Insert(note)
+	if note.ID == 7 {
+		t.Errorf("id = %d, want the id from the body dropped", note.ID)
+	}
+}
+
+func TestSaveWithoutId(t *testing.T) {
+	var log []string
+	repo := NewWithDB(commitDB(t, &log, false))
+
+	if err := repo.Save(&saveNote{Title: "note"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if len(log) != 0 {
+		t.Errorf("log = %v, want nothing written", log)
+	}
+}
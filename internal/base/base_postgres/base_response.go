@@ -5,13 +5,14 @@ import (
 	"application_template/utils"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 	"io/ioutil"
 	"net/http"
-	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -25,13 +26,25 @@ type AppError struct {
 	Detailed  string
 	FieldName string
 	Fields    map[string]string
+	// Type - код исключения, из которого строится URI типа проблемы, в прежнем виде не выводится.
+	Type string `json:"-"`
+	// RetryAfter, если задан, говорит клиенту, когда повторить запрос.
+	RetryAfter time.Duration `json:"-"`
 }
 
 type AppHandler func(ctx *gin.Context) *AppError
 
 func (a AppHandler) Handle(ctx *gin.Context) {
 	if err := a(ctx); err != nil {
-		ctx.JSON(err.Code, gin.H{"error": err})
+		writeError(ctx, err)
+	}
+}
+
+// Guard выполняет обработчик как middleware и прерывает цепочку при ошибке.
+func (a AppHandler) Guard(ctx *gin.Context) {
+	if err := a(ctx); err != nil {
+		ctx.Abort()
+		writeError(ctx, err)
 	}
 }
 
@@ -50,6 +63,33 @@ func OkT(ctx *gin.Context, t int64, i interface{}) *AppError {
 	return nil
 }
 
+// OkP пишет страницу записей.
+func OkP(ctx *gin.Context, p Pager, t int64, i interface{}) *AppError {
+	return Ok(ctx, pageBody(p, t, i))
+}
+
+// pageBody строит ответ страницы. Total не выводится, если пейджер не считал записи,
+// курсорные страницы добавляют курсоры соседних страниц.
+func pageBody(p Pager, t int64, i interface{}) gin.H {
+	h := gin.H{"data": i}
+	if !p.skipCount() {
+		h["total"] = t
+	}
+	if cp, ok := p.(CursorPager); ok {
+		next, prev := cp.getCursors()
+		h["next_cursor"] = nullable(next)
+		h["prev_cursor"] = nullable(prev)
+	}
+	return h
+}
+
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 func okJson(ctx *gin.Context, s string) *AppError {
 	ctx.Data(http.StatusOK, "application/json; charset=utf-8", []byte(s))
 	return nil
@@ -67,15 +107,17 @@ func errBind(err error) *AppError {
 		Message:   "Bind error",
 		Detailed:  "",
 		FieldName: "",
+		Type:      "exception:bind-error",
 	}
 }
 
 func ErrNotFound(ctx *gin.Context, err error, instance interface{}, id int, params string) *AppError {
-	var message string
+	var message, code string
 	if params != "" {
+		code = "exception:failed-to-fetch-records-with-params"
 		message = utils.Localize(
 			ctx,
-			"exception:failed-to-fetch-records-with-params",
+			code,
 			map[string]interface{}{
 				"Table":      GetTableName(instance, connect.PostgresDB),
 				"Parameters": params,
@@ -83,12 +125,14 @@ func ErrNotFound(ctx *gin.Context, err error, instance interface{}, id int, para
 		)
 	} else {
 		if id != 0 {
-			message = utils.Localize(ctx, "exception:failed-to-fetch-one-record", map[string]interface{}{
+			code = "exception:failed-to-fetch-one-record"
+			message = utils.Localize(ctx, code, map[string]interface{}{
 				"Table": GetTableName(instance, connect.PostgresDB),
 				"ID":    id,
 			})
 		} else {
-			message = utils.Localize(ctx, "exception:could-not-fetch-records", map[string]string{
+			code = "exception:could-not-fetch-records"
+			message = utils.Localize(ctx, code, map[string]string{
 				"Table": GetTableName(instance, connect.PostgresDB),
 			})
 		}
@@ -100,6 +144,7 @@ func ErrNotFound(ctx *gin.Context, err error, instance interface{}, id int, para
 		Message:   message,
 		Detailed:  "",
 		FieldName: "",
+		Type:      code,
 	}
 }
 
@@ -113,39 +158,66 @@ func ErrNotCreated(ctx *gin.Context, err error, instance interface{}) *AppError
 		Message:   message,
 		Detailed:  "",
 		FieldName: "",
+		Type:      "exception:failed-to-create-record",
 	}
 }
 
-func ErrNotUpdated(err error) *AppError {
-	return &AppError{
-		Error:     err.Error(),
-		Code:      http.StatusBadRequest,
-		Message:   "Record not updated",
-		Detailed:  "",
-		FieldName: "",
-	}
+// ErrNotUpdated сообщает о неудачном обновлении: 404, если записи нет, 412, если она изменилась
+// после версии из If-Match, статус ошибки Postgres, если база отклонила запись,
+// иначе 400.
+func ErrNotUpdated(ctx *gin.Context, err error, instance interface{}) *AppError {
+	return errWrite(ctx, err, instance, "exception:failed-to-update-record")
 }
 
-func errNotDeleted(err error) *AppError {
-	return &AppError{
-		Error:     err.Error(),
-		Code:      http.StatusBadRequest,
-		Message:   "Record not deleted",
-		Detailed:  "",
-		FieldName: "",
+func errNotDeleted(ctx *gin.Context, err error, instance interface{}) *AppError {
+	return errWrite(ctx, err, instance, "exception:failed-to-delete-record")
+}
+
+func errWrite(ctx *gin.Context, err error, instance interface{}, code string) *AppError {
+	if appErr := pgError(ctx, err); appErr != nil {
+		return appErr
+	}
+
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrVersionMismatch):
+		status = http.StatusPreconditionFailed
+		code = "exception:version-mismatch"
 	}
+
+	appErr := LocalizeError(ctx, err)
+	appErr.Code = status
+	appErr.Type = code
+	appErr.Message = utils.Localize(ctx, code, map[string]interface{}{
+		"Table": GetTableName(instance, connect.PostgresDB),
+	})
+	return appErr
 }
 
 func ErrDenied(err error) *AppError {
+	code := "exception:access-denied"
+	var localized *utils.LocalizeError
+	if errors.As(err, &localized) {
+		code = localized.Message
+	}
 	return &AppError{
 		Error:     err.Error(),
 		Code:      http.StatusForbidden,
 		Message:   "Access denied",
 		Detailed:  "",
 		FieldName: "",
+		Type:      code,
 	}
 }
 
+func ErrUnauthorized(ctx *gin.Context, err error) *AppError {
+	appErr := LocalizeError(ctx, err)
+	appErr.Code = http.StatusUnauthorized
+	return appErr
+}
+
 func ErrBadRequest(ctx *gin.Context, err error, data map[string]interface{}) *AppError {
 	return &AppError{
 		Error:     err.Error(),
@@ -153,10 +225,31 @@ func ErrBadRequest(ctx *gin.Context, err error, data map[string]interface{}) *Ap
 		Message:   utils.Localize(ctx, err.Error(), data),
 		Detailed:  "",
 		FieldName: "",
+		Type:      err.Error(),
 	}
 }
 
 func LocalizeError(ctx *gin.Context, err error) *AppError {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		return ErrValidation(ctx, invalid)
+	}
+	var invalidItems binding.SliceValidationError
+	if errors.As(err, &invalidItems) {
+		return errSliceValidation(ctx, invalidItems)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if appErr := errUnmarshalType(ctx, typeErr); appErr != nil {
+			return appErr
+		}
+	}
+
+	// ошибка Postgres сохраняет свой статус, даже обёрнутая в локализованную
+	if appErr := pgError(ctx, err); appErr != nil {
+		return appErr
+	}
+
 	var pgxError *pgconn.PgError
 	var fieldName string
 	var detailedInfo string
@@ -177,6 +270,7 @@ func LocalizeError(ctx *gin.Context, err error) *AppError {
 			Code:      http.StatusBadRequest,
 			Detailed:  detailedInfo,
 			FieldName: fieldName,
+			Type:      e.Message,
 		}
 	case *utils.LocalizeError:
 		errors.As(e.Source, &pgxError)
@@ -190,6 +284,7 @@ func LocalizeError(ctx *gin.Context, err error) *AppError {
 			Code:      http.StatusBadRequest,
 			Detailed:  detailedInfo,
 			FieldName: fieldName,
+			Type:      e.Message,
 		}
 	}
 	errors.As(err, &pgxError)
@@ -227,6 +322,7 @@ func DefaultError(ctx *gin.Context, err error, fieldName string, detailedInfo st
 		Code:      http.StatusBadRequest,
 		Detailed:  detailedInfo,
 		FieldName: fieldName,
+		Type:      "exception:default-message",
 	}
 }
 
@@ -250,12 +346,10 @@ func ParamUint(p string) uint {
 	return uint(ParamInt(p))
 }
 
-func getPager(ctx *gin.Context) Pager {
-	page, _ := strconv.Atoi(ctx.Query("page"))
-	if page == 0 {
-		page = 1
-	}
-
+// getPager читает параметры пагинации. Параметр cursor, даже пустой для первой страницы,
+// включает курсорную пагинацию, которая не считает записи без count=true.
+// Пагинация по смещению считает их без count=false.
+func getPager(ctx *gin.Context) (Pager, error) {
 	pageSize, _ := strconv.Atoi(ctx.Query("page_size"))
 	switch {
 	case pageSize > 100:
@@ -264,8 +358,22 @@ func getPager(ctx *gin.Context) Pager {
 		pageSize = 10
 	}
 
+	if cursor, ok := ctx.GetQuery("cursor"); ok {
+		return NewCursorPager(cursor, pageSize, ctx.Query("count") == "true")
+	}
+
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	if page == 0 {
+		page = 1
+	}
+
 	offset := (page - 1) * pageSize
-	return NewPager(page, pageSize, offset)
+	return &Page{
+		page:     page,
+		pageSize: pageSize,
+		offset:   offset,
+		noCount:  ctx.Query("count") == "false",
+	}, nil
 }
 
 func CheckDate(format, date string) bool {
@@ -304,178 +412,30 @@ func getOrder(ctx *gin.Context, model HasId) OrderFilter {
 	return NewOrder(values, model)
 }
 
-func getQuery(c *gin.Context, a interface{}) Searcher {
+// getQuery разбирает параметр search и проверяет его по схеме gorm модели.
+func getQuery(c *gin.Context, a interface{}) (Searcher, error) {
 	search := c.Query("search")
-	if search != "" {
-		in := []byte(search)
-		var raw map[string]interface{}
-		if err := json.Unmarshal(in, &raw); err != nil {
-			return nil
-		}
+	if search == "" {
+		return nil, nil
+	}
 
-		data := reflect.ValueOf(a)
-		s := data.Type()
-
-		model := fmt.Sprintf("%v", s)
-		myModel := model[10:] + "s"
-
-		var query string
-		var queryJoin string
-		var isJoin bool
-		var JoinModels string
-		i := 1
-		lenRaw := len(raw)
-		for key, v := range raw {
-			value := fmt.Sprintf("%v", v)
-			typeValue := fmt.Sprintf("%T", v)
-			var fromV string
-			var beforeV string
-			var lang string
-			var word string
-
-			if strings.Contains(key, "json") == true {
-				s := strings.Split(value, " = ")
-				lang = s[0]
-				word = s[1]
-				typeValue = "json"
-			}
-
-			if CheckDate("2006-01-02", value) == true {
-				typeValue = "date"
-			}
-
-			if strings.Contains(value, "and") == true {
-				s := strings.Split(value, " and ")
-				fromV = s[0]
-				beforeV = s[1]
-				if CheckDate("2006-01-02", fromV) == true {
-					typeValue = "date"
-				}
-			}
-
-			if typeValue == "map[string]interface {}" {
-				byteV, _ := json.Marshal(v)
-				var rawV map[string]interface{}
-				if err := json.Unmarshal(byteV, &rawV); err != nil {
-					return nil
-				}
-				JoinModels = key
-				for k, val := range rawV {
-					newVal := fmt.Sprintf("%v", val)
-					typeVal := fmt.Sprintf("%T", val)
-					var from string
-					var before string
-
-					if CheckDate("2006-02-01", newVal) == true {
-						typeVal = "date"
-					}
-
-					if strings.Contains(newVal, "and") == true {
-						s := strings.Split(newVal, " and ")
-						from = s[0]
-						before = s[1]
-						if CheckDate("2006-02-01", from) == true {
-							typeVal = "date"
-						}
-					}
-
-					if i < lenRaw {
-						if typeVal == "bool" {
-							queryJoin += k + " = " + newVal + " and "
-						} else if typeVal == "string" && newVal != "not_null" {
-							queryJoin += k + " ILIKE " + "'%" + newVal + "%' and "
-						}
-						if typeVal == "int" || typeVal == "float64" {
-							queryJoin += k + " = " + newVal + " and "
-						}
-						if typeVal == "date" {
-							if strings.Contains(newVal, "and") == true {
-								queryJoin += k + " BETWEEN '" + from + "' AND '" + before + "' and "
-							} else {
-								queryJoin += k + "::text LIKE " + "'%" + newVal + "%' and "
-							}
-						}
-						if newVal == "not_null" {
-							queryJoin += k + " IS NOT NULL and "
-						}
-					}
-					if i >= lenRaw {
-						if typeVal == "bool" {
-							queryJoin += k + " = " + newVal
-						} else if typeVal == "string" && newVal != "not_null" {
-							queryJoin += k + " ILIKE " + "'%" + newVal + "%'"
-						}
-						if typeVal == "int" || typeVal == "float64" {
-							queryJoin += k + " = " + newVal
-						}
-						if typeVal == "date" {
-							if strings.Contains(newVal, "and") == true {
-								queryJoin += k + " BETWEEN '" + from + "' AND '" + before + "'"
-							} else {
-								queryJoin += k + "::text LIKE " + "'%" + newVal + "%'"
-							}
-						}
-						if newVal == "not_null" {
-							queryJoin += k + " IS NOT NULL"
-						}
-					}
-					i++
-				}
-				isJoin = true
-			} else {
-				if i < lenRaw {
-					if typeValue == "bool" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " = " + value + " and "
-					} else if typeValue == "string" && value != "not_null" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " ILIKE " + "'%" + value + "%' and "
-					}
-					if typeValue == "int" || typeValue == "float64" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " = " + value + " and "
-					}
-					if typeValue == "date" {
-						if strings.Contains(value, "and") == true {
-							queryJoin += key + " BETWEEN '" + fromV + "' AND '" + beforeV + "' and "
-						} else {
-							queryJoin += ToSnakeCase(myModel) + "." + key + "::text LIKE " + "'%" + value + "%' and "
-						}
-					}
-					if value == "not_null" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " IS NOT NULL and "
-					}
-					if typeValue == "json" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " ->> '" + lang + "' ilike '%" + word + "%' and "
-					}
-				}
-				if i >= lenRaw {
-					if typeValue == "bool" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " = " + value
-					} else if typeValue == "string" && value != "not_null" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " ILIKE " + "'%" + value + "%'"
-					}
-					if typeValue == "int" || typeValue == "float64" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " = " + value
-					}
-					if typeValue == "date" {
-						if strings.Contains(value, "and") == true {
-							queryJoin += key + " BETWEEN '" + fromV + "' AND '" + beforeV + "'"
-						} else {
-							queryJoin += ToSnakeCase(myModel) + "." + key + "::text LIKE " + "'%" + value + "%'"
-						}
-					}
-					if value == "not_null" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " IS NOT NULL"
-					}
-					if typeValue == "json" {
-						queryJoin += ToSnakeCase(myModel) + "." + key + " ->> '" + lang + "' ilike '%" + word + "%'"
-					}
-				}
-				i++
-			}
-		}
-		return NewSearcher(query, isJoin, JoinModels, queryJoin)
-	} else {
-		return nil
+	filter, err := ParseFilter([]byte(search))
+	if err != nil {
+		return nil, err
+	}
+
+	st := &gorm.Statement{DB: connect.PostgresDB}
+	if err := st.Parse(a); err != nil {
+		return nil, err
 	}
+
+	compiler := &filterCompiler{}
+	query, err := filter.compile(compiler, st.Schema, st.Schema.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSearcher(query, compiler.vars), nil
 }
 
 func I18nError(c *gin.Context, model interface{}, errCode string) *AppError {
@@ -499,5 +459,6 @@ func I18nError(c *gin.Context, model interface{}, errCode string) *AppError {
 			"Table": table,
 		}),
 		Code: code,
+		Type: errCode,
 	}
 }
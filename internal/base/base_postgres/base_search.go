@@ -1,25 +1,23 @@
 package base_postgres
 
+import "gorm.io/gorm"
+
 type Searcher interface {
 	getQuery() string
-	getIsJoin() bool
-	getJoinModels() string
-	getQueryJoin() string
+	getVars() []interface{}
+
+	search() func(db *gorm.DB) *gorm.DB
 }
 
 type Search struct {
-	query      string
-	isJoin     bool
-	JoinModels string
-	queryJoin  string
+	query string
+	vars  []interface{}
 }
 
-func NewSearcher(query string, isJoin bool, JoinModels string, queryJoin string) Searcher {
+func NewSearcher(query string, vars []interface{}) Searcher {
 	return &Search{
-		query:      query,
-		isJoin:     isJoin,
-		JoinModels: JoinModels,
-		queryJoin:  queryJoin,
+		query: query,
+		vars:  vars,
 	}
 }
 
@@ -27,14 +25,22 @@ func (p *Search) getQuery() string {
 	return p.query
 }
 
-func (p *Search) getIsJoin() bool {
-	return p.isJoin
+func (p *Search) getVars() []interface{} {
+	return p.vars
 }
 
-func (p *Search) getJoinModels() string {
-	return p.JoinModels
+func (p *Search) search() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.query == "" {
+			return db
+		}
+		return db.Where(p.query, p.vars...)
+	}
 }
 
-func (p *Search) getQueryJoin() string {
-	return p.queryJoin
+func searchScope(se Searcher) Scope {
+	if se == nil {
+		return NoScope
+	}
+	return se.search()
 }
@@ -1,5 +1,13 @@
 package base_postgres
 
+import (
+	"application_template/internal/database/outbox"
+
+	"gorm.io/gorm"
+)
+
+const importSavePoint = "import_row"
+
 type FindAll func(all interface{}, s Scope, p Pager, o OrderFilter, total *int64, se Searcher) error
 type FindAllDeleted func(all interface{}, s Scope, p Pager, o OrderFilter, total *int64, se Searcher) error
 type FindOne func(one HasId, s Scope) error
@@ -7,6 +15,7 @@ type FindOneDeleted func(one HasId, s Scope) error
 type Create func(one HasId) error
 type Update func(one HasId) error
 type Delete func(one HasId) error
+type Import func(rows []HasId, conflict *Conflict) ([]error, error)
 
 type FindAllInterface interface {
 	FindAll(all interface{}, s Scope, p Pager, o OrderFilter, total *int64, se Searcher) error
@@ -38,6 +47,11 @@ type UpdateInterface interface {
 
 type DeleteInterface interface {
 	Delete(one HasId) error
+	Purge(one HasId) error
+}
+
+type ImportInterface interface {
+	Import(rows []HasId, conflict *Conflict) ([]error, error)
 }
 
 type CrudServiceInterface interface {
@@ -49,6 +63,7 @@ type CrudServiceInterface interface {
 	CreateInterface
 	UpdateInterface
 	DeleteInterface
+	ImportInterface
 }
 
 type CrudService struct {
@@ -80,21 +95,92 @@ func (c *CrudService) FindOneDeleted(one HasId, s Scope) error {
 }
 
 func (c *CrudService) Create(one HasId) error {
-	return c.repo.Save(one)
+	return c.withEvent(one, outbox.Created, func(repo CrudRepository) error {
+		return repo.Insert(one)
+	})
 }
 
 func (c *CrudService) PartialUpdate(one HasId) error {
-	return c.repo.PartialUpdate(one)
+	return c.withEvent(one, outbox.Updated, func(repo CrudRepository) error {
+		return repo.PartialUpdate(one)
+	})
 }
 
 func (c *CrudService) Update(one HasId) error {
-	return c.repo.Save(one)
+	return c.withEvent(one, outbox.Updated, func(repo CrudRepository) error {
+		return repo.Save(one)
+	})
 }
 
+// Recover восстанавливает мягко удалённую запись; для подписчиков она создаётся снова.
 func (c *CrudService) Recover(one HasId) error {
-	return c.repo.Recover(one)
+	return c.withEvent(one, outbox.Created, func(repo CrudRepository) error {
+		return repo.Recover(one)
+	})
 }
 
 func (c *CrudService) Delete(one HasId) error {
-	return c.repo.Delete(one)
+	return c.withEvent(one, outbox.Deleted, func(repo CrudRepository) error {
+		return repo.Delete(one)
+	})
+}
+
+func (c *CrudService) Purge(one HasId) error {
+	return c.withEvent(one, outbox.Deleted, func(repo CrudRepository) error {
+		return repo.Purge(one)
+	})
+}
+
+// Conflict называет уникальное ограничение для upsert строк и колонки, обновляемые при конфликте.
+type Conflict struct {
+	Constraint string
+	Columns    []string
+}
+
+// Import сохраняет строки в одной транзакции, каждую под своим savepoint, чтобы отклонённая базой
+// строка попадала в отчёт под своим индексом, не прерывая остальные. С conflict строки обновляются.
+func (c *CrudService) Import(rows []HasId, conflict *Conflict) ([]error, error) {
+	errs := make([]error, len(rows))
+
+	err := c.repo.Transaction(func(tx *gorm.DB) error {
+		keys, err := conflictKeys(tx, rows, conflict)
+		if err != nil {
+			return err
+		}
+		for i, row := range rows {
+			if row == nil {
+				continue
+			}
+			if res := tx.SavePoint(importSavePoint); res.Error != nil {
+				return res.Error
+			}
+
+			if err := importRow(tx, row, conflict, keys); err != nil {
+				errs[i] = err
+				if res := tx.RollbackTo(importSavePoint); res.Error != nil {
+					return res.Error
+				}
+			}
+		}
+		return nil
+	})
+
+	return errs, err
+}
+
+// conflictKeys возвращает колонки ограничения upsert, нужные событиям строк EventSource.
+func conflictKeys(tx *gorm.DB, rows []HasId, conflict *Conflict) ([]string, error) {
+	if conflict == nil {
+		return nil, nil
+	}
+	for _, row := range rows {
+		if row == nil {
+			continue
+		}
+		if _, ok := row.(EventSource); !ok {
+			return nil, nil
+		}
+		return constraintColumns(tx, row, conflict.Constraint)
+	}
+	return nil, nil
 }
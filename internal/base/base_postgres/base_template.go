@@ -2,13 +2,13 @@ package base_postgres
 
 import (
 	"application_template/internal/database/connect"
-	"application_template/internal/database/redis"
 	"application_template/utils"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"io"
-	"net/http"
 	"reflect"
 )
 
@@ -35,24 +35,57 @@ func NewCrudTemplate(cc *CrudController) *CrudTemplate {
 }
 
 func (ct *CrudTemplate) FindAllFunc(c *gin.Context, findAll FindAll) *AppError {
+	return ct.list(c, findAll, true)
+}
+
+// list пишет страницу записей, прочитанную findAll, и кеширует её под ключом списка, если задан cache.
+func (ct *CrudTemplate) list(c *gin.Context, findAll FindAll, cache bool) *AppError {
 	a := ct.mi.GetAll()
 
-	//search := c.Query("search")
-	//if search == "" {
-	//	all, err := redis.Get(c, ct.ri.KeyAll())
-	//	if err == nil {
-	//		return okJson(c, all)
-	//	}
-	//}
+	se, err := getQuery(c, a)
+	if err != nil {
+		return LocalizeError(c, err)
+	}
 
-	var total int64
-	if err := findAll(a, ct.mi.ScopeAll, getPager(c), getOrder(c, ct.mi.GetOne()), &total, getQuery(c, a)); err != nil {
-		return I18nError(c, a, "exception:could-not-fetch-records")
+	p, err := getPager(c)
+	if err != nil {
+		return LocalizeError(c, err)
+	}
+	order := getOrder(c, ct.mi.GetOne())
+
+	// load может выполняться в фоне для обновления страницы в кеше, поэтому не должен использовать c.
+	load := func(context.Context) ([]byte, error) {
+		all := ct.mi.GetAll()
+		var total int64
+		if err := findAll(all, ct.mi.ScopeAll, p, order, &total, se); err != nil {
+			return nil, err
+		}
+		return json.Marshal(pageBody(p, total, all))
 	}
 
-	_ = redis.Set(c, ct.ri.KeyAll(), a)
+	var key string
+	if cache {
+		key = ct.listKey(c)
+	}
 
-	return OkT(c, total, a)
+	var body []byte
+	if key != "" {
+		body, err = connect.Cache.Fetch(c, key, ct.ri.ListTTL(), load)
+	} else {
+		body, err = load(c)
+	}
+	if err != nil {
+		var localized *utils.LocalizeError
+		if errors.As(err, &localized) {
+			return LocalizeError(c, localized)
+		}
+		if appErr := pgError(c, err); appErr != nil {
+			return appErr
+		}
+		return I18nError(c, a, "exception:could-not-fetch-records")
+	}
+
+	return okJson(c, string(body))
 }
 
 func (ct *CrudTemplate) FindAll(c *gin.Context, allInter FindAllInterface) *AppError {
@@ -64,29 +97,32 @@ func (ct *CrudTemplate) FindOneFunc(c *gin.Context, findOne FindOne) *AppError {
 	o := ct.mi.GetOne()
 	o.SetId(ParamUint(id))
 
-	redisStop := c.Query("redisStop")
-
-	if redisStop == "" {
-		one, err := redis.Get(c, ct.ri.KeyOne(id))
-		if err == nil {
-			return okJson(c, one)
+	load := func(context.Context) ([]byte, error) {
+		one := ct.mi.GetOne()
+		one.SetId(ParamUint(id))
+		if err := findOne(one, ct.mi.ScopeOne); err != nil {
+			return nil, err
 		}
+		return json.Marshal(one)
 	}
 
-	if err := findOne(o, ct.mi.ScopeOne); err != nil {
-		return &AppError{
-			Error: err.Error(),
-			Message: utils.Localize(c, "exception:failed-to-fetch-one-record", map[string]interface{}{
-				"Table": GetTableName(o, connect.PostgresDB),
-				"ID":    id,
-			}),
-			Code: http.StatusNotFound,
-		}
+	var body []byte
+	var err error
+	if c.Query(cacheBypass) == "" {
+		body, err = connect.Cache.Fetch(c, ct.ri.KeyOne(id), 0, load)
+	} else {
+		body, err = load(c)
+	}
+	if err != nil {
+		return ct.errNotFoundOne(c, err, o, id)
 	}
 
-	_ = redis.Set(c, ct.ri.KeyOne(id), o)
+	// тело может прийти из кеша: его версия читается из него же
+	if _, ok := o.(Versioned); ok && json.Unmarshal(body, o) == nil {
+		setETag(c, o)
+	}
 
-	return Ok(c, o)
+	return okJson(c, string(body))
 }
 
 func (ct *CrudTemplate) FindOne(c *gin.Context, oneInter FindOneInterface) *AppError {
@@ -110,7 +146,7 @@ func (ct *CrudTemplate) CreateFunc(c *gin.Context, create Create) *AppError {
 		return LocalizeError(c, err)
 	}
 
-	_ = redis.Unset(c, ct.ri.KeyAll())
+	ct.invalidate(c)
 
 	return Ok(c, i)
 }
@@ -135,20 +171,27 @@ func (ct *CrudTemplate) UpdateFunc(c *gin.Context, update Update) *AppError {
 		))
 	}
 
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(data))
-
-	if err := c.ShouldBindJSON(o); err != nil {
+	if c.Request.Method == patch {
+		err = bindPartial(o, data, body)
+	} else {
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(data))
+		err = c.ShouldBindJSON(o)
+	}
+	if err != nil {
 		return LocalizeError(c, err)
 	}
 
 	o.SetId(ParamUint(id))
 
+	if err := expectIfMatch(c, o); err != nil {
+		return ErrNotUpdated(c, err, o)
+	}
 	if err := update(o); err != nil {
-		return ErrNotUpdated(err)
+		return ErrNotUpdated(c, err, o)
 	}
 
-	_ = redis.Unset(c, ct.ri.KeyAll())
-	_ = redis.Unset(c, ct.ri.KeyOne(id))
+	ct.invalidate(c, id)
+	setETag(c, o)
 
 	return Ok(c, body)
 }
@@ -166,12 +209,14 @@ func (ct *CrudTemplate) DeleteFunc(c *gin.Context, delete Delete) *AppError {
 	o := ct.mi.GetOne()
 	o.SetId(ParamUint(id))
 
+	if err := expectIfMatch(c, o); err != nil {
+		return errNotDeleted(c, err, o)
+	}
 	if err := delete(o); err != nil {
-		return errNotDeleted(err)
+		return errNotDeleted(c, err, o)
 	}
 
-	_ = redis.Unset(c, ct.ri.KeyAll())
-	_ = redis.Unset(c, ct.ri.KeyOne(id))
+	ct.invalidate(c, id)
 
 	return Ok(c, o)
 }
@@ -0,0 +1,115 @@
+package base_postgres
+
+import (
+	"application_template/internal/database/connect"
+	"application_template/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ErrConflict сообщает о записи, конфликтующей с другой, например о восстановленной записи,
+// уникальные значения которой заняли, пока она была удалена.
+func ErrConflict(ctx *gin.Context, err error, message string, instance interface{}) *AppError {
+	appErr := LocalizeError(ctx, err)
+	appErr.Code = http.StatusConflict
+	appErr.Type = message
+	appErr.Message = utils.Localize(ctx, message, map[string]interface{}{
+		"Table": GetTableName(instance, connect.PostgresDB),
+		"Field": appErr.FieldName,
+	})
+	return appErr
+}
+
+// errNotFoundOne отвечает 404 для отсутствующей записи и отображает любую другую ошибку как LocalizeError.
+func (ct *CrudTemplate) errNotFoundOne(c *gin.Context, err error, o HasId, id string) *AppError {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return LocalizeError(c, err)
+	}
+	return &AppError{
+		Error: err.Error(),
+		Message: utils.Localize(c, "exception:failed-to-fetch-one-record", map[string]interface{}{
+			"Table": GetTableName(o, connect.PostgresDB),
+			"ID":    id,
+		}),
+		Code: http.StatusNotFound,
+		Type: "exception:failed-to-fetch-one-record",
+	}
+}
+
+func (ct *CrudTemplate) FindAllDeletedFunc(c *gin.Context, findAllDeleted FindAllDeleted) *AppError {
+	return ct.list(c, FindAll(findAllDeleted), false)
+}
+
+func (ct *CrudTemplate) FindAllDeleted(c *gin.Context, allInter FindAllDeletedInterface) *AppError {
+	return ct.FindAllDeletedFunc(c, allInter.FindAllDeleted)
+}
+
+func (ct *CrudTemplate) FindOneDeletedFunc(c *gin.Context, findOneDeleted FindOneDeleted) *AppError {
+	id := c.Param("id")
+	o := ct.mi.GetOne()
+	o.SetId(ParamUint(id))
+
+	if err := findOneDeleted(o, ct.mi.ScopeOne); err != nil {
+		return ct.errNotFoundOne(c, err, o, id)
+	}
+
+	return Ok(c, o)
+}
+
+func (ct *CrudTemplate) FindOneDeleted(c *gin.Context, oneInter FindOneDeletedInterface) *AppError {
+	return ct.FindOneDeletedFunc(c, oneInter.FindOneDeleted)
+}
+
+// RecoverFunc восстанавливает мягко удалённую запись. Восстановление падает с 409, если живая запись
+// тем временем заняла её значения частичного уникального индекса (where deleted_at is null).
+func (ct *CrudTemplate) RecoverFunc(c *gin.Context, recover Update) *AppError {
+	id := c.Param("id")
+	o := ct.mi.GetOne()
+	o.SetId(ParamUint(id))
+
+	if err := recover(o); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ct.errNotFoundOne(c, err, o, id)
+		case PgCode(err) == PgUniqueViolation:
+			return ErrConflict(c, err, "exception:restore-conflict", o)
+		}
+		return LocalizeError(c, err)
+	}
+
+	ct.invalidate(c, id)
+
+	return Ok(c, o)
+}
+
+func (ct *CrudTemplate) Recover(c *gin.Context, updInter UpdateInterface) *AppError {
+	return ct.RecoverFunc(c, updInter.Recover)
+}
+
+// PurgeFunc удаляет запись навсегда. Записи, на которые ещё ссылаются другие, остаются с 409.
+func (ct *CrudTemplate) PurgeFunc(c *gin.Context, purge Delete) *AppError {
+	id := c.Param("id")
+	o := ct.mi.GetOne()
+	o.SetId(ParamUint(id))
+
+	if err := purge(o); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ct.errNotFoundOne(c, err, o, id)
+		case PgCode(err) == PgForeignKeyViolation:
+			return ErrConflict(c, err, "exception:purge-conflict", o)
+		}
+		return errNotDeleted(c, err, o)
+	}
+
+	ct.invalidate(c, id)
+
+	return Ok(c, o)
+}
+
+func (ct *CrudTemplate) Purge(c *gin.Context, delInter DeleteInterface) *AppError {
+	return ct.PurgeFunc(c, delInter.Purge)
+}
@@ -2,11 +2,212 @@ package base_postgres
 
 import (
 	"application_template/pkg/types"
+	"application_template/utils"
 	"encoding/json"
-	"gorm.io/gorm/utils"
+	"errors"
 	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	gormutils "gorm.io/gorm/utils"
 )
 
+// Тела проверяются по тегам binding своих полей. Каждое непрошедшее поле попадает
+// в AppError.Fields под своим JSON именем, с сообщением validation:<tag> на языке запроса.
+
+// Validator возвращает валидатор, которым gin разбирает тела запросов.
+func Validator() *validator.Validate {
+	return binding.Validator.Engine().(*validator.Validate)
+}
+
+// RegisterValidators называет поля их JSON именами и добавляет проверки общих типов:
+// phone проверяет types.PhoneNumber или строку, translations проверяет, что у переводимого JSON поля
+// есть перевод на каждый язык параметра, например translations=en ru, или хотя бы на один.
+func RegisterValidators() error {
+	v := Validator()
+	v.RegisterTagNameFunc(jsonName)
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(types.PhoneNumber).Number
+	}, types.PhoneNumber{})
+
+	if err := v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "" || types.ValidPhoneNumber(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	return v.RegisterValidation("translations", validTranslations)
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+func validTranslations(fl validator.FieldLevel) bool {
+	var raw []byte
+	switch field := fl.Field(); {
+	case field.Kind() == reflect.String:
+		raw = []byte(field.String())
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		raw = field.Bytes()
+	default:
+		return false
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return fl.Param() == ""
+	}
+
+	var translations map[string]interface{}
+	if err := json.Unmarshal(raw, &translations); err != nil {
+		return false
+	}
+	translated := func(lang string) bool {
+		t, ok := translations[lang].(string)
+		return ok && strings.TrimSpace(t) != ""
+	}
+
+	if fl.Param() == "" {
+		for lang := range translations {
+			if translated(lang) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, lang := range strings.Fields(fl.Param()) {
+		if !translated(lang) {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrValidation сообщает обо всех неверных полях тела.
+func ErrValidation(ctx *gin.Context, err validator.ValidationErrors) *AppError {
+	fields := make(map[string]string, len(err))
+	addFields(ctx, fields, err)
+	return errFields(ctx, err, fields)
+}
+
+// errSliceValidation сообщает о неверных полях тела со списком. gin не говорит, какие элементы
+// не прошли, поэтому поле, не прошедшее в нескольких элементах, сообщается один раз.
+func errSliceValidation(ctx *gin.Context, err binding.SliceValidationError) *AppError {
+	fields := map[string]string{}
+	for _, e := range err {
+		var invalid validator.ValidationErrors
+		if errors.As(e, &invalid) {
+			addFields(ctx, fields, invalid)
+		}
+	}
+	return errFields(ctx, err, fields)
+}
+
+func addFields(ctx *gin.Context, fields map[string]string, err validator.ValidationErrors) {
+	for _, fe := range err {
+		if _, ok := fields[fieldKey(fe)]; !ok {
+			fields[fieldKey(fe)] = validationMessage(ctx, fe)
+		}
+	}
+}
+
+func errFields(ctx *gin.Context, err error, fields map[string]string) *AppError {
+	return &AppError{
+		Error:   err.Error(),
+		Code:    http.StatusUnprocessableEntity,
+		Message: utils.Localize(ctx, "exception:validation-failed", nil),
+		Fields:  fields,
+		Type:    "exception:validation-failed",
+	}
+}
+
+// errUnmarshalType сообщает о значении тела неверного JSON типа как о непрошедшей проверке.
+func errUnmarshalType(ctx *gin.Context, err *json.UnmarshalTypeError) *AppError {
+	field := err.Field
+	if field == "" {
+		return nil
+	}
+	return &AppError{
+		Error:   err.Error(),
+		Code:    http.StatusUnprocessableEntity,
+		Message: utils.Localize(ctx, "exception:validation-failed", nil),
+		Fields: map[string]string{
+			field: utils.Localize(ctx, "validation:type", map[string]interface{}{
+				"Field": fieldLabel(ctx, field, field),
+				"Param": err.Type.String(),
+			}),
+		},
+		Type: "exception:validation-failed",
+	}
+}
+
+// fieldKey - путь поля внутри проверяемой структуры, например UserName или Translations.en.
+func fieldKey(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+func validationMessage(ctx *gin.Context, fe validator.FieldError) string {
+	return utils.LocalizeOr(ctx, utils.DefaultError(ctx), map[string]interface{}{
+		"Field": fieldLabel(ctx, fe.Field(), fe.StructField()),
+		"Param": fe.Param(),
+	}, "validation:"+fe.Tag(), "validation:default")
+}
+
+// fieldLabel локализует поле по имени колонки так же, как заголовки экспорта.
+func fieldLabel(ctx *gin.Context, name, structField string) string {
+	return utils.LocalizeOr(ctx, name, nil, "column:"+utils.ToSnakeCase(structField))
+}
+
+// bindPartial декодирует частичное обновление в o и проверяет только поля, присутствующие в body.
+func bindPartial(o HasId, data []byte, body map[string]interface{}) error {
+	if err := json.Unmarshal(data, o); err != nil {
+		return err
+	}
+
+	t := reflect.Indirect(reflect.ValueOf(o)).Type()
+	fields := make([]string, 0, len(body))
+	for key := range body {
+		if name, ok := structFieldName(t, key); ok {
+			fields = append(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return Validator().StructPartial(o, fields...)
+}
+
+// structFieldName находит поле t, включая встроенные, с JSON именем key.
+func structFieldName(t reflect.Type, key string) (string, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if name, ok := structFieldName(field.Type, key); ok {
+				return name, true
+			}
+			continue
+		}
+		if field.IsExported() && jsonName(field) == key {
+			return field.Name, true
+		}
+	}
+	return "", false
+}
+
 func ValidateBody(model HasId, requestBody io.ReadCloser) (map[string]interface{}, error) {
 	m := make(map[string]interface{})
 
@@ -27,7 +228,7 @@ func ValidateBody(model HasId, requestBody io.ReadCloser) (map[string]interface{
 
 		m[k] = value
 
-		if utils.Contains(model.GetReadOnlyFields(), k) {
+		if gormutils.Contains(model.GetReadOnlyFields(), k) {
 			delete(m, k)
 		}
 	}
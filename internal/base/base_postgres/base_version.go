@@ -0,0 +1,98 @@
+package base_postgres
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Версионированная запись реализует оптимистичную блокировку: клиент отправляет в If-Match
+// прочитанный ETag, и запись проходит, только пока у сохранённой записи та же версия.
+
+// ErrVersionMismatch возвращает условная запись записи, изменённой после чтения.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// Versioned реализуют модели с версией. Entity выводит её из UpdatedAt;
+// модель с колонкой версии реализует его на этой колонке.
+type Versioned interface {
+	GetVersion() string
+	// ExpectVersion делает следующую запись модели условной по одной из versions.
+	ExpectVersion(versions ...string) error
+	// VersionCondition возвращает условие условной записи, ok false для безусловной.
+	VersionCondition() (query string, args interface{}, ok bool)
+}
+
+func (e *Entity) GetVersion() string {
+	return strconv.FormatInt(e.UpdatedAt.UnixMicro(), 10)
+}
+
+func (e *Entity) ExpectVersion(versions ...string) error {
+	e.expected = e.expected[:0]
+	for _, version := range versions {
+		micros, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return ErrVersionMismatch
+		}
+		e.expected = append(e.expected, time.UnixMicro(micros))
+	}
+	return nil
+}
+
+func (e *Entity) VersionCondition() (string, interface{}, bool) {
+	if len(e.expected) == 0 {
+		return "", nil, false
+	}
+	return "updated_at IN ?", e.expected, true
+}
+
+// versioned добавляет к db условие версии entity, ok false, если запись безусловная.
+func versioned(db *gorm.DB, entity interface{}) (*gorm.DB, bool) {
+	v, ok := entity.(Versioned)
+	if !ok {
+		return db, false
+	}
+	query, args, ok := v.VersionCondition()
+	if !ok {
+		return db, false
+	}
+	return db.Where(query, args), true
+}
+
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+// setETag отправляет версию o, если она есть.
+func setETag(c *gin.Context, o interface{}) {
+	if v, ok := o.(Versioned); ok {
+		c.Header("ETag", etag(v.GetVersion()))
+	}
+}
+
+// expectIfMatch делает следующую запись o условной по ETag из If-Match.
+// Без заголовка или с * запись безусловная.
+func expectIfMatch(c *gin.Context, o interface{}) error {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	v, ok := o.(Versioned)
+	if header == "" || header == "*" || !ok {
+		return nil
+	}
+
+	var versions []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// слабые теги в If-Match никогда не совпадают
+		if strings.HasPrefix(tag, "W/") || len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+		versions = append(versions, tag[1:len(tag)-1])
+	}
+	if len(versions) == 0 {
+		return ErrVersionMismatch
+	}
+	return v.ExpectVersion(versions...)
+}
@@ -3,47 +3,158 @@ package config
 import "github.com/spf13/viper"
 
 type Config struct {
-	Server
-	DB
-	RabbitMQ
-	Redis
-	JWT
+	Server    `mapstructure:",squash"`
+	DB        `mapstructure:",squash"`
+	RabbitMQ  `mapstructure:",squash"`
+	Redis     `mapstructure:",squash"`
+	JWT       `mapstructure:",squash"`
+	Password  `mapstructure:",squash"`
+	Admin     `mapstructure:",squash"`
+	Retention `mapstructure:",squash"`
+	Cache     `mapstructure:",squash"`
+	Worker    `mapstructure:",squash"`
+	Outbox    `mapstructure:",squash"`
+	Inbox     `mapstructure:",squash"`
+	Scheduler `mapstructure:",squash"`
 }
 
 type Server struct {
-	AppPort int
-	AppHost string
+	AppPort    int    `mapstructure:"APP_PORT"`
+	AppHost    string `mapstructure:"APP_HOST"`
+	AppLocales string `mapstructure:"APP_LOCALES"`
 }
 
 type DB struct {
-	DBHost     string
-	DBPort     int
-	DBName     string
-	DBUser     string
-	DBPassword string
-	DBSSLMode  string
+	DBHost     string `mapstructure:"DB_HOST"`
+	DBPort     int    `mapstructure:"DB_PORT"`
+	DBName     string `mapstructure:"DB_NAME"`
+	DBUser     string `mapstructure:"DB_USER"`
+	DBPassword string `mapstructure:"DB_PASSWORD"`
+	DBSSLMode  string `mapstructure:"DB_SSLMODE"`
+
+	DBMigrations  string `mapstructure:"DB_MIGRATIONS"`
+	DBAutoMigrate bool   `mapstructure:"DB_AUTO_MIGRATE"`
 }
 
 type RabbitMQ struct {
-	RabbitMQHost string
-	RabbitMQPort int
-	RabbitMQUser string
-	RabbitMQPass string
+	RabbitMQHost string `mapstructure:"RABBITMQ_HOST"`
+	RabbitMQPort int    `mapstructure:"RABBITMQ_PORT"`
+	RabbitMQUser string `mapstructure:"RABBITMQ_USERNAME"`
+	RabbitMQPass string `mapstructure:"RABBITMQ_PASSWORD"`
+
+	RabbitMQEnabled        bool   `mapstructure:"RABBITMQ_ENABLED"`
+	RabbitMQVHost          string `mapstructure:"RABBITMQ_VHOST"`
+	RabbitMQPoolSize       int    `mapstructure:"RABBITMQ_POOL_SIZE"`
+	RabbitMQConfirmTimeout int    `mapstructure:"RABBITMQ_CONFIRM_TIMEOUT"`
 }
 
 type Redis struct {
-	RedisAddr     string
-	RedisDB       int
-	RedisPassword string
+	RedisAddr     string `mapstructure:"REDIS_ADDR"`
+	RedisDB       int    `mapstructure:"REDIS_DB"`
+	RedisPassword string `mapstructure:"REDIS_PASSWORD"`
 }
 
 type JWT struct {
-	JWTSecret     string
-	JWTExpiration int
+	JWTSecret            string `mapstructure:"JWT_SECRET"`
+	JWTExpiration        int    `mapstructure:"JWT_EXPIRATION"`
+	JWTRefreshExpiration int    `mapstructure:"JWT_REFRESH_EXPIRATION"`
+}
+
+type Password struct {
+	PasswordHashCost       int  `mapstructure:"PASSWORD_HASH_COST"`
+	PasswordMinLength      int  `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper   bool `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower   bool `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit   bool `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSpecial bool `mapstructure:"PASSWORD_REQUIRE_SPECIAL"`
+}
+
+type Admin struct {
+	AdminUserName string `mapstructure:"ADMIN_USERNAME"`
+	AdminPassword string `mapstructure:"ADMIN_PASSWORD"`
+}
+
+type Retention struct {
+	RetentionSchedule   string `mapstructure:"RETENTION_SCHEDULE"`
+	RetentionBatchSize  int    `mapstructure:"RETENTION_BATCH_SIZE"`
+	RetentionArchiveDir string `mapstructure:"RETENTION_ARCHIVE_DIR"`
+}
+
+type Cache struct {
+	CacheDriver       string  `mapstructure:"CACHE_DRIVER"`
+	CacheTTL          int     `mapstructure:"CACHE_TTL"`
+	CacheMemorySize   int     `mapstructure:"CACHE_MEMORY_SIZE"`
+	CacheLocalTTL     int     `mapstructure:"CACHE_LOCAL_TTL"`
+	CacheRefreshAhead float64 `mapstructure:"CACHE_REFRESH_AHEAD"`
+	CacheChannel      string  `mapstructure:"CACHE_CHANNEL"`
+}
+
+type Worker struct {
+	WorkerEmbedded     bool `mapstructure:"WORKER_EMBEDDED"`
+	WorkerConcurrency  int  `mapstructure:"WORKER_CONCURRENCY"`
+	WorkerMaxAttempts  int  `mapstructure:"WORKER_MAX_ATTEMPTS"`
+	WorkerBackoff      int  `mapstructure:"WORKER_BACKOFF"`
+	WorkerMaxBackoff   int  `mapstructure:"WORKER_MAX_BACKOFF"`
+	WorkerDrainTimeout int  `mapstructure:"WORKER_DRAIN_TIMEOUT"`
+}
+
+type Outbox struct {
+	OutboxExchange  string `mapstructure:"OUTBOX_EXCHANGE"`
+	OutboxInterval  int    `mapstructure:"OUTBOX_INTERVAL"`
+	OutboxBatchSize int    `mapstructure:"OUTBOX_BATCH_SIZE"`
+	OutboxRetention int    `mapstructure:"OUTBOX_RETENTION"`
+}
+
+type Inbox struct {
+	InboxRetention int `mapstructure:"INBOX_RETENTION"`
+}
+
+type Scheduler struct {
+	SchedulerEnabled          bool   `mapstructure:"SCHEDULER_ENABLED"`
+	SchedulerTimezone         string `mapstructure:"SCHEDULER_TIMEZONE"`
+	SchedulerLockTTL          int    `mapstructure:"SCHEDULER_LOCK_TTL"`
+	SchedulerHistoryRetention int    `mapstructure:"SCHEDULER_HISTORY_RETENTION"`
 }
 
 var config Config
 
+var defaults = map[string]interface{}{
+	"APP_PORT":                    8080,
+	"APP_LOCALES":                 "locales",
+	"DB_SSLMODE":                  "disable",
+	"DB_MIGRATIONS":               "migrations",
+	"RABBITMQ_VHOST":              "/",
+	"RABBITMQ_POOL_SIZE":          4,
+	"RABBITMQ_CONFIRM_TIMEOUT":    5,
+	"JWT_EXPIRATION":              3600,
+	"JWT_REFRESH_EXPIRATION":      2592000,
+	"PASSWORD_MIN_LENGTH":         8,
+	"ADMIN_USERNAME":              "admin",
+	"RETENTION_SCHEDULE":          "0 3 * * *",
+	"RETENTION_BATCH_SIZE":        500,
+	"CACHE_DRIVER":                "redis",
+	"CACHE_TTL":                   3600,
+	"CACHE_MEMORY_SIZE":           10000,
+	"CACHE_LOCAL_TTL":             5,
+	"CACHE_REFRESH_AHEAD":         0.1,
+	"CACHE_CHANNEL":               "cache:invalidate",
+	"WORKER_EMBEDDED":             true,
+	"WORKER_CONCURRENCY":          1,
+	"WORKER_MAX_ATTEMPTS":         5,
+	"WORKER_BACKOFF":              5,
+	"WORKER_MAX_BACKOFF":          300,
+	"WORKER_DRAIN_TIMEOUT":        30,
+	"OUTBOX_EXCHANGE":             "events",
+	"OUTBOX_INTERVAL":             1,
+	"OUTBOX_BATCH_SIZE":           100,
+	"OUTBOX_RETENTION":            604800,
+	"INBOX_RETENTION":             604800,
+	"SCHEDULER_ENABLED":           true,
+	"SCHEDULER_TIMEZONE":          "UTC",
+	"SCHEDULER_LOCK_TTL":          60,
+	"SCHEDULER_HISTORY_RETENTION": 2592000,
+}
+
 func Load() (*Config, error) {
 	return LoadPath(".")
 }
@@ -52,6 +163,11 @@ func LoadPath(path string) (*Config, error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
+	viper.AutomaticEnv()
+
+	for k, v := range defaults {
+		viper.SetDefault(k, v)
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverTiered = "tiered"
+	DriverNone   = "none"
+)
+
+// ErrMiss возвращает Get, когда ключ не закеширован.
+var ErrMiss = errors.New("cache: miss")
+
+// Cache хранит значения по ключу. ttl 0 хранит значение, пока его не вытеснят или не удалят.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Incr увеличивает целое под ключом, начиная с 0.
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+type Options struct {
+	Driver string
+	// TTL используется, когда Fetch не передан свой.
+	TTL time.Duration
+	// MemorySize - число записей, которое держит кеш в памяти.
+	MemorySize int
+	// LocalTTL ограничивает, сколько многоуровневый кеш держит значение в памяти.
+	LocalTTL time.Duration
+	// RefreshAhead - доля TTL до истечения, в течение которой Fetch перезагружает значение в фоне.
+	RefreshAhead float64
+	// Channel - канал Redis, в который многоуровневый кеш публикует инвалидации.
+	Channel string
+}
+
+// New создаёт хранилище настроенного драйвера. Redis нужен только драйверам redis и tiered.
+func New(opts Options, client *redis.Client) (*Store, error) {
+	var c Cache
+	switch opts.Driver {
+	case DriverRedis, "":
+		if client == nil {
+			return nil, fmt.Errorf("cache driver %s requires redis", DriverRedis)
+		}
+		c = NewRedis(client)
+	case DriverMemory:
+		c = NewLRU(opts.MemorySize)
+	case DriverTiered:
+		if client == nil {
+			return nil, fmt.Errorf("cache driver %s requires redis", DriverTiered)
+		}
+		c = NewTiered(NewLRU(opts.MemorySize), NewRedis(client), opts.LocalTTL, NewInvalidation(client, opts.Channel))
+	case DriverNone:
+		c = Noop{}
+	default:
+		return nil, fmt.Errorf("unknown cache driver %s", opts.Driver)
+	}
+
+	return NewStore(c, opts.TTL, opts.RefreshAhead), nil
+}
+
+// Noop ничего не кеширует: каждый Get - промах.
+type Noop struct{}
+
+func (Noop) Get(context.Context, string) ([]byte, error) {
+	return nil, ErrMiss
+}
+
+func (Noop) Set(context.Context, string, []byte, time.Duration) error {
+	return nil
+}
+
+func (Noop) Delete(context.Context, ...string) error {
+	return nil
+}
+
+func (Noop) Incr(context.Context, string) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultChannel = "cache:invalidate"
+
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// publishScript нумерует сообщение и публикует его за один шаг, поэтому подписчики получают
+// сообщения по порядку номеров, какой бы экземпляр их ни отправил.
+var publishScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+redis.call('PUBLISH', ARGV[1], cjson.encode({s = seq, o = ARGV[2], k = cjson.decode(ARGV[3])}))
+return seq
+`)
+
+type invalidationMessage struct {
+	Seq    int64    `json:"s"`
+	Origin string   `json:"o"`
+	Keys   []string `json:"k"`
+}
+
+// Invalidation сообщает другим экземплярам, какие ключи убрать из их кеша в памяти.
+// Сообщения несут глобальный номер: подписчик, увидевший пропуск или вернувшийся после потери
+// соединения, не может знать, что пропустил, и очищает весь локальный кеш.
+type Invalidation struct {
+	client  *redis.Client
+	channel string
+	seqKey  string
+	origin  string
+}
+
+func NewInvalidation(client *redis.Client, channel string) *Invalidation {
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	origin := make([]byte, 8)
+	_, _ = rand.Read(origin)
+
+	return &Invalidation{
+		client:  client,
+		channel: channel,
+		seqKey:  channel + ":seq",
+		origin:  hex.EncodeToString(origin),
+	}
+}
+
+// Publish просит все остальные экземпляры убрать ключи.
+func (i *Invalidation) Publish(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return publishScript.Run(ctx, i.client, []string{i.seqKey}, i.channel, i.origin, string(data)).Err()
+}
+
+// Listen вытесняет из local ключи, опубликованные другими экземплярами, пока не завершится ctx.
+// Подписка переподключается сама; пока Redis недоступен, Listen повторяет попытки с нарастающей паузой.
+func (i *Invalidation) Listen(ctx context.Context, local *LRU) {
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	go func() {
+		<-ctx.Done()
+		_ = pubsub.Close()
+	}()
+
+	var last int64
+	synced := false
+	backoff := minBackoff
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Опубликованное тем временем потеряно: синхронизация при следующей подписке.
+			synced = false
+			log.Printf("cache: invalidation channel %s: %s\n", i.channel, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = minBackoff
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// Сообщения, отправленные до (пере)подписки, либо известны, либо пропущены:
+			// текущий номер говорит, какие.
+			seq, err := i.sequence(ctx)
+			if err != nil {
+				log.Printf("cache: invalidation sequence: %s\n", err)
+				local.Flush()
+				continue
+			}
+			if !synced && last != 0 && seq != last {
+				local.Flush()
+			}
+			last, synced = seq, true
+		case *redis.Message:
+			var inv invalidationMessage
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+				log.Printf("cache: invalid invalidation message: %s\n", err)
+				continue
+			}
+			last = i.apply(ctx, local, inv, last)
+		}
+	}
+}
+
+// apply вытесняет ключи сообщения из local и возвращает последний известный номер. Пропуск
+// номеров очищает весь local. Сообщение с номером не больше last пришло по подписке, но было
+// опубликовано до чтения номера после неё: номер его уже учёл, а ключи ещё нужно вытеснить.
+func (i *Invalidation) apply(ctx context.Context, local *LRU, inv invalidationMessage, last int64) int64 {
+	switch {
+	case inv.Seq > last+1:
+		local.Flush()
+	case inv.Origin != i.origin:
+		_ = local.Delete(ctx, inv.Keys...)
+	}
+	if inv.Seq > last {
+		last = inv.Seq
+	}
+	return last
+}
+
+func (i *Invalidation) sequence(ctx context.Context) (int64, error) {
+	seq, err := i.client.Get(ctx, i.seqKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return seq, err
+}
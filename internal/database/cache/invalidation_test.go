@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestInvalidationApply(t *testing.T) {
+	const own, other = "own", "other"
+
+	tests := []struct {
+		name     string
+		inv      invalidationMessage
+		last     int64
+		evicted  []string
+		kept     []string
+		wantLast int64
+	}{
+		{"next message", invalidationMessage{Seq: 5, Origin: other, Keys: []string{"a"}}, 4, []string{"a"}, []string{"b", "c"}, 5},
+		{"own message", invalidationMessage{Seq: 5, Origin: own, Keys: []string{"a"}}, 4, nil, []string{"a", "b", "c"}, 5},
+		{"gap", invalidationMessage{Seq: 7, Origin: other, Keys: []string{"a"}}, 4, []string{"a", "b", "c"}, nil, 7},
+		// опубликовано после подписки, но учтено номером, прочитанным после неё
+		{"counted by the sequence", invalidationMessage{Seq: 4, Origin: other, Keys: []string{"b"}}, 5, []string{"b"}, []string{"a", "c"}, 5},
+		{"last counted by the sequence", invalidationMessage{Seq: 5, Origin: other, Keys: []string{"c"}}, 5, []string{"c"}, []string{"a", "b"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			local := NewLRU(10)
+			for _, key := range []string{"a", "b", "c"} {
+				_ = local.Set(ctx, key, []byte(key), 0)
+			}
+			i := &Invalidation{origin: own}
+
+			if last := i.apply(ctx, local, tt.inv, tt.last); last != tt.wantLast {
+				t.Errorf("last = %d, want %d", last, tt.wantLast)
+			}
+			for _, key := range tt.evicted {
+				if _, err := local.Get(ctx, key); !errors.Is(err, ErrMiss) {
+					t.Errorf("%s kept, want it evicted", key)
+				}
+			}
+			for _, key := range tt.kept {
+				if _, err := local.Get(ctx, key); err != nil {
+					t.Errorf("%s evicted, want it kept", key)
+				}
+			}
+		})
+	}
+}
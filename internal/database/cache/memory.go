@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultMemorySize = 10000
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (e *lruEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// LRU - кеш в памяти процесса, вытесняющий давно не использованную запись, когда их становится size.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = defaultMemorySize
+	}
+	return &LRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (l *LRU) Get(_ context.Context, key string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	entry := el.Value.(*lruEntry)
+	if entry.expired(time.Now()) {
+		l.remove(el)
+		return nil, ErrMiss
+	}
+	l.order.MoveToFront(el)
+	return entry.value, nil
+}
+
+func (l *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.set(key, value, ttl)
+	return nil
+}
+
+func (l *LRU) Delete(_ context.Context, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := l.entries[key]; ok {
+			l.remove(el)
+		}
+	}
+	return nil
+}
+
+func (l *LRU) Incr(_ context.Context, key string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var n int64
+	var ttl time.Duration
+	if el, ok := l.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		if !entry.expired(time.Now()) {
+			current, err := strconv.ParseInt(string(entry.value), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			n = current
+			if !entry.expires.IsZero() {
+				ttl = time.Until(entry.expires)
+			}
+		}
+	}
+
+	n++
+	l.set(key, []byte(strconv.FormatInt(n, 10)), ttl)
+	return n, nil
+}
+
+func (l *LRU) set(key string, value []byte, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	if el, ok := l.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		l.order.MoveToFront(el)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *LRU) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.entries, el.Value.(*lruEntry).key)
+}
+
+// Flush удаляет все записи.
+func (l *LRU) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.order.Init()
+	l.entries = make(map[string]*list.Element, l.size)
+}
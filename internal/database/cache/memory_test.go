@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+	_ = l.Set(ctx, "a", []byte("1"), 0)
+	_ = l.Set(ctx, "b", []byte("2"), 0)
+	// чтение делает a недавно использованной, поэтому вытесняется b
+	if _, err := l.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Set(ctx, "c", []byte("3"), 0)
+
+	tests := []struct {
+		key  string
+		want error
+	}{
+		{"a", nil},
+		{"b", ErrMiss},
+		{"c", nil},
+	}
+	for _, tt := range tests {
+		if _, err := l.Get(ctx, tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("Get(%s) err = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(10)
+	_ = l.Set(ctx, "short", []byte("1"), 20*time.Millisecond)
+	_ = l.Set(ctx, "forever", []byte("2"), 0)
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := l.Get(ctx, "short"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expired entry: err = %v, want %v", err, ErrMiss)
+	}
+	if _, err := l.Get(ctx, "forever"); err != nil {
+		t.Errorf("entry without ttl: err = %v", err)
+	}
+}
+
+func TestLRUIncr(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(10)
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := l.Incr(ctx, "counter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Fatalf("Incr = %d, want %d", n, want)
+		}
+	}
+
+	_ = l.Set(ctx, "text", []byte("abc"), 0)
+	if _, err := l.Incr(ctx, "text"); err == nil {
+		t.Error("Incr of a value that is not an integer succeeded")
+	}
+
+	// счётчик сохраняет срок жизни значения, которое увеличивает
+	_ = l.Set(ctx, "expiring", []byte("5"), 20*time.Millisecond)
+	if n, _ := l.Incr(ctx, "expiring"); n != 6 {
+		t.Fatalf("Incr = %d, want 6", n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := l.Get(ctx, "expiring"); !errors.Is(err, ErrMiss) {
+		t.Errorf("incremented entry outlived its ttl: err = %v", err)
+	}
+}
+
+func TestLRUDeleteAndFlush(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(10)
+	for _, key := range []string{"a", "b", "c"} {
+		_ = l.Set(ctx, key, []byte(key), 0)
+	}
+
+	_ = l.Delete(ctx, "a", "missing")
+	if _, err := l.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+		t.Errorf("deleted entry: err = %v, want %v", err, ErrMiss)
+	}
+	if _, err := l.Get(ctx, "b"); err != nil {
+		t.Errorf("kept entry: err = %v", err)
+	}
+
+	l.Flush()
+	for _, key := range []string{"b", "c"} {
+		if _, err := l.Get(ctx, key); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(%s) after Flush: err = %v, want %v", key, err, ErrMiss)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultTTL = time.Hour
+	// refreshTimeout ограничивает фоновое обновление, переживающее запустивший его запрос.
+	refreshTimeout = 30 * time.Second
+)
+
+// Stats считает, что сделал Store с момента создания.
+type Stats struct {
+	Hits      int64
+	Misses    int64
+	Errors    int64
+	Loads     int64
+	Refreshes int64
+}
+
+type metrics struct {
+	hits, misses, errors, loads, refreshes int64
+}
+
+// Store оборачивает кеш метриками и Fetch, который загружает отсутствующее значение один раз,
+// сколько бы вызывающих ни запросили его одновременно.
+type Store struct {
+	cache        Cache
+	ttl          time.Duration
+	refreshAhead float64
+	group        singleflight.Group
+	refreshing   sync.Map
+	metrics      metrics
+}
+
+func NewStore(c Cache, ttl time.Duration, refreshAhead float64) *Store {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Store{
+		cache:        c,
+		ttl:          ttl,
+		refreshAhead: refreshAhead,
+	}
+}
+
+func (s *Store) TTL() time.Duration {
+	return s.ttl
+}
+
+func (s *Store) Stats() Stats {
+	return Stats{
+		Hits:      atomic.LoadInt64(&s.metrics.hits),
+		Misses:    atomic.LoadInt64(&s.metrics.misses),
+		Errors:    atomic.LoadInt64(&s.metrics.errors),
+		Loads:     atomic.LoadInt64(&s.metrics.loads),
+		Refreshes: atomic.LoadInt64(&s.metrics.refreshes),
+	}
+}
+
+// Publish публикует статистику хранилища как expvar с именем name.
+func (s *Store) Publish(name string) {
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			return s.Stats()
+		}))
+	}
+}
+
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.cache.Get(ctx, key)
+	s.count(err)
+	return value, err
+}
+
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	err := s.cache.Set(ctx, key, value, ttl)
+	s.fail(err)
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, keys ...string) error {
+	err := s.cache.Delete(ctx, keys...)
+	s.fail(err)
+	return err
+}
+
+func (s *Store) Incr(ctx context.Context, key string) (int64, error) {
+	n, err := s.cache.Incr(ctx, key)
+	s.fail(err)
+	return n, err
+}
+
+// Listen держит кеш согласованным с другими экземплярами, пока не завершится ctx, если бэкенду
+// есть что согласовывать; иначе сразу возвращается.
+func (s *Store) Listen(ctx context.Context) {
+	if l, ok := s.cache.(interface{ Listen(ctx context.Context) }); ok {
+		l.Listen(ctx)
+	}
+}
+
+// Counter возвращает целое, сохранённое под ключом через Incr, 0, если его нет.
+func (s *Store) Counter(ctx context.Context, key string) (int64, error) {
+	value, err := s.Get(ctx, key)
+	if errors.Is(err, ErrMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// Fetch возвращает значение, закешированное под key, при промахе вызывая load и кешируя результат
+// на ttl, TTL хранилища при 0. Одновременные промахи по ключу делят одну загрузку. Когда от ttl
+// остаётся меньше доли refresh-ahead, возвращается закешированное значение и перезагружается в фоне.
+// Ключи, записанные Fetch, хранят своё истечение и читаются только через Fetch.
+func (s *Store) Fetch(ctx context.Context, key string, ttl time.Duration, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+
+	if raw, err := s.Get(ctx, key); err == nil {
+		if value, expires, ok := unwrap(raw); ok {
+			if s.refreshAhead > 0 && time.Until(expires) < time.Duration(float64(ttl)*s.refreshAhead) {
+				s.refresh(key, ttl, load)
+			}
+			return value, nil
+		}
+	}
+
+	v, err, _ := s.group.Do(key, func() (interface{}, error) {
+		return s.load(ctx, key, ttl, load)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+func (s *Store) refresh(key string, ttl time.Duration, load func(ctx context.Context) ([]byte, error)) {
+	if _, running := s.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	atomic.AddInt64(&s.metrics.refreshes, 1)
+
+	go func() {
+		defer s.refreshing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+
+		_, err, _ := s.group.Do(key, func() (interface{}, error) {
+			return s.load(ctx, key, ttl, load)
+		})
+		if err != nil {
+			log.Printf("cache: refresh of %s failed: %s\n", key, err)
+		}
+	}()
+}
+
+func (s *Store) load(ctx context.Context, key string, ttl time.Duration, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	atomic.AddInt64(&s.metrics.loads, 1)
+	value, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.Set(ctx, key, wrap(value, time.Now().Add(ttl)), ttl)
+	return value, nil
+}
+
+func (s *Store) count(err error) {
+	switch {
+	case err == nil:
+		atomic.AddInt64(&s.metrics.hits, 1)
+	case errors.Is(err, ErrMiss):
+		atomic.AddInt64(&s.metrics.misses, 1)
+	default:
+		atomic.AddInt64(&s.metrics.misses, 1)
+		atomic.AddInt64(&s.metrics.errors, 1)
+	}
+}
+
+func (s *Store) fail(err error) {
+	if err != nil {
+		atomic.AddInt64(&s.metrics.errors, 1)
+	}
+}
+
+// wrap добавляет перед значением время его истечения в наносекундах unix.
+func wrap(value []byte, expires time.Time) []byte {
+	raw := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(raw, uint64(expires.UnixNano()))
+	copy(raw[8:], value)
+	return raw
+}
+
+func unwrap(raw []byte) ([]byte, time.Time, bool) {
+	if len(raw) < 8 {
+		return nil, time.Time{}, false
+	}
+	return raw[8:], time.Unix(0, int64(binary.BigEndian.Uint64(raw))), true
+}
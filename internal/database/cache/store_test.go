@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchLoadsOnce(t *testing.T) {
+	s := NewStore(NewLRU(10), time.Minute, 0)
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("value"), nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, err := s.Fetch(context.Background(), "key", 0, load)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = string(value)
+		}(i)
+	}
+	// все вызывающие должны успеть промахнуться до окончания загрузки
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("load ran %d times, want 1", n)
+	}
+	for i, value := range results {
+		if value != "value" {
+			t.Errorf("caller %d got %q, want value", i, value)
+		}
+	}
+
+	if _, err := s.Fetch(context.Background(), "key", 0, load); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("cached value loaded again, %d loads", n)
+	}
+}
+
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+	s := NewStore(NewLRU(10), time.Minute, 0)
+	boom := errors.New("boom")
+
+	if _, err := s.Fetch(context.Background(), "key", 0, func(context.Context) ([]byte, error) {
+		return nil, boom
+	}); !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want %v", err, boom)
+	}
+
+	value, err := s.Fetch(context.Background(), "key", 0, func(context.Context) ([]byte, error) {
+		return []byte("value"), nil
+	})
+	if err != nil || string(value) != "value" {
+		t.Errorf("Fetch after a failed load = %q, %v; want value", value, err)
+	}
+}
+
+func TestFetchRefreshesAhead(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+	s := NewStore(NewLRU(10), time.Minute, 0.5)
+
+	var version int32
+	load := func(context.Context) ([]byte, error) {
+		return []byte{byte('0' + atomic.AddInt32(&version, 1))}, nil
+	}
+
+	if value, _ := s.Fetch(context.Background(), "key", ttl, load); string(value) != "1" {
+		t.Fatalf("first Fetch = %q, want 1", value)
+	}
+	if value, _ := s.Fetch(context.Background(), "key", ttl, load); string(value) != "1" {
+		t.Fatalf("Fetch before the refresh window = %q, want 1", value)
+	}
+	if n := s.Stats().Refreshes; n != 0 {
+		t.Fatalf("%d refreshes before the refresh window, want 0", n)
+	}
+
+	// в последней половине ttl отдаётся закешированное значение, а новое загружается в фоне
+	time.Sleep(60 * time.Millisecond)
+	if value, _ := s.Fetch(context.Background(), "key", ttl, load); string(value) != "1" {
+		t.Fatalf("Fetch in the refresh window = %q, want the cached 1", value)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		value, _ := s.Fetch(context.Background(), "key", ttl, load)
+		if string(value) == "2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Fetch = %q, want the refreshed 2", value)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := s.Stats().Refreshes; n != 1 {
+		t.Errorf("%d refreshes, want 1", n)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(NewLRU(10), time.Minute, 0)
+
+	if n, err := s.Counter(ctx, "generation"); err != nil || n != 0 {
+		t.Fatalf("Counter of a missing key = %d, %v; want 0", n, err)
+	}
+	_, _ = s.Incr(ctx, "generation")
+	_, _ = s.Incr(ctx, "generation")
+	if n, err := s.Counter(ctx, "generation"); err != nil || n != 2 {
+		t.Errorf("Counter = %d, %v; want 2", n, err)
+	}
+}
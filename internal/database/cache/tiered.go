@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultLocalTTL = 5 * time.Second
+	logInterval     = time.Minute
+)
+
+// Tiered читает через кеш в памяти процесса, стоящий перед общим. Значения остаются в памяти не дольше
+// localTTL, что ограничивает, как долго не видны записи других экземпляров. Когда общий кеш падает,
+// многоуровневый продолжает работать только с памятью, и вызывающие работают, пока Redis недоступен.
+// С инвалидацией удаления и инкременты также вытесняют ключ из памяти других экземпляров.
+type Tiered struct {
+	local        *LRU
+	remote       Cache
+	localTTL     time.Duration
+	invalidation *Invalidation
+	logged       int64
+}
+
+func NewTiered(local *LRU, remote Cache, localTTL time.Duration, invalidation *Invalidation) *Tiered {
+	if localTTL <= 0 {
+		localTTL = defaultLocalTTL
+	}
+	return &Tiered{
+		local:        local,
+		remote:       remote,
+		localTTL:     localTTL,
+		invalidation: invalidation,
+	}
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, err := t.local.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	value, err := t.remote.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrMiss) {
+			t.failed("get", err)
+		}
+		return nil, ErrMiss
+	}
+
+	_ = t.local.Set(ctx, key, value, t.localTTL)
+	return value, nil
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	localTTL := t.localTTL
+	if ttl > 0 && ttl < localTTL {
+		localTTL = ttl
+	}
+	_ = t.local.Set(ctx, key, value, localTTL)
+
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		t.failed("set", err)
+	}
+	return nil
+}
+
+func (t *Tiered) Delete(ctx context.Context, keys ...string) error {
+	_ = t.local.Delete(ctx, keys...)
+
+	if err := t.remote.Delete(ctx, keys...); err != nil {
+		t.failed("delete", err)
+		return err
+	}
+	t.publish(ctx, keys...)
+	return nil
+}
+
+// Incr увеличивает общий счётчик и держит его новое значение в памяти; без общего
+// кеша счётчик живёт только в памяти.
+func (t *Tiered) Incr(ctx context.Context, key string) (int64, error) {
+	n, err := t.remote.Incr(ctx, key)
+	if err != nil {
+		t.failed("incr", err)
+		return t.local.Incr(ctx, key)
+	}
+
+	_ = t.local.Set(ctx, key, []byte(strconv.FormatInt(n, 10)), t.localTTL)
+	t.publish(ctx, key)
+	return n, nil
+}
+
+// Listen вытесняет ключи, инвалидированные другими экземплярами, пока не завершится ctx.
+func (t *Tiered) Listen(ctx context.Context) {
+	if t.invalidation != nil {
+		t.invalidation.Listen(ctx, t.local)
+	}
+}
+
+func (t *Tiered) publish(ctx context.Context, keys ...string) {
+	if t.invalidation == nil {
+		return
+	}
+	if err := t.invalidation.Publish(ctx, keys...); err != nil {
+		t.failed("invalidation", err)
+	}
+}
+
+// failed пишет в лог сбой общего кеша, не чаще раза в минуту.
+func (t *Tiered) failed(op string, err error) {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&t.logged)
+	if now-last < int64(logInterval) || !atomic.CompareAndSwapInt64(&t.logged, last, now) {
+		return
+	}
+	log.Printf("cache: shared cache %s failed, using memory only: %s\n", op, err)
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("connection refused")
+
+// flakyCache - общий кеш в памяти, который можно отключить, как недоступный Redis.
+type flakyCache struct {
+	*LRU
+	mu   sync.Mutex
+	down bool
+}
+
+func newFlakyCache() *flakyCache {
+	return &flakyCache{LRU: NewLRU(10)}
+}
+
+func (f *flakyCache) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *flakyCache) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errDown
+	}
+	return nil
+}
+
+func (f *flakyCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+	return f.LRU.Get(ctx, key)
+}
+
+func (f *flakyCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := f.err(); err != nil {
+		return err
+	}
+	return f.LRU.Set(ctx, key, value, ttl)
+}
+
+func (f *flakyCache) Delete(ctx context.Context, keys ...string) error {
+	if err := f.err(); err != nil {
+		return err
+	}
+	return f.LRU.Delete(ctx, keys...)
+}
+
+func (f *flakyCache) Incr(ctx context.Context, key string) (int64, error) {
+	if err := f.err(); err != nil {
+		return 0, err
+	}
+	return f.LRU.Incr(ctx, key)
+}
+
+func TestTieredReadsThrough(t *testing.T) {
+	ctx := context.Background()
+	remote := newFlakyCache()
+	tiered := NewTiered(NewLRU(10), remote, 20*time.Millisecond, nil)
+
+	_ = remote.Set(ctx, "key", []byte("v1"), 0)
+	if value, err := tiered.Get(ctx, "key"); err != nil || string(value) != "v1" {
+		t.Fatalf("Get = %q, %v; want v1", value, err)
+	}
+
+	// другой экземпляр меняет значение: память отдаёт старое не дольше localTTL
+	_ = remote.Set(ctx, "key", []byte("v2"), 0)
+	if value, _ := tiered.Get(ctx, "key"); string(value) != "v1" {
+		t.Errorf("Get within the local ttl = %q, want v1", value)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if value, _ := tiered.Get(ctx, "key"); string(value) != "v2" {
+		t.Errorf("Get after the local ttl = %q, want v2", value)
+	}
+}
+
+func TestTieredSurvivesSharedCacheFailure(t *testing.T) {
+	ctx := context.Background()
+	remote := newFlakyCache()
+	tiered := NewTiered(NewLRU(10), remote, time.Minute, nil)
+	remote.setDown(true)
+
+	if err := tiered.Set(ctx, "key", []byte("v"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if value, err := tiered.Get(ctx, "key"); err != nil || string(value) != "v" {
+		t.Errorf("Get = %q, %v; want v from memory", value, err)
+	}
+	if _, err := tiered.Get(ctx, "missing"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get of a missing key: err = %v, want %v", err, ErrMiss)
+	}
+	for want := int64(1); want <= 2; want++ {
+		if n, err := tiered.Incr(ctx, "counter"); err != nil || n != want {
+			t.Errorf("Incr = %d, %v; want %d from memory", n, err, want)
+		}
+	}
+	if err := tiered.Delete(ctx, "key"); !errors.Is(err, errDown) {
+		t.Errorf("Delete: err = %v, want %v", err, errDown)
+	}
+	if _, err := tiered.Get(ctx, "key"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get after a failed Delete: err = %v, want the key gone from memory", err)
+	}
+}
+
+func TestTieredIncrKeepsSharedCounter(t *testing.T) {
+	ctx := context.Background()
+	remote := newFlakyCache()
+	first := NewTiered(NewLRU(10), remote, time.Minute, nil)
+	second := NewTiered(NewLRU(10), remote, time.Minute, nil)
+
+	if _, err := first.Incr(ctx, "generation"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := second.Incr(ctx, "generation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Incr on the second instance = %d, want 2", n)
+	}
+	if value, _ := second.Get(ctx, "generation"); string(value) != "2" {
+		t.Errorf("Get = %q, want the new value in memory", value)
+	}
+}
@@ -1,9 +1,18 @@
 package connect
 
 import (
+	"application_template/internal/database/cache"
+	"application_template/internal/integrations/rabbitmq"
+
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 var PostgresDB *gorm.DB
 var RedisDB *redis.Client
+
+// Cache ничего не кеширует, пока сервер не настроит выбранный драйвер.
+var Cache = cache.NewStore(cache.Noop{}, 0, 0)
+
+// RabbitMQ равен nil, если не задан RABBITMQ_ENABLED.
+var RabbitMQ *rabbitmq.Client
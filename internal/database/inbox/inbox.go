@@ -0,0 +1,79 @@
+// Package inbox делает потребителей идемпотентными: обработчик выполняется в транзакции, которая также
+// отмечает сообщение обработанным его потребителем, поэтому повторно доставленное сообщение находит отметку и пропускается.
+package inbox
+
+import (
+	"application_template/internal/worker"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNoMessageId возвращается для сообщения без id, которое нельзя отличить от его дубликатов.
+var ErrNoMessageId = errors.New("inbox: message without an id")
+
+// Message отмечает сообщение, обработанное потребителем.
+type Message struct {
+	MessageId   string    `gorm:"primaryKey"`
+	Consumer    string    `gorm:"primaryKey"`
+	ProcessedAt time.Time `gorm:"index"`
+}
+
+func (Message) TableName() string {
+	return "inbox_messages"
+}
+
+// Process выполняет fn в транзакции, отмечающей сообщение обработанным потребителем, если оно ещё
+// не обработано: иначе fn пропускается и processed равен false. Дубликат, пришедший, пока первая
+// доставка ещё выполняется, ждёт её транзакцию и выполняется, только если та откатилась.
+func Process(ctx context.Context, db *gorm.DB, consumer, messageId string, fn func(tx *gorm.DB) error) (processed bool, err error) {
+	if messageId == "" {
+		return false, ErrNoMessageId
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Message{
+			MessageId:   messageId,
+			Consumer:    consumer,
+			ProcessedAt: time.Now(),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		processed = true
+		return fn(tx)
+	})
+	if err != nil {
+		return false, err
+	}
+	return processed, nil
+}
+
+// Handler возвращает обработчик воркера, обрабатывающий каждое сообщение один раз; потребителем
+// служит имя воркера. fn делает записи через tx; действия вне базы данных не откатываются
+// вместе с ней и сами должны быть идемпотентными.
+func Handler(db *gorm.DB, consumer string, fn func(ctx context.Context, tx *gorm.DB, msg *worker.Message) error) worker.Handler {
+	return func(ctx context.Context, msg *worker.Message) error {
+		_, err := Process(ctx, db, consumer, msg.Id, func(tx *gorm.DB) error {
+			return fn(ctx, tx, msg)
+		})
+		if errors.Is(err, ErrNoMessageId) {
+			return worker.Poison(err)
+		}
+		return err
+	}
+}
+
+// Clean удаляет отметки сообщений, обработанных до cutoff. Он должен быть старше любой
+// повторной доставки, иначе поздний дубликат выполнится снова.
+func Clean(ctx context.Context, db *gorm.DB, before time.Time) (int64, error) {
+	res := db.WithContext(ctx).Where("processed_at < ?", before).Delete(&Message{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,131 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errDryRun = errors.New("dry run")
+
+var ddl = regexp.MustCompile(`(?i)^\s*(create|alter|drop|comment)\s`)
+
+// captureLogger сохраняет DDL запросы, которые выполняет gorm.
+type captureLogger struct {
+	logger.Interface
+	mu         sync.Mutex
+	statements []string
+}
+
+func (l *captureLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *captureLogger) Trace(_ context.Context, _ time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	if err != nil || !ddl.MatchString(sql) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.statements = append(l.statements, strings.TrimSpace(sql))
+}
+
+// Diff возвращает запросы, приводящие текущую схему в соответствие с моделями.
+// AutoMigrate выполняется в транзакции, которая всегда откатывается, поэтому ничего не меняется.
+func (m *Migrator) Diff(ctx context.Context, models []interface{}) (up, down []string, err error) {
+	capture := &captureLogger{Interface: logger.Discard}
+	session := m.db.WithContext(ctx).Session(&gorm.Session{Logger: capture})
+
+	err = session.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(models...); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, nil, err
+	}
+
+	up = capture.statements
+	for i := len(up) - 1; i >= 0; i-- {
+		down = append(down, revert(up[i]))
+	}
+	return up, down, nil
+}
+
+// CreateDiff пишет миграцию, созданную Diff. Если схема актуальна, возвращает пустые пути.
+func (m *Migrator) CreateDiff(ctx context.Context, name string, models []interface{}) (string, string, error) {
+	up, down, err := m.Diff(ctx, models)
+	if err != nil {
+		return "", "", err
+	}
+	if len(up) == 0 {
+		return "", "", nil
+	}
+
+	return m.Create(name, script(up), script(down))
+}
+
+var (
+	createTable      = regexp.MustCompile(`(?i)^create table (?:if not exists )?("?[\w.]+"?)`)
+	createIndex      = regexp.MustCompile(`(?i)^create (?:unique )?index (?:if not exists )?("?\w+"?)`)
+	addColumn        = regexp.MustCompile(`(?i)^alter table ("?[\w.]+"?) add (?:column )?("?\w+"?)`)
+	addConstraint    = regexp.MustCompile(`(?i)^alter table ("?[\w.]+"?) add constraint ("?\w+"?)`)
+	alterColumnType  = regexp.MustCompile(`(?i)^alter table ("?[\w.]+"?) alter column ("?\w+"?) type`)
+	dropNotNull      = regexp.MustCompile(`(?i)^alter table ("?[\w.]+"?) alter column ("?\w+"?) drop not null`)
+	setNotNull       = regexp.MustCompile(`(?i)^alter table ("?[\w.]+"?) alter column ("?\w+"?) set not null`)
+	commentStatement = regexp.MustCompile(`(?i)^comment on`)
+)
+
+// revert угадывает запрос, отменяющий DDL запрос, созданный AutoMigrate.
+func revert(statement string) string {
+	switch {
+	case addConstraint.MatchString(statement):
+		m := addConstraint.FindStringSubmatch(statement)
+		return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", m[1], m[2])
+	case addColumn.MatchString(statement):
+		m := addColumn.FindStringSubmatch(statement)
+		return fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", m[1], m[2])
+	case createTable.MatchString(statement):
+		m := createTable.FindStringSubmatch(statement)
+		return fmt.Sprintf("DROP TABLE IF EXISTS %s", m[1])
+	case createIndex.MatchString(statement):
+		m := createIndex.FindStringSubmatch(statement)
+		return fmt.Sprintf("DROP INDEX IF EXISTS %s", m[1])
+	case dropNotNull.MatchString(statement):
+		m := dropNotNull.FindStringSubmatch(statement)
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", m[1], m[2])
+	case setNotNull.MatchString(statement):
+		m := setNotNull.FindStringSubmatch(statement)
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", m[1], m[2])
+	case commentStatement.MatchString(statement):
+		return "-- nothing to revert for: " + oneLine(statement)
+	case alterColumnType.MatchString(statement):
+		return "-- TODO restore the previous column type for: " + oneLine(statement)
+	}
+	return "-- TODO revert: " + oneLine(statement)
+}
+
+func script(statements []string) string {
+	var b strings.Builder
+	for _, s := range statements {
+		b.WriteString(s)
+		if !strings.HasPrefix(s, "--") {
+			b.WriteString(";")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,306 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey определяет advisory lock, удерживаемый на время миграций, чтобы реплики, запущенные
+// одновременно, применяли их по очереди.
+const lockKey int64 = 0x6d696772617465
+
+const versionFormat = "20060102150405"
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db  *gorm.DB
+	dir string
+}
+
+func New(db *gorm.DB, dir string) *Migrator {
+	return &Migrator{
+		db:  db,
+		dir: dir,
+	}
+}
+
+// Load читает файлы миграций каталога по порядку версий.
+func (m *Migrator) Load() ([]Migration, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := os.ReadFile(filepath.Join(m.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, exists := byVersion[version]
+		if !exists {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+
+		if match[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		result = append(result, *mig)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// Up применяет до steps ожидающих миграций, все, если steps равен 0.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	all, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = m.locked(ctx, func(tx *gorm.DB) error {
+		applied, err := appliedVersions(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range all {
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				if err := exec(tx, mig.Up); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{
+					Version:   mig.Version,
+					Name:      mig.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down откатывает последние steps применённых миграций, одну, если steps равен 0.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	all, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]Migration{}
+	for _, mig := range all {
+		byVersion[mig.Version] = mig
+	}
+
+	var done []Migration
+	err = m.locked(ctx, func(tx *gorm.DB) error {
+		var applied []SchemaMigration
+		if res := tx.Order("version desc").Limit(steps).Find(&applied); res.Error != nil {
+			return res.Error
+		}
+
+		for _, a := range applied {
+			mig, exists := byVersion[a.Version]
+			if !exists {
+				return fmt.Errorf("migration %d_%s has no files", a.Version, a.Name)
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				if err := exec(tx, mig.Down); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, a.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	all, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureTable(m.db.WithContext(ctx)); err != nil {
+		return nil, err
+	}
+	var applied []SchemaMigration
+	if res := m.db.WithContext(ctx).Find(&applied); res.Error != nil {
+		return nil, res.Error
+	}
+	appliedAt := map[int64]time.Time{}
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	result := make([]Status, 0, len(all))
+	for _, mig := range all {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if t, ok := appliedAt[mig.Version]; ok {
+			t := t
+			s.AppliedAt = &t
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// Create пишет пустую пару файлов up/down и возвращает их пути.
+func (m *Migrator) Create(name, up, down string) (string, string, error) {
+	name = normalizeName(name)
+	if name == "" {
+		return "", "", errors.New("migration name is empty")
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return "", "", err
+	}
+
+	version := time.Now().UTC().Format(versionFormat)
+	upPath := filepath.Join(m.dir, fmt.Sprintf("%s_%s.up.sql", version, name))
+	downPath := filepath.Join(m.dir, fmt.Sprintf("%s_%s.down.sql", version, name))
+
+	if err := os.WriteFile(upPath, []byte(up), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(downPath, []byte(down), 0o644); err != nil {
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
+
+// locked выполняет fc на одном соединении, удерживающем advisory lock миграций.
+func (m *Migrator) locked(ctx context.Context, fc func(tx *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if res := conn.Exec("select pg_advisory_lock(?)", lockKey); res.Error != nil {
+			return res.Error
+		}
+		defer conn.Exec("select pg_advisory_unlock(?)", lockKey)
+
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		return fc(conn)
+	})
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`create table if not exists schema_migrations (
+		version bigint primary key,
+		name text not null,
+		applied_at timestamptz not null default now()
+	)`).Error
+}
+
+func appliedVersions(db *gorm.DB) (map[int64]struct{}, error) {
+	var versions []int64
+	if res := db.Model(&SchemaMigration{}).Pluck("version", &versions); res.Error != nil {
+		return nil, res.Error
+	}
+	result := make(map[int64]struct{}, len(versions))
+	for _, v := range versions {
+		result[v] = struct{}{}
+	}
+	return result, nil
+}
+
+func exec(tx *gorm.DB, sql string) error {
+	if strings.TrimSpace(sql) == "" {
+		return nil
+	}
+	return tx.Exec(sql).Error
+}
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+func normalizeName(name string) string {
+	return strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
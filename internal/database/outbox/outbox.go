@@ -0,0 +1,99 @@
+// Package outbox делает доменные события такими же надёжными, как изменения, о которых они сообщают:
+// событие пишется в таблицу outbox в транзакции изменения и публикуется Relay после коммита,
+// поэтому недоступность брокера задерживает события, а не теряет их.
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Действия событий, которые пишет CrudService, добавляются к типу события модели.
+const (
+	Created = "created"
+	Updated = "updated"
+	Deleted = "deleted"
+)
+
+// Event - строка таблицы outbox.
+type Event struct {
+	ID           uint64 `gorm:"primarykey;index:idx_outbox_events_pending,where:sent_at is null"`
+	EventId      string `gorm:"uniqueIndex"`
+	EventType    string
+	EventVersion int
+	Payload      json.RawMessage `gorm:"type:jsonb"`
+	CreatedAt    time.Time
+	SentAt       *time.Time
+	Attempts     int
+	LastError    string
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// Envelope - публикуемое тело события. Before равен null для созданной записи, After - для
+// удалённой; Version - версия схемы снимков.
+type Envelope struct {
+	Id          string          `json:"id"`
+	Type        string          `json:"type"`
+	Version     int             `json:"version"`
+	Aggregate   string          `json:"aggregate"`
+	AggregateId uint            `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Before      json.RawMessage `json:"before"`
+	After       json.RawMessage `json:"after"`
+}
+
+// New возвращает конверт изменения записи id таблицы агрегата с JSON снимками записи
+// до и после него; nil снимок остаётся null.
+func New(eventType string, version int, aggregate string, id uint, before, after interface{}) (Envelope, error) {
+	e := Envelope{
+		Id:          newId(),
+		Type:        eventType,
+		Version:     version,
+		Aggregate:   aggregate,
+		AggregateId: id,
+		OccurredAt:  time.Now().UTC(),
+	}
+
+	var err error
+	if e.Before, err = snapshot(before); err != nil {
+		return e, err
+	}
+	if e.After, err = snapshot(after); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return json.RawMessage("null"), nil
+	}
+	return json.Marshal(v)
+}
+
+// Add пишет событие в outbox через tx, транзакцию изменения, о котором оно сообщает.
+func Add(tx *gorm.DB, e Envelope) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&Event{
+		EventId:      e.Id,
+		EventType:    e.Type,
+		EventVersion: e.Version,
+		Payload:      payload,
+	}).Error
+}
+
+func newId() string {
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
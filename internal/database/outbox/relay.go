@@ -0,0 +1,152 @@
+package outbox
+
+import (
+	"application_template/internal/integrations/rabbitmq"
+	"application_template/pkg/logfmt"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/streadway/amqp"
+	"gorm.io/gorm"
+)
+
+// relayLockKey определяет advisory lock ретранслятора, чтобы публиковала одна реплика за раз
+// и события сохраняли порядок.
+const relayLockKey int64 = 0x6f7574626f78
+
+const defaultBatchSize = 100
+
+// publishTimeout ограничивает ожидание брокера, которое держит транзакцию пачки открытой.
+const publishTimeout = 10 * time.Second
+
+// HeaderVersion передаёт версию схемы события в заголовках сообщения.
+const HeaderVersion = "x-event-version"
+
+type Relay struct {
+	db        *gorm.DB
+	client    *rabbitmq.Client
+	exchange  string
+	batchSize int
+}
+
+// NewRelay возвращает ретранслятор, публикующий в topic exchange с типами событий в качестве ключей маршрутизации.
+func NewRelay(db *gorm.DB, client *rabbitmq.Client, exchange string, batchSize int) *Relay {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Relay{
+		db:        db,
+		client:    client,
+		exchange:  exchange,
+		batchSize: batchSize,
+	}
+}
+
+// Topology объявляет exchange, в который публикует ретранслятор.
+func (r *Relay) Topology() rabbitmq.Topology {
+	return rabbitmq.Topology{
+		Exchanges: []rabbitmq.Exchange{{Name: r.exchange, Kind: amqp.ExchangeTopic, Durable: true}},
+	}
+}
+
+// Poll запускает ретранслятор каждые interval, пока не завершится ctx. Запуск дольше интервала
+// откладывает следующий, а не накладывается на него; пачка, прерванная остановкой, откатывается и
+// публикуется снова при следующем запуске.
+func (r *Relay) Poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		// успешные запуски не логируются, при интервале по умолчанию они заглушили бы остальное
+		if err := r.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logfmt.Event("failed", "relay", r.exchange, "duration", time.Since(start), "error", err)
+		}
+	}
+}
+
+// Run публикует ожидающие события пачками, пока они не кончатся или одна не упадёт.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		published, err := r.batch(ctx)
+		if err != nil || published < r.batchSize {
+			return err
+		}
+	}
+}
+
+// batch публикует самые старые ожидающие события по порядку и отмечает их отправленными. Упавшее событие
+// останавливает пачку, и следующие за ним ждут его. Событие, опубликованное перед тем, как его транзакция
+// не смогла зафиксироваться, публикуется снова: потребители получают события хотя бы один раз.
+func (r *Relay) batch(ctx context.Context) (int, error) {
+	var published int
+	var failure error
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if res := tx.Raw("select pg_try_advisory_xact_lock(?)", relayLockKey).Scan(&locked); res.Error != nil {
+			return res.Error
+		}
+		if !locked {
+			return nil
+		}
+
+		var events []Event
+		if res := tx.Where("sent_at is null").Order("id").Limit(r.batchSize).Find(&events); res.Error != nil {
+			return res.Error
+		}
+
+		ids := make([]uint64, 0, len(events))
+		for _, e := range events {
+			if failure = r.publish(ctx, e); failure != nil {
+				res := tx.Model(&Event{}).Where("id = ?", e.ID).Updates(map[string]interface{}{
+					"attempts":   gorm.Expr("attempts + 1"),
+					"last_error": failure.Error(),
+				})
+				if res.Error != nil {
+					return res.Error
+				}
+				break
+			}
+			ids = append(ids, e.ID)
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+		published = len(ids)
+		return tx.Model(&Event{}).Where("id in ?", ids).Update("sent_at", time.Now()).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, failure
+}
+
+func (r *Relay) publish(ctx context.Context, e Event) error {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	return r.client.Publish(ctx, r.exchange, e.EventType, amqp.Publishing{
+		Headers:      amqp.Table{HeaderVersion: int64(e.EventVersion)},
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    e.EventId,
+		Type:         e.EventType,
+		Timestamp:    e.CreatedAt,
+		Body:         e.Payload,
+	})
+}
+
+// Clean удаляет события, отправленные до cutoff.
+func Clean(ctx context.Context, db *gorm.DB, before time.Time) (int64, error) {
+	res := db.WithContext(ctx).Where("sent_at < ?", before).Delete(&Event{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,318 @@
+package retention
+
+import (
+	"application_template/internal/base/base_postgres"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const defaultBatchSize = 500
+
+// Retainer реализуют мягко удаляемые модели, удалённые строки которых удаляются навсегда,
+// когда они удалены дольше возвращаемой длительности назад. Ноль хранит их всегда.
+type Retainer interface {
+	Retention() time.Duration
+}
+
+// Report сообщает, что очистка удалила из таблицы. Пропущенные строки ещё используются
+// другими строками и остаются, пока те не исчезнут.
+type Report struct {
+	Table   string
+	Deleted int64
+	Skipped int64
+	Archive string
+}
+
+type Purger struct {
+	db        *gorm.DB
+	archive   string
+	batchSize int
+}
+
+// New возвращает очиститель, пишущий удаляемые строки в файлы JSONL в archive, если он не пуст.
+func New(db *gorm.DB, archive string, batchSize int) *Purger {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Purger{
+		db:        db,
+		archive:   archive,
+		batchSize: batchSize,
+	}
+}
+
+type target struct {
+	model     interface{}
+	schema    *schema.Schema
+	retention time.Duration
+}
+
+// Run очищает истёкшие строки каждой модели, реализующей Retainer, сначала ссылающиеся таблицы.
+func (p *Purger) Run(ctx context.Context, models []interface{}) ([]Report, error) {
+	targets, err := p.targets(models)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]Report, 0, len(targets))
+	for _, t := range targets {
+		report, err := p.purge(ctx, t, time.Now().Add(-t.retention))
+		reports = append(reports, report)
+		if err != nil {
+			return reports, fmt.Errorf("purge %s: %w", t.schema.Table, err)
+		}
+	}
+	return reports, nil
+}
+
+// Log пишет в лог, что очистка удалила из каждой затронутой таблицы.
+func Log(reports []Report) {
+	for _, r := range reports {
+		if r.Deleted == 0 && r.Skipped == 0 {
+			continue
+		}
+		log.Printf("retention: %s deleted %d, skipped %d still referenced\n", r.Table, r.Deleted, r.Skipped)
+	}
+}
+
+// targets разбирает хранимые модели и упорядочивает их так, чтобы таблица очищалась раньше таблиц, на которые ссылается.
+func (p *Purger) targets(models []interface{}) ([]target, error) {
+	var targets []target
+	for _, m := range models {
+		r, ok := m.(Retainer)
+		if !ok || r.Retention() <= 0 {
+			continue
+		}
+		st := &gorm.Statement{DB: p.db}
+		if err := st.Parse(m); err != nil {
+			return nil, err
+		}
+		if st.Schema.LookUpField("deleted_at") == nil || st.Schema.PrioritizedPrimaryField == nil {
+			continue
+		}
+		targets = append(targets, target{model: m, schema: st.Schema, retention: r.Retention()})
+	}
+
+	// parents[a][b] означает, что a ссылается на b, поэтому a идёт первой.
+	parents := map[string]map[string]bool{}
+	for _, t := range targets {
+		parents[t.schema.Table] = map[string]bool{}
+	}
+	for _, t := range targets {
+		for _, rel := range t.schema.Relationships.Relations {
+			switch rel.Type {
+			case schema.BelongsTo:
+				if rel.FieldSchema.Table != t.schema.Table {
+					parents[t.schema.Table][rel.FieldSchema.Table] = true
+				}
+			case schema.HasOne, schema.HasMany:
+				if child, ok := parents[rel.FieldSchema.Table]; ok && rel.FieldSchema.Table != t.schema.Table {
+					child[t.schema.Table] = true
+				}
+			}
+		}
+	}
+
+	ordered := make([]target, 0, len(targets))
+	done := map[string]bool{}
+	for len(ordered) < len(targets) {
+		progress := false
+		for _, t := range targets {
+			if done[t.schema.Table] || referenced(t.schema.Table, parents, done) {
+				continue
+			}
+			ordered = append(ordered, t)
+			done[t.schema.Table] = true
+			progress = true
+		}
+		if !progress {
+			// Цикл ссылок: остальные сохраняют порядок, ссылки всё равно проверяются построчно.
+			for _, t := range targets {
+				if !done[t.schema.Table] {
+					ordered = append(ordered, t)
+					done[t.schema.Table] = true
+				}
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// referenced сообщает, ссылается ли на table ещё не очищенная таблица.
+func referenced(table string, parents map[string]map[string]bool, done map[string]bool) bool {
+	for child, refs := range parents {
+		if !done[child] && child != table && refs[table] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Purger) purge(ctx context.Context, t target, cutoff time.Time) (Report, error) {
+	report := Report{Table: t.schema.Table}
+	if p.archive != "" {
+		report.Archive = filepath.Join(p.archive, fmt.Sprintf("%s-%s.jsonl", t.schema.Table, time.Now().Format("20060102")))
+	}
+
+	db := p.db.WithContext(ctx).Session(&gorm.Session{SkipHooks: true})
+	pk := t.schema.PrioritizedPrimaryField.DBName
+
+	var last interface{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		var ids []interface{}
+		q := db.Unscoped().Model(t.model).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+		if last != nil {
+			q = q.Where(pk+" > ?", last)
+		}
+		if res := q.Order(pk).Limit(p.batchSize).Pluck(pk, &ids); res.Error != nil {
+			return report, res.Error
+		}
+		if len(ids) == 0 {
+			return report, nil
+		}
+		last = ids[len(ids)-1]
+
+		deleted, skipped, err := p.batch(db, t, ids, report.Archive)
+		report.Deleted += deleted
+		report.Skipped += skipped
+		if err != nil {
+			return report, err
+		}
+	}
+}
+
+// batch удаляет строки в одной транзакции и архивирует удалённые до её коммита. Когда на строку
+// ещё ссылаются, пачка переходит к построчному удалению под savepoint, оставляя
+// используемые строки вместе с их строками связей.
+func (p *Purger) batch(db *gorm.DB, t target, ids []interface{}, archive string) (deleted, skipped int64, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		rows, ok, err := remove(tx, t, ids, "purge_batch")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			rows = reflect.MakeSlice(reflect.SliceOf(t.schema.ModelType), 0, len(ids))
+			for _, id := range ids {
+				row, ok, err := remove(tx, t, []interface{}{id}, "purge_row")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					skipped++
+					continue
+				}
+				rows = reflect.AppendSlice(rows, row)
+			}
+		}
+
+		deleted = int64(rows.Len())
+		if archive == "" || rows.Len() == 0 {
+			return nil
+		}
+		return write(tx.Statement.Context, t, rows, archive)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return deleted, skipped, nil
+}
+
+// remove отвязывает и удаляет строки под savepoint и возвращает удалённые. Когда на одну из них
+// ещё ссылаются, откатывается к savepoint, включая строки связей, и возвращает false.
+func remove(tx *gorm.DB, t target, ids []interface{}, savepoint string) (reflect.Value, bool, error) {
+	if res := tx.SavePoint(savepoint); res.Error != nil {
+		return reflect.Value{}, false, res.Error
+	}
+	if err := unlink(tx, t, ids); err != nil {
+		return reflect.Value{}, false, err
+	}
+
+	rows := reflect.New(reflect.SliceOf(t.schema.ModelType))
+	res := tx.Unscoped().Clauses(clause.Returning{}).
+		Where(t.schema.PrioritizedPrimaryField.DBName+" IN ?", ids).
+		Delete(rows.Interface())
+	if res.Error == nil {
+		return rows.Elem(), true, nil
+	}
+	if !isForeignKeyViolation(res.Error) {
+		return reflect.Value{}, false, res.Error
+	}
+	if res := tx.RollbackTo(savepoint); res.Error != nil {
+		return reflect.Value{}, false, res.Error
+	}
+	return reflect.Value{}, false, nil
+}
+
+// unlink удаляет строки связей many2many очищаемых строк, которые связывают их только с другими строками.
+func unlink(tx *gorm.DB, t target, ids []interface{}) error {
+	for _, rel := range t.schema.Relationships.Relations {
+		if rel.Type != schema.Many2Many || rel.JoinTable == nil {
+			continue
+		}
+		for _, ref := range rel.References {
+			if !ref.OwnPrimaryKey {
+				continue
+			}
+			res := tx.Exec("DELETE FROM ? WHERE ? IN ?", clause.Table{Name: rel.JoinTable.Table}, clause.Column{Name: ref.ForeignKey.DBName}, ids)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+	}
+	return nil
+}
+
+var archiveMu sync.Mutex
+
+// write дописывает строки как JSON строки, каждая с таблицей и строкой. Как и в экспорте, в строке
+// нет ни паролей, ни скрытых от JSON полей, и читать файл может только владелец.
+func write(ctx context.Context, t target, rows reflect.Value, path string) error {
+	columns := base_postgres.ExportColumns(t.schema)
+
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// файл, оставленный прежней версией, может быть доступен другим
+	if err := f.Chmod(0o600); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for i := 0; i < rows.Len(); i++ {
+		row := make(map[string]interface{}, len(columns))
+		for _, field := range columns {
+			row[field.DBName], _ = field.ValueOf(ctx, rows.Index(i))
+		}
+		if err := enc.Encode(map[string]interface{}{"table": t.schema.Table, "row": row}); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+func isForeignKeyViolation(err error) bool {
+	return base_postgres.PgCode(err) == base_postgres.PgForeignKeyViolation
+}
@@ -0,0 +1,306 @@
+package rabbitmq
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	defaultPoolSize          = 4
+	defaultReconnectDelay    = 500 * time.Millisecond
+	defaultMaxReconnectDelay = 30 * time.Second
+	defaultConfirmTimeout    = 5 * time.Second
+)
+
+type Options struct {
+	// PoolSize - число каналов публикации, открытых между публикациями.
+	PoolSize int
+	// ReconnectDelay - пауза перед первым переподключением, удваивается до MaxReconnectDelay.
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	// ConfirmTimeout ограничивает ожидание подтверждения опубликованного сообщения брокером.
+	ConfirmTimeout time.Duration
+}
+
+// Client держит одно соединение с брокером, переподключаясь при каждой потере
+// и заново объявляя топологию на каждом новом соединении.
+type Client struct {
+	broker Broker
+	opts   Options
+
+	mu       sync.Mutex
+	conn     Connection
+	gen      uint64
+	ready    chan struct{}
+	topology Topology
+	closed   bool
+
+	pool chan *publisher
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// publisher - канал в режиме подтверждений; он публикует по одному сообщению, поэтому следующее
+// подтверждение на нём - подтверждение только что опубликованного сообщения.
+type publisher struct {
+	ch       Channel
+	confirms chan amqp.Confirmation
+	gen      uint64
+}
+
+func New(broker Broker, opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = defaultPoolSize
+	}
+	if opts.ReconnectDelay <= 0 {
+		opts.ReconnectDelay = defaultReconnectDelay
+	}
+	if opts.MaxReconnectDelay < opts.ReconnectDelay {
+		opts.MaxReconnectDelay = defaultMaxReconnectDelay
+	}
+	if opts.ConfirmTimeout <= 0 {
+		opts.ConfirmTimeout = defaultConfirmTimeout
+	}
+
+	return &Client{
+		broker: broker,
+		opts:   opts,
+		ready:  make(chan struct{}),
+		pool:   make(chan *publisher, opts.PoolSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start подключается в фоне и переподключается до Close.
+func (c *Client) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stop = cancel
+	go c.run(ctx)
+}
+
+func (c *Client) run(ctx context.Context) {
+	defer close(c.done)
+
+	delay := c.opts.ReconnectDelay
+	for {
+		closed, err := c.connect()
+		if err != nil {
+			log.Printf("rabbitmq: connect failed, retrying in %s: %s\n", delay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > c.opts.MaxReconnectDelay {
+				delay = c.opts.MaxReconnectDelay
+			}
+			continue
+		}
+		delay = c.opts.ReconnectDelay
+
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-closed:
+			c.disconnected(err)
+		}
+	}
+}
+
+func (c *Client) connect() (chan *amqp.Error, error) {
+	conn, err := c.broker.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.declare(conn, c.topology); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	c.conn = conn
+	c.gen++
+	close(c.ready)
+	log.Println("rabbitmq: connected")
+	return closed, nil
+}
+
+func (c *Client) disconnected(err *amqp.Error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn = nil
+	c.ready = make(chan struct{})
+	log.Printf("rabbitmq: connection lost: %v\n", err)
+}
+
+func (c *Client) declare(conn Connection, t Topology) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	return t.declare(ch)
+}
+
+// Declare добавляет exchange и очереди в топологию, сразу объявляя их при подключении.
+func (c *Client) Declare(t Topology) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.topology.add(t)
+	if c.conn == nil {
+		return nil
+	}
+	return c.declare(c.conn, t)
+}
+
+// connection ждёт, пока клиент подключится.
+func (c *Client) connection(ctx context.Context) (Connection, uint64, error) {
+	for {
+		c.mu.Lock()
+		conn, gen, ready, closed := c.conn, c.gen, c.ready, c.closed
+		c.mu.Unlock()
+
+		switch {
+		case closed:
+			return nil, 0, ErrClosed
+		case conn != nil:
+			return conn, gen, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-ready:
+		}
+	}
+}
+
+// Publish отправляет сообщение и ждёт его подтверждения брокером, при необходимости сначала переподключаясь.
+func (c *Client) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	p, err := c.publisher(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := p.ch.Publish(exchange, key, false, false, msg); err != nil {
+		_ = p.ch.Close()
+		return err
+	}
+
+	timer := time.NewTimer(c.opts.ConfirmTimeout)
+	defer timer.Stop()
+
+	select {
+	case confirm, ok := <-p.confirms:
+		if !ok {
+			return ErrNotConfirmed
+		}
+		c.release(p)
+		if !confirm.Ack {
+			return ErrNacked
+		}
+		return nil
+	case <-ctx.Done():
+		// позднее подтверждение приняли бы за подтверждение следующего сообщения: канал закрывается
+		_ = p.ch.Close()
+		return ctx.Err()
+	case <-timer.C:
+		_ = p.ch.Close()
+		return ErrNotConfirmed
+	}
+}
+
+func (c *Client) publisher(ctx context.Context) (*publisher, error) {
+	conn, gen, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for p := c.pooled(); p != nil; p = c.pooled() {
+		if p.gen == gen {
+			return p, nil
+		}
+		_ = p.ch.Close()
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+	return &publisher{
+		ch:       ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		gen:      gen,
+	}, nil
+}
+
+// pooled берёт свободный канал публикации, nil, если его нет.
+func (c *Client) pooled() *publisher {
+	select {
+	case p := <-c.pool:
+		return p
+	default:
+		return nil
+	}
+}
+
+// release оставляет канал для следующей публикации, пока в пуле есть место и соединение то же.
+func (c *Client) release(p *publisher) {
+	c.mu.Lock()
+	current := p.gen == c.gen && c.conn != nil && !c.closed
+	c.mu.Unlock()
+
+	if current {
+		select {
+		case c.pool <- p:
+			return
+		default:
+		}
+	}
+	_ = p.ch.Close()
+}
+
+// Close прекращает переподключение и закрывает соединение. Потребителей нужно остановить раньше.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	if c.stop != nil {
+		c.stop()
+		<-c.done
+	}
+
+	for p := c.pooled(); p != nil; p = c.pooled() {
+		_ = p.ch.Close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		// будим ожидающих соединения, они получат ErrClosed
+		close(c.ready)
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
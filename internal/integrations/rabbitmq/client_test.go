@@ -0,0 +1,250 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	testQueue     = "jobs"
+	testDeadQueue = "jobs.dead"
+	testDLX       = "jobs.dlx"
+)
+
+var testTopology = Topology{
+	Exchanges: []Exchange{{Name: testDLX, Kind: amqp.ExchangeFanout}},
+	Queues: []Queue{
+		{Name: testQueue, Args: amqp.Table{ArgDeadLetterExchange: testDLX}},
+		{Name: testDeadQueue, Bindings: []Binding{{Exchange: testDLX}}},
+	},
+}
+
+func newTestClient(t *testing.T, broker Broker, opts Options) *Client {
+	t.Helper()
+	if opts.ReconnectDelay == 0 {
+		opts.ReconnectDelay = 10 * time.Millisecond
+	}
+	c := New(broker, opts)
+	if err := c.Declare(testTopology); err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func publish(t *testing.T, c *Client, id string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Publish(ctx, "", testQueue, amqp.Publishing{MessageId: id, Body: []byte(id)}); err != nil {
+		t.Fatalf("publish %s: %v", id, err)
+	}
+}
+
+// eventually ждёт cond и проваливает тест, если условие не выполнилось за несколько секунд.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// consume запускает потребителя до конца теста и возвращает функцию его остановки, которая ждёт
+// возврата Consume.
+func consume(t *testing.T, c *Client, opts ConsumerOptions, handler Handler) (stop func()) {
+	t.Helper()
+	if opts.Queue == "" {
+		opts.Queue = testQueue
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Consume(ctx, opts, handler) }()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("Consume: %v", err)
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// connected ждёт, пока у клиента не будет gen-е соединение.
+func connected(t *testing.T, c *Client, gen uint64) {
+	t.Helper()
+	eventually(t, "the connection", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.conn != nil && c.gen == gen
+	})
+}
+
+func TestPublishReconnects(t *testing.T) {
+	m := NewMemory()
+	c := newTestClient(t, m, Options{})
+
+	publish(t, c, "before")
+	m.Disconnect()
+	connected(t, c, 2)
+	// канал из пула принадлежит потерянному соединению: клиент не должен его использовать
+	publish(t, c, "after")
+
+	if n := m.Len(testQueue); n != 2 {
+		t.Fatalf("queue holds %d messages, want 2", n)
+	}
+}
+
+func TestDeclareAfterConnect(t *testing.T) {
+	m := NewMemory()
+	c := newTestClient(t, m, Options{})
+	connected(t, c, 1)
+
+	err := c.Declare(Topology{Queues: []Queue{{Name: "late"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Publish(ctx, "", "late", amqp.Publishing{Body: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Len("late"); n != 1 {
+		t.Fatalf("late queue holds %d messages, want 1", n)
+	}
+}
+
+// confirmBroker оборачивает Memory, отвечая на публикации подтверждениями confirm: false
+// оставляет публикацию неподтверждённой.
+type confirmBroker struct {
+	*Memory
+	confirm func() (ack bool, ok bool)
+}
+
+type confirmConnection struct {
+	Connection
+	b *confirmBroker
+}
+
+type confirmChannel struct {
+	Channel
+	b        *confirmBroker
+	tag      uint64
+	confirms chan amqp.Confirmation
+}
+
+func (b *confirmBroker) Dial() (Connection, error) {
+	conn, err := b.Memory.Dial()
+	if err != nil {
+		return nil, err
+	}
+	return &confirmConnection{Connection: conn, b: b}, nil
+}
+
+func (c *confirmConnection) Channel() (Channel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return &confirmChannel{Channel: ch, b: c.b}, nil
+}
+
+func (ch *confirmChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.confirms = confirm
+	return confirm
+}
+
+func (ch *confirmChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if err := ch.Channel.Publish(exchange, key, mandatory, immediate, msg); err != nil {
+		return err
+	}
+	ch.tag++
+	if ack, ok := ch.b.confirm(); ok {
+		ch.confirms <- amqp.Confirmation{DeliveryTag: ch.tag, Ack: ack}
+	}
+	return nil
+}
+
+func TestPublishConfirms(t *testing.T) {
+	tests := []struct {
+		name    string
+		ack, ok bool
+		want    error
+	}{
+		{"acked", true, true, nil},
+		{"nacked", false, true, ErrNacked},
+		{"not confirmed", false, false, ErrNotConfirmed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &confirmBroker{Memory: NewMemory(), confirm: func() (bool, bool) { return tt.ack, tt.ok }}
+			c := newTestClient(t, b, Options{ConfirmTimeout: 50 * time.Millisecond})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err := c.Publish(ctx, "", testQueue, amqp.Publishing{Body: []byte("x")})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishNackKeepsChannel(t *testing.T) {
+	var mu sync.Mutex
+	acks := []bool{false, true}
+	b := &confirmBroker{Memory: NewMemory(), confirm: func() (bool, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		ack := acks[0]
+		acks = acks[1:]
+		return ack, true
+	}}
+	c := newTestClient(t, b, Options{PoolSize: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Publish(ctx, "", testQueue, amqp.Publishing{}); !errors.Is(err, ErrNacked) {
+		t.Fatalf("err = %v, want %v", err, ErrNacked)
+	}
+	// подтверждение отклонённого сообщения прочитано, поэтому следующее совпадает со своим
+	if err := c.Publish(ctx, "", testQueue, amqp.Publishing{}); err != nil {
+		t.Fatalf("publish after a nack: %v", err)
+	}
+}
+
+func TestPublishWaitsForConnection(t *testing.T) {
+	c := New(NewMemory(), Options{})
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Publish(ctx, "", testQueue, amqp.Publishing{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPublishAfterClose(t *testing.T) {
+	c := newTestClient(t, NewMemory(), Options{})
+	publish(t, c, "open")
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Publish(context.Background(), "", testQueue, amqp.Publishing{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("err = %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,210 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var consumerSeq uint64
+
+const (
+	defaultDrainTimeout = 30 * time.Second
+	resubscribeDelay    = time.Second
+)
+
+// Handler обрабатывает одно сообщение. nil подтверждает сообщение, ошибка возвращает его в очередь,
+// если она не обёрнута Discard.
+type Handler func(ctx context.Context, d amqp.Delivery) error
+
+type discardError struct {
+	err error
+}
+
+func (e discardError) Error() string {
+	return e.err.Error()
+}
+
+func (e discardError) Unwrap() error {
+	return e.err
+}
+
+// Discard отклоняет сообщение без возврата в очередь: оно уходит в dead letter exchange
+// очереди, если он есть, иначе отбрасывается.
+func Discard(err error) error {
+	return discardError{err: err}
+}
+
+func IsDiscarded(err error) bool {
+	var d discardError
+	return errors.As(err, &d)
+}
+
+type ConsumerOptions struct {
+	Queue string
+	// Name - тег потребителя, по умолчанию имя очереди с порядковым номером.
+	Name string
+	// Concurrency ограничивает число одновременно обрабатываемых сообщений, по умолчанию 1.
+	Concurrency int
+	// DrainTimeout - сколько обработчики, работающие при остановке, могут завершаться,
+	// прежде чем их контекст отменят.
+	DrainTimeout time.Duration
+}
+
+// Consume обрабатывает сообщения очереди, пока не завершится ctx, подписываясь заново после каждого
+// переподключения. Обработчики, прерванные потерей соединения, держат свои места, пока не вернутся, поэтому
+// ограничение параллельности сохраняется между подписками. При остановке перестаёт брать сообщения и ждёт
+// работающие обработчики, чтобы их сообщения были подтверждены до закрытия канала.
+func (c *Client) Consume(ctx context.Context, opts ConsumerOptions, handler Handler) error {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = defaultDrainTimeout
+	}
+	if opts.Name == "" {
+		opts.Name = fmt.Sprintf("%s-%d", opts.Queue, atomic.AddUint64(&consumerSeq, 1))
+	}
+
+	handlers := &handlers{sem: make(chan struct{}, opts.Concurrency)}
+	handlers.ctx, handlers.cancel = context.WithCancel(context.Background())
+	defer handlers.drain(opts.DrainTimeout)
+
+	for {
+		conn, _, err := c.connection(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		err = c.consume(ctx, conn, opts, handlers, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("rabbitmq: consumer of %s stopped, subscribing again: %v\n", opts.Queue, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+// handlers отслеживает работающие обработчики потребителя.
+type handlers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+}
+
+func (c *Client) consume(ctx context.Context, conn Connection, opts ConsumerOptions, h *handlers, handler Handler) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	// при остановке канал закрывается после дренажа, который откладывает Consume
+	defer func() {
+		if ctx.Err() == nil {
+			_ = ch.Close()
+			return
+		}
+		go func() {
+			h.wg.Wait()
+			_ = ch.Close()
+		}()
+	}()
+
+	if err := ch.Qos(opts.Concurrency, 0, false); err != nil {
+		return err
+	}
+	deliveries, err := ch.Consume(opts.Queue, opts.Name, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		// место берётся раньше сообщения, чтобы занятый потребитель не задерживал остановку
+		select {
+		case <-ctx.Done():
+			_ = ch.Cancel(opts.Name, false)
+			return nil
+		case h.sem <- struct{}{}:
+		}
+
+		select {
+		case <-ctx.Done():
+			<-h.sem
+			_ = ch.Cancel(opts.Name, false)
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				<-h.sem
+				return fmt.Errorf("deliveries of %s closed", opts.Queue)
+			}
+
+			h.wg.Add(1)
+			go func() {
+				defer func() {
+					<-h.sem
+					h.wg.Done()
+				}()
+				handle(h.ctx, d, handler)
+			}()
+		}
+	}
+}
+
+// drain ждёт работающие обработчики, отменяя их контекст по истечении timeout.
+func (h *handlers) drain(timeout time.Duration) {
+	defer h.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		h.cancel()
+		<-done
+	}
+}
+
+// handle подтверждает или отклоняет сообщение по результату обработчика. Упавший с паникой обработчик
+// упал бы снова на том же сообщении, поэтому его сообщение отбрасывается.
+func handle(ctx context.Context, d amqp.Delivery, handler Handler) {
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = Discard(fmt.Errorf("handler panic: %v", r))
+			}
+		}()
+		err = handler(ctx, d)
+	}()
+
+	switch {
+	case err == nil:
+		err = d.Ack(false)
+	case IsDiscarded(err):
+		log.Printf("rabbitmq: discarding message %s from %s: %s\n", d.MessageId, d.RoutingKey, err)
+		err = d.Nack(false, false)
+	default:
+		err = d.Nack(false, true)
+	}
+	if err != nil {
+		log.Printf("rabbitmq: failed to acknowledge message %s: %s\n", d.MessageId, err)
+	}
+}
@@ -0,0 +1,190 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestConsumeOutcomes(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(d amqp.Delivery) error
+		calls   int32
+		dead    int
+	}{
+		{"ack", func(amqp.Delivery) error { return nil }, 1, 0},
+		{"requeue", func(d amqp.Delivery) error {
+			if !d.Redelivered {
+				return errors.New("try again")
+			}
+			return nil
+		}, 2, 0},
+		{"discard", func(amqp.Delivery) error { return Discard(errors.New("malformed")) }, 1, 1},
+		{"panic", func(amqp.Delivery) error { panic("boom") }, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory()
+			c := newTestClient(t, m, Options{})
+
+			var calls int32
+			stop := consume(t, c, ConsumerOptions{}, func(_ context.Context, d amqp.Delivery) error {
+				atomic.AddInt32(&calls, 1)
+				return tt.handler(d)
+			})
+			publish(t, c, "1")
+
+			eventually(t, "the handler", func() bool { return atomic.LoadInt32(&calls) == tt.calls })
+			eventually(t, "the dead letter", func() bool { return m.Len(testDeadQueue) == tt.dead })
+			stop()
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			// закрытие соединения возвращает в очередь неподтверждённое
+			if n := m.Len(testQueue); n != 0 {
+				t.Errorf("queue holds %d messages, want 0", n)
+			}
+			if n := atomic.LoadInt32(&calls); n != tt.calls {
+				t.Errorf("handler called %d times, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestConsumeConcurrency(t *testing.T) {
+	const concurrency, messages = 3, 12
+
+	c := newTestClient(t, NewMemory(), Options{})
+	for i := 0; i < messages; i++ {
+		publish(t, c, "m")
+	}
+
+	var running, peak, handled int32
+	consume(t, c, ConsumerOptions{Concurrency: concurrency}, func(context.Context, amqp.Delivery) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+
+	eventually(t, "all messages", func() bool { return atomic.LoadInt32(&handled) == messages })
+	if p := atomic.LoadInt32(&peak); p != concurrency {
+		t.Errorf("%d handlers ran at once, want %d", p, concurrency)
+	}
+}
+
+func TestConsumeResubscribes(t *testing.T) {
+	m := NewMemory()
+	c := newTestClient(t, m, Options{})
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var seen []amqp.Delivery
+	consume(t, c, ConsumerOptions{}, func(_ context.Context, d amqp.Delivery) error {
+		mu.Lock()
+		seen = append(seen, d)
+		first := len(seen) == 1
+		mu.Unlock()
+		if first {
+			started <- struct{}{}
+			<-release
+		}
+		return nil
+	})
+	publish(t, c, "cut")
+	<-started
+
+	// подтверждение работающего обработчика теряется с соединением, поэтому его сообщение приходит снова
+	m.Disconnect()
+	close(release)
+	connected(t, c, 2)
+	publish(t, c, "next")
+
+	eventually(t, "the deliveries after the reconnect", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen) == 3
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	got := map[string]bool{}
+	for _, d := range seen[1:] {
+		got[d.MessageId] = d.Redelivered
+	}
+	if redelivered, ok := got["cut"]; !ok || !redelivered {
+		t.Errorf("cut message redelivered = %v, %v; want true, true", redelivered, ok)
+	}
+	if _, ok := got["next"]; !ok {
+		t.Error("message published after the reconnect was not delivered")
+	}
+}
+
+func TestConsumeDrain(t *testing.T) {
+	m := NewMemory()
+	c := newTestClient(t, m, Options{})
+
+	started := make(chan struct{})
+	var finished int32
+	stop := consume(t, c, ConsumerOptions{DrainTimeout: time.Second}, func(ctx context.Context, _ amqp.Delivery) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	})
+	publish(t, c, "1")
+	<-started
+
+	stop()
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Fatal("Consume returned before the running handler finished")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Len(testQueue); n != 0 {
+		t.Errorf("queue holds %d messages, want the drained one acked", n)
+	}
+}
+
+func TestConsumeDrainTimeout(t *testing.T) {
+	m := NewMemory()
+	c := newTestClient(t, m, Options{})
+
+	started := make(chan struct{})
+	stop := consume(t, c, ConsumerOptions{DrainTimeout: 30 * time.Millisecond}, func(ctx context.Context, _ amqp.Delivery) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	publish(t, c, "1")
+	<-started
+
+	begin := time.Now()
+	stop()
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("drain took %s, want about the drain timeout", elapsed)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Len(testQueue); n != 1 {
+		t.Errorf("queue holds %d messages, want the cancelled one requeued", n)
+	}
+}
@@ -0,0 +1,783 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Memory - брокер в памяти процесса с поведением RabbitMQ, на которое опирается клиент: exchange
+// direct, fanout и topic, prefetch, подтверждения, возврат в очередь, dead letter и TTL сообщений.
+// Публикации подтверждаются сразу. Слушатели закрытия должны быть буферизованы, как с настоящим соединением.
+type Memory struct {
+	mu        sync.Mutex
+	exchanges map[string]*memExchange
+	queues    map[string]*memQueue
+	conns     map[*memConnection]struct{}
+	seq       uint64
+}
+
+type memExchange struct {
+	kind     string
+	bindings []memBinding
+}
+
+type memBinding struct {
+	queue string
+	key   string
+}
+
+type memQueue struct {
+	name      string
+	args      amqp.Table
+	ready     []*memMessage
+	consumers []*memConsumer
+	next      int
+}
+
+type memMessage struct {
+	msg         amqp.Publishing
+	exchange    string
+	key         string
+	redelivered bool
+	expiry      *time.Timer
+}
+
+type memConnection struct {
+	m        *Memory
+	channels map[*memChannel]struct{}
+	closers  []chan *amqp.Error
+	closed   bool
+}
+
+type memChannel struct {
+	conn      *memConnection
+	prefetch  int
+	consumers map[string]*memConsumer
+	tag       uint64
+	unacked   map[uint64]*memUnacked
+	closers   []chan *amqp.Error
+	closed    bool
+
+	confirmMu      sync.Mutex
+	confirm        bool
+	published      uint64
+	confirms       []chan amqp.Confirmation
+	confirmsClosed bool
+}
+
+type memUnacked struct {
+	message  *memMessage
+	queue    *memQueue
+	consumer *memConsumer
+}
+
+type memConsumer struct {
+	tag      string
+	ch       *memChannel
+	queue    *memQueue
+	autoAck  bool
+	prefetch int
+	unacked  int
+	pending  []amqp.Delivery
+	out      chan amqp.Delivery
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		exchanges: map[string]*memExchange{},
+		queues:    map[string]*memQueue{},
+		conns:     map[*memConnection]struct{}{},
+	}
+}
+
+func (m *Memory) Dial() (Connection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn := &memConnection{m: m, channels: map[*memChannel]struct{}{}}
+	m.conns[conn] = struct{}{}
+	return conn, nil
+}
+
+// Disconnect обрывает все соединения, как перезапуск брокера. Exchange, очереди и их
+// сообщения сохраняются, неподтверждённые сообщения возвращаются в очереди.
+func (m *Memory) Disconnect() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for conn := range m.conns {
+		m.closeConnection(conn, &amqp.Error{Code: amqp.ConnectionForced, Reason: "CONNECTION_FORCED - disconnected", Server: true})
+	}
+}
+
+// Len возвращает число сообщений очереди, готовых к доставке.
+func (m *Memory) Len(queue string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if q, ok := m.queues[queue]; ok {
+		return len(q.ready)
+	}
+	return 0
+}
+
+func (m *Memory) name(prefix string) string {
+	m.seq++
+	return prefix + strconv.FormatUint(m.seq, 10)
+}
+
+func notFound(format string, args ...interface{}) *amqp.Error {
+	return &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - " + fmt.Sprintf(format, args...), Server: true}
+}
+
+func (m *Memory) closeConnection(conn *memConnection, err *amqp.Error) {
+	if conn.closed {
+		return
+	}
+	conn.closed = true
+	delete(m.conns, conn)
+
+	for ch := range conn.channels {
+		m.closeChannel(ch, err)
+	}
+	notifyClose(conn.closers, err)
+}
+
+// closeChannel отменяет потребителей канала и возвращает в очередь его неподтверждённые сообщения.
+func (m *Memory) closeChannel(ch *memChannel, err *amqp.Error) {
+	if ch.closed {
+		return
+	}
+	ch.closed = true
+	delete(ch.conn.channels, ch)
+
+	for _, c := range ch.consumers {
+		m.cancel(c)
+	}
+
+	tags := make([]uint64, 0, len(ch.unacked))
+	for tag := range ch.unacked {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	m.settle(ch, tags, func(u *memUnacked) { u.queue.requeue(u.message) })
+
+	notifyClose(ch.closers, err)
+
+	ch.confirmMu.Lock()
+	ch.confirmsClosed = true
+	for _, c := range ch.confirms {
+		close(c)
+	}
+	ch.confirmMu.Unlock()
+}
+
+func notifyClose(receivers []chan *amqp.Error, err *amqp.Error) {
+	for _, r := range receivers {
+		if err != nil {
+			select {
+			case r <- err:
+			default:
+			}
+		}
+		close(r)
+	}
+}
+
+// cancel останавливает потребителя. Ещё не выданные им сообщения возвращаются в очередь,
+// выданные остаются неподтверждёнными на канале.
+func (m *Memory) cancel(c *memConsumer) {
+	delete(c.ch.consumers, c.tag)
+	q := c.queue
+	for i, other := range q.consumers {
+		if other == c {
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			break
+		}
+	}
+	close(c.done)
+
+	tags := make([]uint64, 0, len(c.pending))
+	for _, d := range c.pending {
+		tags = append(tags, d.DeliveryTag)
+	}
+	c.pending = nil
+	m.settle(c.ch, tags, func(u *memUnacked) { u.queue.requeue(u.message) })
+}
+
+// settle убирает доставки из неподтверждённых сообщений канала, передавая каждую fn,
+// и раздаёт сообщения очередей, где освободилось место.
+func (m *Memory) settle(ch *memChannel, tags []uint64, fn func(u *memUnacked)) {
+	queues := map[*memQueue]struct{}{}
+	// возвращённые сообщения встают в начало очереди в исходном порядке
+	for i := len(tags) - 1; i >= 0; i-- {
+		u, ok := ch.unacked[tags[i]]
+		if !ok {
+			continue
+		}
+		delete(ch.unacked, tags[i])
+		u.consumer.unacked--
+		fn(u)
+		queues[u.queue] = struct{}{}
+	}
+	for q := range queues {
+		m.dispatch(q)
+	}
+}
+
+// dispatch раздаёт готовые сообщения очереди её потребителям по очереди, насколько позволяет
+// их prefetch.
+func (m *Memory) dispatch(q *memQueue) {
+	for len(q.ready) > 0 {
+		c := q.pick()
+		if c == nil {
+			return
+		}
+		message := q.ready[0]
+		q.ready = q.ready[1:]
+		if message.expiry != nil {
+			message.expiry.Stop()
+			message.expiry = nil
+		}
+		c.deliver(message)
+	}
+}
+
+func (q *memQueue) pick() *memConsumer {
+	for i := range q.consumers {
+		c := q.consumers[(q.next+i)%len(q.consumers)]
+		if c.autoAck || c.prefetch == 0 || c.unacked < c.prefetch {
+			q.next = (q.next + i + 1) % len(q.consumers)
+			return c
+		}
+	}
+	return nil
+}
+
+func (q *memQueue) requeue(message *memMessage) {
+	message.redelivered = true
+	q.ready = append([]*memMessage{message}, q.ready...)
+}
+
+func (c *memConsumer) deliver(message *memMessage) {
+	ch := c.ch
+	ch.tag++
+	if !c.autoAck {
+		ch.unacked[ch.tag] = &memUnacked{message: message, queue: c.queue, consumer: c}
+		c.unacked++
+	}
+
+	msg := message.msg
+	c.pending = append(c.pending, amqp.Delivery{
+		Acknowledger:    ch,
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		ConsumerTag:     c.tag,
+		DeliveryTag:     ch.tag,
+		Redelivered:     message.redelivered,
+		Exchange:        message.exchange,
+		RoutingKey:      message.key,
+		Body:            msg.Body,
+	})
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump передаёт доставки потребителю вне блокировки брокера.
+func (c *memConsumer) pump(m *Memory) {
+	defer close(c.out)
+
+	for {
+		m.mu.Lock()
+		if len(c.pending) == 0 {
+			m.mu.Unlock()
+			select {
+			case <-c.wake:
+				continue
+			case <-c.done:
+				return
+			}
+		}
+		d := c.pending[0]
+		c.pending = c.pending[1:]
+		m.mu.Unlock()
+
+		select {
+		case c.out <- d:
+		case <-c.done:
+			m.mu.Lock()
+			m.settle(c.ch, []uint64{d.DeliveryTag}, func(u *memUnacked) { u.queue.requeue(u.message) })
+			m.mu.Unlock()
+			return
+		}
+	}
+}
+
+// route возвращает очереди, в которые exchange отправляет ключ маршрутизации.
+func (m *Memory) route(exchange, key string) ([]*memQueue, error) {
+	if exchange == "" {
+		if q, ok := m.queues[key]; ok {
+			return []*memQueue{q}, nil
+		}
+		return nil, nil
+	}
+
+	e, ok := m.exchanges[exchange]
+	if !ok {
+		return nil, notFound("no exchange '%s'", exchange)
+	}
+
+	var queues []*memQueue
+	seen := map[string]bool{}
+	for _, b := range e.bindings {
+		if seen[b.queue] {
+			continue
+		}
+		var match bool
+		switch e.kind {
+		case amqp.ExchangeFanout:
+			match = true
+		case amqp.ExchangeTopic:
+			match = topicMatch(strings.Split(b.key, "."), strings.Split(key, "."))
+		default:
+			match = b.key == key
+		}
+		if match {
+			seen[b.queue] = true
+			queues = append(queues, m.queues[b.queue])
+		}
+	}
+	return queues, nil
+}
+
+// topicMatch сопоставляет слова ключа маршрутизации с шаблоном привязки, где * означает
+// одно слово, а # - любое число слов.
+func topicMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatch(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatch(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && topicMatch(pattern[1:], words[1:])
+	}
+}
+
+// publish кладёт сообщение в каждую очередь, в которую его направляет exchange.
+func (m *Memory) publish(exchange, key string, msg amqp.Publishing) error {
+	queues, err := m.route(exchange, key)
+	if err != nil {
+		return err
+	}
+	for _, q := range queues {
+		m.enqueue(q, &memMessage{msg: msg, exchange: exchange, key: key})
+	}
+	return nil
+}
+
+func (m *Memory) enqueue(q *memQueue, message *memMessage) {
+	q.ready = append(q.ready, message)
+	m.dispatch(q)
+
+	ttl, ok := messageTTL(q, message.msg)
+	if !ok || message.expiry != nil || !q.holds(message) {
+		return
+	}
+	message.expiry = time.AfterFunc(ttl, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.expire(q, message)
+	})
+}
+
+func (q *memQueue) holds(message *memMessage) bool {
+	for _, other := range q.ready {
+		if other == message {
+			return true
+		}
+	}
+	return false
+}
+
+// messageTTL - меньшее из истечения сообщения и x-message-ttl очереди.
+func messageTTL(q *memQueue, msg amqp.Publishing) (time.Duration, bool) {
+	ttl := int64(-1)
+	if msg.Expiration != "" {
+		if ms, err := strconv.ParseInt(msg.Expiration, 10, 64); err == nil {
+			ttl = ms
+		}
+	}
+	var queueTTL int64 = -1
+	switch v := q.args["x-message-ttl"].(type) {
+	case int:
+		queueTTL = int64(v)
+	case int32:
+		queueTTL = int64(v)
+	case int64:
+		queueTTL = v
+	}
+	if queueTTL >= 0 && (ttl < 0 || queueTTL < ttl) {
+		ttl = queueTTL
+	}
+	return time.Duration(ttl) * time.Millisecond, ttl >= 0
+}
+
+func (m *Memory) expire(q *memQueue, message *memMessage) {
+	for i, other := range q.ready {
+		if other == message {
+			q.ready = append(q.ready[:i], q.ready[i+1:]...)
+			message.expiry = nil
+			m.deadLetter(q, message, "expired")
+			return
+		}
+	}
+}
+
+// deadLetter публикует отклонённое или истёкшее сообщение в dead letter exchange его
+// очереди с заголовком x-death и отбрасывает его, если у очереди его нет.
+func (m *Memory) deadLetter(q *memQueue, message *memMessage, reason string) {
+	exchange, ok := q.args[ArgDeadLetterExchange].(string)
+	if !ok {
+		return
+	}
+	key := message.key
+	if k, ok := q.args[ArgDeadLetterRoutingKey].(string); ok {
+		key = k
+	}
+
+	msg := message.msg
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers["x-death"] = addDeath(headers["x-death"], q.name, reason, message)
+	if _, ok := headers["x-first-death-queue"]; !ok {
+		headers["x-first-death-queue"] = q.name
+		headers["x-first-death-reason"] = reason
+		headers["x-first-death-exchange"] = message.exchange
+	}
+	msg.Headers = headers
+	// как делает RabbitMQ, чтобы сообщение не истекло снова в следующей очереди
+	msg.Expiration = ""
+
+	_ = m.publish(exchange, key, msg)
+}
+
+func addDeath(header interface{}, queue, reason string, message *memMessage) []interface{} {
+	deaths, _ := header.([]interface{})
+	for i, d := range deaths {
+		t, ok := d.(amqp.Table)
+		if !ok || t["queue"] != queue || t["reason"] != reason {
+			continue
+		}
+		count, _ := t["count"].(int64)
+		updated := amqp.Table{}
+		for k, v := range t {
+			updated[k] = v
+		}
+		updated["count"] = count + 1
+		updated["time"] = time.Now()
+		rest := append(append([]interface{}{}, deaths[:i]...), deaths[i+1:]...)
+		return append([]interface{}{updated}, rest...)
+	}
+
+	death := amqp.Table{
+		"count":        int64(1),
+		"reason":       reason,
+		"queue":        queue,
+		"exchange":     message.exchange,
+		"routing-keys": []interface{}{message.key},
+		"time":         time.Now(),
+	}
+	return append([]interface{}{death}, deaths...)
+}
+
+func (c *memConnection) Channel() (Channel, error) {
+	m := c.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch := &memChannel{conn: c, consumers: map[string]*memConsumer{}, unacked: map[uint64]*memUnacked{}}
+	c.channels[ch] = struct{}{}
+	return ch, nil
+}
+
+func (c *memConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if c.closed {
+		close(receiver)
+	} else {
+		c.closers = append(c.closers, receiver)
+	}
+	return receiver
+}
+
+func (c *memConnection) Close() error {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if c.closed {
+		return amqp.ErrClosed
+	}
+	c.m.closeConnection(c, nil)
+	return nil
+}
+
+// do выполняет fn на открытом канале, закрывая канал при ошибке fn, как сделал бы сервер.
+func (ch *memChannel) do(fn func(m *Memory) error) error {
+	m := ch.conn.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	err := fn(m)
+	if e, ok := err.(*amqp.Error); ok {
+		m.closeChannel(ch, e)
+	}
+	return err
+}
+
+func (ch *memChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return ch.do(func(m *Memory) error {
+		switch kind {
+		case amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeTopic:
+		default:
+			return &amqp.Error{Code: amqp.NotImplemented, Reason: "NOT_IMPLEMENTED - exchange type " + kind, Server: true}
+		}
+		if e, ok := m.exchanges[name]; ok {
+			if e.kind != kind {
+				return &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - inequivalent arg 'type' for exchange '" + name + "'", Server: true}
+			}
+			return nil
+		}
+		m.exchanges[name] = &memExchange{kind: kind}
+		return nil
+	})
+}
+
+func (ch *memChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	var queue amqp.Queue
+	err := ch.do(func(m *Memory) error {
+		if name == "" {
+			name = m.name("amq.gen-")
+		}
+		q, ok := m.queues[name]
+		if !ok {
+			q = &memQueue{name: name, args: args}
+			m.queues[name] = q
+		}
+		queue = amqp.Queue{Name: name, Messages: len(q.ready), Consumers: len(q.consumers)}
+		return nil
+	})
+	return queue, err
+}
+
+func (ch *memChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return ch.do(func(m *Memory) error {
+		if _, ok := m.queues[name]; !ok {
+			return notFound("no queue '%s'", name)
+		}
+		e, ok := m.exchanges[exchange]
+		if !ok {
+			return notFound("no exchange '%s'", exchange)
+		}
+		b := memBinding{queue: name, key: key}
+		for _, other := range e.bindings {
+			if other == b {
+				return nil
+			}
+		}
+		e.bindings = append(e.bindings, b)
+		return nil
+	})
+}
+
+// Qos задаёт prefetch потребителей, запускаемых на канале после него.
+func (ch *memChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return ch.do(func(m *Memory) error {
+		ch.prefetch = prefetchCount
+		return nil
+	})
+}
+
+func (ch *memChannel) Confirm(noWait bool) error {
+	return ch.do(func(m *Memory) error {
+		ch.confirmMu.Lock()
+		ch.confirm = true
+		ch.confirmMu.Unlock()
+		return nil
+	})
+}
+
+func (ch *memChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.confirmMu.Lock()
+	defer ch.confirmMu.Unlock()
+
+	if ch.confirmsClosed {
+		close(confirm)
+	} else {
+		ch.confirms = append(ch.confirms, confirm)
+	}
+	return confirm
+}
+
+func (ch *memChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	m := ch.conn.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ch.closed {
+		close(receiver)
+	} else {
+		ch.closers = append(ch.closers, receiver)
+	}
+	return receiver
+}
+
+// Publish маршрутизирует сообщение и в режиме подтверждений сразу подтверждает его слушателям.
+func (ch *memChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	err := ch.do(func(m *Memory) error {
+		return m.publish(exchange, key, msg)
+	})
+	if err != nil {
+		return err
+	}
+
+	ch.confirmMu.Lock()
+	defer ch.confirmMu.Unlock()
+	if !ch.confirm || ch.confirmsClosed {
+		return nil
+	}
+	ch.published++
+	for _, c := range ch.confirms {
+		c <- amqp.Confirmation{DeliveryTag: ch.published, Ack: true}
+	}
+	return nil
+}
+
+func (ch *memChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	var out chan amqp.Delivery
+	err := ch.do(func(m *Memory) error {
+		q, ok := m.queues[queue]
+		if !ok {
+			return notFound("no queue '%s'", queue)
+		}
+		if consumer == "" {
+			consumer = m.name("amq.ctag-")
+		}
+		if _, ok := ch.consumers[consumer]; ok {
+			return &amqp.Error{Code: amqp.NotAllowed, Reason: "NOT_ALLOWED - attempt to reuse consumer tag '" + consumer + "'", Server: true}
+		}
+
+		c := &memConsumer{
+			tag:      consumer,
+			ch:       ch,
+			queue:    q,
+			autoAck:  autoAck,
+			prefetch: ch.prefetch,
+			out:      make(chan amqp.Delivery),
+			wake:     make(chan struct{}, 1),
+			done:     make(chan struct{}),
+		}
+		ch.consumers[consumer] = c
+		q.consumers = append(q.consumers, c)
+		out = c.out
+		go c.pump(m)
+		m.dispatch(q)
+		return nil
+	})
+	return out, err
+}
+
+// Cancel останавливает потребителя и закрывает его доставки; выданные им сообщения ещё можно подтвердить.
+func (ch *memChannel) Cancel(consumer string, noWait bool) error {
+	return ch.do(func(m *Memory) error {
+		if c, ok := ch.consumers[consumer]; ok {
+			m.cancel(c)
+		}
+		return nil
+	})
+}
+
+func (ch *memChannel) Close() error {
+	return ch.do(func(m *Memory) error {
+		m.closeChannel(ch, nil)
+		return nil
+	})
+}
+
+func (ch *memChannel) Ack(tag uint64, multiple bool) error {
+	return ch.settle(tag, multiple, func(m *Memory, u *memUnacked) {})
+}
+
+// Nack возвращает сообщения в очередь или, без requeue, отправляет их в dead letter.
+func (ch *memChannel) Nack(tag uint64, multiple, requeue bool) error {
+	return ch.settle(tag, multiple, func(m *Memory, u *memUnacked) {
+		if requeue {
+			u.queue.requeue(u.message)
+		} else {
+			m.deadLetter(u.queue, u.message, "rejected")
+		}
+	})
+}
+
+func (ch *memChannel) Reject(tag uint64, requeue bool) error {
+	return ch.Nack(tag, false, requeue)
+}
+
+func (ch *memChannel) settle(tag uint64, multiple bool, fn func(m *Memory, u *memUnacked)) error {
+	return ch.do(func(m *Memory) error {
+		var tags []uint64
+		if multiple {
+			for t := range ch.unacked {
+				if t <= tag {
+					tags = append(tags, t)
+				}
+			}
+			sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+		} else if _, ok := ch.unacked[tag]; ok {
+			tags = []uint64{tag}
+		}
+		if len(tags) == 0 {
+			return &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - unknown delivery tag " + strconv.FormatUint(tag, 10), Server: true}
+		}
+		m.settle(ch, tags, func(u *memUnacked) { fn(m, u) })
+		return nil
+	})
+}
@@ -0,0 +1,85 @@
+// Package rabbitmq поддерживает соединение с RabbitMQ, публикует с подтверждениями и потребляет
+// с ограниченной параллельностью. Брокер за Client - интерфейс: Dial подключается к настоящему
+// серверу, NewMemory - замена в памяти процесса для тестов и локальной разработки.
+package rabbitmq
+
+import (
+	"application_template/internal/config"
+	"errors"
+	"strings"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrClosed возвращается после закрытия клиента.
+	ErrClosed = errors.New("rabbitmq: client closed")
+	// ErrNacked возвращается, когда брокер отказывается от опубликованного сообщения.
+	ErrNacked = errors.New("rabbitmq: message nacked")
+	// ErrNotConfirmed возвращается, когда брокер не подтверждает опубликованное сообщение вовремя.
+	ErrNotConfirmed = errors.New("rabbitmq: message not confirmed")
+)
+
+// Broker открывает соединения с брокером.
+type Broker interface {
+	Dial() (Connection, error)
+}
+
+type Connection interface {
+	Channel() (Channel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Channel - часть *amqp.Channel, которую использует клиент.
+type Channel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Close() error
+}
+
+type dialer struct {
+	url string
+}
+
+// Dial возвращает брокер из конфигурации.
+func Dial(conf config.RabbitMQ) Broker {
+	vhost := strings.TrimPrefix(conf.RabbitMQVHost, "/")
+	uri := amqp.URI{
+		Scheme:   "amqp",
+		Host:     conf.RabbitMQHost,
+		Port:     conf.RabbitMQPort,
+		Username: conf.RabbitMQUser,
+		Password: conf.RabbitMQPass,
+		Vhost:    "/" + vhost,
+	}
+	return &dialer{url: uri.String()}
+}
+
+func (d *dialer) Dial() (Connection, error) {
+	conn, err := amqp.Dial(d.url)
+	if err != nil {
+		return nil, err
+	}
+	return amqpConnection{conn}, nil
+}
+
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c amqpConnection) Channel() (Channel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
@@ -0,0 +1,64 @@
+package rabbitmq
+
+import "github.com/streadway/amqp"
+
+const (
+	// ArgDeadLetterExchange отправляет в exchange сообщения, которые очередь отклоняет без возврата.
+	ArgDeadLetterExchange = "x-dead-letter-exchange"
+	// ArgDeadLetterRoutingKey заменяет ключ маршрутизации сообщений в dead letter.
+	ArgDeadLetterRoutingKey = "x-dead-letter-routing-key"
+)
+
+type Exchange struct {
+	Name string
+	// Kind - direct, fanout, topic или headers.
+	Kind       string
+	Durable    bool
+	AutoDelete bool
+	Args       amqp.Table
+}
+
+type Queue struct {
+	Name       string
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+	Args       amqp.Table
+	Bindings   []Binding
+}
+
+type Binding struct {
+	Exchange string
+	Key      string
+}
+
+// Topology - набор exchange и очередей, которые клиент объявляет на каждом соединении.
+type Topology struct {
+	Exchanges []Exchange
+	Queues    []Queue
+}
+
+func (t *Topology) add(other Topology) {
+	t.Exchanges = append(t.Exchanges, other.Exchanges...)
+	t.Queues = append(t.Queues, other.Queues...)
+}
+
+// declare сначала объявляет exchange, чтобы к ним можно было привязать очереди.
+func (t Topology) declare(ch Channel) error {
+	for _, e := range t.Exchanges {
+		if err := ch.ExchangeDeclare(e.Name, e.Kind, e.Durable, e.AutoDelete, false, false, e.Args); err != nil {
+			return err
+		}
+	}
+	for _, q := range t.Queues {
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, q.Args); err != nil {
+			return err
+		}
+		for _, b := range q.Bindings {
+			if err := ch.QueueBind(q.Name, b.Key, b.Exchange, false, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors - сокращения, допустимые вместо пяти полей.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = field{name: "minute", min: 0, max: 59}
+	hours   = field{name: "hour", min: 0, max: 23}
+	days    = field{name: "day of month", min: 1, max: 31}
+	months  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	weekdays = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// maxYears ограничивает поиск следующего времени, за ним расписание не срабатывает.
+const maxYears = 5
+
+// Schedule - разобранное выражение cron: минута, час, день месяца, месяц и день недели, каждое -
+// список значений, диапазонов и шагов, например "0,30", "9-17", "*/15" или "mon-fri". Когда ограничены
+// оба дня, срабатывает время, подходящее под любой, как в cron.
+type Schedule struct {
+	expr                              string
+	minute, hour, day, month, weekday uint64
+	anyDay, anyWeekday                bool
+}
+
+// Parse разбирает выражение cron из пяти полей или одно из @yearly, @monthly, @weekly, @daily и @hourly.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields, got %d", expr, len(parts))
+	}
+
+	s := &Schedule{
+		expr:       expr,
+		anyDay:     strings.HasPrefix(parts[2], "*"),
+		anyWeekday: strings.HasPrefix(parts[4], "*"),
+	}
+	var err error
+	for i, f := range []struct {
+		field field
+		bits  *uint64
+	}{
+		{minutes, &s.minute},
+		{hours, &s.hour},
+		{days, &s.day},
+		{months, &s.month},
+		{weekdays, &s.weekday},
+	} {
+		if *f.bits, err = f.field.parse(parts[i]); err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	// 7 - тоже воскресенье
+	if s.weekday&(1<<7) != 0 {
+		s.weekday |= 1
+	}
+
+	if s.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("cron %q: never fires", expr)
+	}
+	return s, nil
+}
+
+func (f field) parse(spec string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(spec, ",") {
+		rng, step, hasStep := strings.Cut(item, "/")
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(to); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/15" шагает от 5 до конца диапазона
+				hi = f.max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("%s range %q is reversed", f.name, rng)
+		}
+
+		n := 1
+		if hasStep {
+			var err error
+			if n, err = strconv.Atoi(step); err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s step %q is not a positive number", f.name, step)
+			}
+		}
+		for v := lo; v <= hi; v += n {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s %q is not a number", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %d is out of %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next возвращает первое время расписания после t, в зоне t; нулевое, если его нет
+// в ближайшие годы. Время, пропущенное переходом на летнее время, не срабатывает.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + maxYears
+
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+		case !s.matchDay(t):
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// forward возвращает next, если он не попал в пропуск перехода на летнее время, который time.Date
+// нормализовал обратно к t или раньше; тогда - час после t, за пропуском.
+func forward(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Hour)
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	day := s.day&(1<<uint(t.Day())) != 0
+	weekday := s.weekday&(1<<uint(t.Weekday())) != 0
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"step", "*/15 * * * *", date(2024, 6, 1, 10, 7), date(2024, 6, 1, 10, 15)},
+		{"step from a value", "5/20 * * * *", date(2024, 6, 1, 10, 26), date(2024, 6, 1, 10, 45)},
+		{"stepped range", "10-30/10 * * * *", date(2024, 6, 1, 10, 31), date(2024, 6, 1, 11, 10)},
+		{"list", "0,30 * * * *", date(2024, 6, 1, 10, 0), date(2024, 6, 1, 10, 30)},
+		{"range wraps to the next day", "0 9-17 * * *", date(2024, 6, 1, 17, 30), date(2024, 6, 2, 9, 0)},
+		{"seconds are dropped", "@hourly", date(2024, 6, 1, 10, 59).Add(30 * time.Second), date(2024, 6, 1, 11, 0)},
+		{"month names", "0 12 * jan,jul *", date(2024, 6, 1, 0, 0), date(2024, 7, 1, 12, 0)},
+		// 2024-06-08 - суббота
+		{"weekday range", "0 0 * * mon-fri", date(2024, 6, 8, 0, 0), date(2024, 6, 10, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", date(2024, 6, 1, 0, 0), date(2024, 6, 2, 0, 0)},
+		{"day of month", "0 0 13 * *", date(2024, 6, 1, 0, 0), date(2024, 6, 13, 0, 0)},
+		// оба дня ограничены: подходит любой, пятница 7-го раньше 13-го
+		{"day of month or weekday", "0 0 13 * 5", date(2024, 6, 1, 0, 0), date(2024, 6, 7, 0, 0)},
+		// день месяца с шагом по звёздочке не ограничен: нужен понедельник 1, 11, 21 или 31 числа
+		{"stepped day and weekday", "0 0 */10 * mon", date(2024, 6, 1, 0, 0), date(2024, 7, 1, 0, 0)},
+		{"short month", "0 0 31 * *", date(2024, 4, 1, 0, 0), date(2024, 5, 31, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(2024, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"too few fields", "* * * *"},
+		{"too many fields", "* * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"month out of range", "* * * 13 *"},
+		{"weekday out of range", "* * * * 8"},
+		{"reversed range", "30-10 * * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"not a number", "x * * * *"},
+		{"unknown name", "* * * foo *"},
+		{"never fires", "0 0 30 2 *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.expr); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.expr)
+			}
+		})
+	}
+}
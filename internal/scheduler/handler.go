@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"application_template/internal/base/base_postgres"
+	"application_template/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultRunsLimit = 50
+	maxRunsLimit     = 500
+)
+
+// Handler выводит задачи и их запуски и запускает их вручную.
+type Handler struct {
+	scheduler   *Scheduler
+	Middlewares []gin.HandlerFunc
+}
+
+func NewHandler(scheduler *Scheduler) *Handler {
+	return &Handler{scheduler: scheduler}
+}
+
+func (h *Handler) Register(r *gin.RouterGroup, s string) *gin.RouterGroup {
+	g := r.Group(s, h.Middlewares...)
+	g.GET("", base_postgres.AppHandler(h.Jobs).Handle)
+	g.GET(":name/runs", base_postgres.AppHandler(h.Runs).Handle)
+	g.POST(":name/run", base_postgres.AppHandler(h.Trigger).Handle)
+	return g
+}
+
+func (h *Handler) Jobs(ctx *gin.Context) *base_postgres.AppError {
+	jobs, err := h.scheduler.Jobs(ctx)
+	if err != nil {
+		return base_postgres.LocalizeError(ctx, err)
+	}
+	return base_postgres.Ok(ctx, jobs)
+}
+
+// Runs выводит последние запуски задачи, ?limit штук.
+func (h *Handler) Runs(ctx *gin.Context) *base_postgres.AppError {
+	limit := defaultRunsLimit
+	if l := ctx.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return base_postgres.ErrBadRequest(ctx, errors.New("exception:failed-to-parse"), map[string]interface{}{
+				"Value": l,
+				"Type":  "limit",
+			})
+		}
+		limit = n
+	}
+	if limit > maxRunsLimit {
+		limit = maxRunsLimit
+	}
+
+	runs, err := h.scheduler.Runs(ctx, ctx.Param("name"), limit)
+	if err != nil {
+		return jobError(ctx, err)
+	}
+	return base_postgres.Ok(ctx, runs)
+}
+
+// Trigger запускает задачу и отвечает, когда запуск записан, не дожидаясь его окончания.
+func (h *Handler) Trigger(ctx *gin.Context) *base_postgres.AppError {
+	run, err := h.scheduler.Trigger(ctx, ctx.Param("name"))
+	if err != nil {
+		return jobError(ctx, err)
+	}
+	ctx.JSON(http.StatusAccepted, run)
+	return nil
+}
+
+func jobError(ctx *gin.Context, err error) *base_postgres.AppError {
+	data := map[string]interface{}{"Job": ctx.Param("name")}
+	switch {
+	case errors.Is(err, ErrUnknownJob):
+		appErr := base_postgres.LocalizeError(ctx, utils.NewLocalizeError(err, "exception:job-not-found", data))
+		appErr.Code = http.StatusNotFound
+		return appErr
+	case errors.Is(err, ErrLocked):
+		appErr := base_postgres.LocalizeError(ctx, utils.NewLocalizeError(err, "exception:job-running", data))
+		appErr.Code = http.StatusConflict
+		return appErr
+	case errors.Is(err, ErrClosed):
+		appErr := base_postgres.LocalizeError(ctx, utils.NewLocalizeError(err, "exception:scheduler-closed", nil))
+		appErr.Code = http.StatusServiceUnavailable
+		return appErr
+	}
+	return base_postgres.LocalizeError(ctx, err)
+}
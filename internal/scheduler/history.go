@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Что начало запуск.
+const (
+	TriggerSchedule = "schedule"
+	TriggerCatchUp  = "catch-up"
+	TriggerManual   = "manual"
+)
+
+// Статусы запуска.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	// StatusAbandoned отмечает запуск, реплика которого потеряла блокировку, не завершив его;
+	// его находит следующий владелец блокировки.
+	StatusAbandoned = "abandoned"
+)
+
+// JobRun - строка истории запусков. Запуски задачи по расписанию уникальны по времени:
+// реплики, проснувшиеся к одному времени, записывают один запуск на всех; ручные запуски - нет.
+type JobRun struct {
+	ID           uint64     `gorm:"primarykey" json:"id"`
+	Job          string     `gorm:"uniqueIndex:idx_job_runs_slot,where:trigger <> 'manual';index:idx_job_runs_job_started_at" json:"job"`
+	ScheduledAt  time.Time  `gorm:"uniqueIndex:idx_job_runs_slot,where:trigger <> 'manual'" json:"scheduled_at"`
+	Trigger      string     `json:"trigger"`
+	Status       string     `json:"status"`
+	StartedAt    time.Time  `gorm:"index:idx_job_runs_job_started_at" json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	Error        string     `json:"error"`
+	FencingToken int64      `json:"fencing_token"`
+	Replica      string     `json:"replica"`
+}
+
+func (JobRun) TableName() string {
+	return "job_runs"
+}
+
+// lastScheduled возвращает время последнего запуска задачи по расписанию, нулевое, если его нет.
+func lastScheduled(ctx context.Context, db *gorm.DB, job string) (time.Time, error) {
+	var last *time.Time
+	res := db.WithContext(ctx).Model(&JobRun{}).
+		Where("job = ? and trigger <> ?", job, TriggerManual).
+		Select("max(scheduled_at)").
+		Scan(&last)
+	if res.Error != nil || last == nil {
+		return time.Time{}, res.Error
+	}
+	return *last, nil
+}
+
+// Clean удаляет завершённые запуски, начатые до cutoff, кроме последнего запуска по расписанию
+// каждой задачи: по нему планировщик находит запуски, пропущенные, пока он не работал.
+func Clean(ctx context.Context, db *gorm.DB, before time.Time) (int64, error) {
+	latest := db.Model(&JobRun{}).
+		Select("distinct on (job) id").
+		Where("trigger <> ?", TriggerManual).
+		Order("job, scheduled_at desc")
+
+	res := db.WithContext(ctx).
+		Where("started_at < ? and status <> ?", before, StatusRunning).
+		Where("id not in (?)", latest).
+		Delete(&JobRun{})
+	return res.RowsAffected, res.Error
+}
+
+// JobInfo описывает зарегистрированную задачу.
+type JobInfo struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	CatchUp  CatchUp   `json:"catch_up"`
+	NextRun  time.Time `json:"next_run"`
+	LastRun  *JobRun   `json:"last_run"`
+}
+
+// Jobs описывает зарегистрированные задачи с их последним запуском.
+func (s *Scheduler) Jobs(ctx context.Context) ([]JobInfo, error) {
+	var runs []JobRun
+	res := s.db.WithContext(ctx).
+		Where("id in (?)", s.db.Model(&JobRun{}).Select("max(id)").Group("job")).
+		Find(&runs)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	last := map[string]*JobRun{}
+	for i := range runs {
+		last[runs[i].Job] = &runs[i]
+	}
+
+	now := s.now()
+	jobs := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, JobInfo{
+			Name:     j.Name,
+			Schedule: j.schedule.String(),
+			CatchUp:  j.CatchUp,
+			NextRun:  j.schedule.Next(now),
+			LastRun:  last[j.Name],
+		})
+	}
+	return jobs, nil
+}
+
+// Runs возвращает последние запуски задачи, новые первыми.
+func (s *Scheduler) Runs(ctx context.Context, name string, limit int) ([]JobRun, error) {
+	if _, ok := s.names[name]; !ok {
+		return nil, ErrUnknownJob
+	}
+	runs := []JobRun{}
+	res := s.db.WithContext(ctx).
+		Where("job = ?", name).
+		Order("started_at desc, id desc").
+		Limit(limit).
+		Find(&runs)
+	return runs, res.Error
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker выдаёт блокировку задачи одной реплике за раз. Каждая выдача идёт с fencing
+// токеном больше прежних токенов задачи, поэтому запись владельца, потерявшего
+// блокировку незаметно для себя, например замерев дольше её срока, можно отличить от записей
+// следующего владельца.
+type Locker interface {
+	// Acquire берёт блокировку задачи на ttl и возвращает её токен, 0, если её держит другой.
+	Acquire(ctx context.Context, job string, ttl time.Duration) (int64, error)
+	// Refresh продлевает блокировку на ttl, false, если token её больше не держит.
+	Refresh(ctx context.Context, job string, token int64, ttl time.Duration) (bool, error)
+	// Release освобождает блокировку, если token ещё её держит.
+	Release(ctx context.Context, job string, token int64) error
+}
+
+// acquireScript берёт блокировку и получает её токен из счётчика задачи за один шаг, поэтому
+// токен никогда не выдаётся без блокировки.
+var acquireScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], token, 'PX', ARGV[1])
+return token
+`)
+
+var refreshScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker хранит блокировки в Redis. Блокировка и счётчик токенов задачи делят hash
+// tag, поэтому скрипты, работающие с обоими, работают и на кластере. Счётчики не истекают: токен
+// не должен уменьшаться.
+type RedisLocker struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisLocker(client *redis.Client, prefix string) *RedisLocker {
+	if prefix == "" {
+		prefix = "scheduler"
+	}
+	return &RedisLocker{client: client, prefix: prefix}
+}
+
+func (l *RedisLocker) keys(job string) []string {
+	return []string{
+		fmt.Sprintf("%s:{%s}:lock", l.prefix, job),
+		fmt.Sprintf("%s:{%s}:fence", l.prefix, job),
+	}
+}
+
+func (l *RedisLocker) Acquire(ctx context.Context, job string, ttl time.Duration) (int64, error) {
+	return acquireScript.Run(ctx, l.client, l.keys(job), ttl.Milliseconds()).Int64()
+}
+
+func (l *RedisLocker) Refresh(ctx context.Context, job string, token int64, ttl time.Duration) (bool, error) {
+	n, err := refreshScript.Run(ctx, l.client, l.keys(job)[:1], token, ttl.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+func (l *RedisLocker) Release(ctx context.Context, job string, token int64) error {
+	return releaseScript.Run(ctx, l.client, l.keys(job)[:1], token).Err()
+}
@@ -0,0 +1,520 @@
+// Package scheduler выполняет задачи модулей по расписаниям cron, каждый запуск на одной реплике:
+// реплики просыпаются вместе, та, что взяла блокировку задачи в Redis, выполняет её и записывает
+// запуск в Postgres, где история также говорит запускающейся реплике, какие запуски она пропустила.
+package scheduler
+
+import (
+	"application_template/pkg/logfmt"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUnknownJob возвращается при запуске незарегистрированной задачи.
+	ErrUnknownJob = errors.New("scheduler: unknown job")
+	// ErrLocked возвращается при запуске задачи, выполняющейся на реплике.
+	ErrLocked = errors.New("scheduler: job is running")
+	// ErrClosed возвращается при запуске задачи после закрытия планировщика.
+	ErrClosed = errors.New("scheduler: closed")
+	// ErrLockLost - причина отмены запуска, блокировку которого не удалось удержать.
+	ErrLockLost = errors.New("scheduler: lock lost")
+
+	// errRecorded возвращается, когда реплика уже записала запуск по расписанию.
+	errRecorded = errors.New("scheduler: run already recorded")
+)
+
+// CatchUp - что задача делает с пропущенными запусками, пока не работала ни одна реплика или пока
+// её предыдущий запуск длился дольше них.
+type CatchUp string
+
+const (
+	// CatchUpSkip отбрасывает пропущенные запуски и ждёт следующего времени расписания.
+	CatchUpSkip CatchUp = "skip"
+	// CatchUpOnce выполняется один раз за все пропущенные запуски, для задач, делающих всё накопившееся.
+	CatchUpOnce CatchUp = "once"
+	// CatchUpAll выполняется за каждый пропущенный запуск, старые первыми, до maxCatchUp последних.
+	CatchUpAll CatchUp = "all"
+)
+
+const (
+	// grace - насколько поздно может начаться запуск, оставаясь вовремя.
+	grace      = time.Minute
+	maxCatchUp = 100
+
+	// recordTimeout ограничивает запись окончания запуска и освобождение его блокировки, которые
+	// должны произойти, даже если запуск отменён.
+	recordTimeout = 10 * time.Second
+)
+
+// Job - задача, выполняемая по расписанию cron.
+type Job struct {
+	Name string
+	// Schedule - выражение cron, см. Parse.
+	Schedule string
+	// CatchUp по умолчанию CatchUpSkip.
+	CatchUp CatchUp
+	// Timeout ограничивает запуск, 0 не ограничивает.
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+type job struct {
+	Job
+	schedule *Schedule
+}
+
+type Options struct {
+	// Location - часовой пояс расписаний, по умолчанию UTC.
+	Location *time.Location
+	// LockTTL - насколько блокировка запуска переживает реплику, умершую во время него; реплика,
+	// выполняющая задачу, продлевает её каждую треть срока.
+	LockTTL time.Duration
+	// DrainTimeout - сколько Close ждёт запуски, прежде чем отменить их.
+	DrainTimeout time.Duration
+}
+
+type Scheduler struct {
+	db      *gorm.DB
+	locker  Locker
+	opts    Options
+	replica string
+
+	jobs  []*job
+	names map[string]*job
+
+	// ctx - контекст запусков, отменяемый Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	closed bool
+	runs   sync.WaitGroup
+}
+
+func New(db *gorm.DB, locker Locker, opts Options) *Scheduler {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = time.Minute
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 30 * time.Second
+	}
+
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:      db,
+		locker:  locker,
+		opts:    opts,
+		replica: fmt.Sprintf("%s:%d", host, os.Getpid()),
+		names:   map[string]*job{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Register добавляет задачи в планировщик; вызывается до Run.
+func (s *Scheduler) Register(jobs ...Job) error {
+	for _, j := range jobs {
+		if j.Name == "" || j.Run == nil {
+			return fmt.Errorf("job %q needs a name and a run function", j.Name)
+		}
+		if _, ok := s.names[j.Name]; ok {
+			return fmt.Errorf("job %q is registered twice", j.Name)
+		}
+		switch j.CatchUp {
+		case "":
+			j.CatchUp = CatchUpSkip
+		case CatchUpSkip, CatchUpOnce, CatchUpAll:
+		default:
+			return fmt.Errorf("job %q: unknown catch up policy %q", j.Name, j.CatchUp)
+		}
+
+		schedule, err := Parse(j.Schedule)
+		if err != nil {
+			return fmt.Errorf("job %q: %w", j.Name, err)
+		}
+		registered := &job{Job: j, schedule: schedule}
+		s.jobs = append(s.jobs, registered)
+		s.names[j.Name] = registered
+	}
+	return nil
+}
+
+// Run выполняет задачи по их расписаниям, пока не завершится ctx. Идущий к тому моменту запуск
+// продолжается до Close.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+}
+
+// Close перестаёт принимать запуски, ждёт работающие до истечения времени дренажа, затем
+// отменяет их.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(s.opts.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+}
+
+func (s *Scheduler) now() time.Time {
+	return time.Now().In(s.opts.Location)
+}
+
+// loop выполняет задачу во времена её расписания. Начинает с последнего запуска по расписанию
+// из истории, чтобы запуски, пропущенные, пока не работала ни одна реплика, были наверстаны; задача,
+// ни разу не запускавшаяся, начинает с текущего момента.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	last, err := lastScheduled(ctx, s.db, j.Name)
+	if err != nil {
+		logfmt.Event("history failed", "job", j.Name, "error", err)
+	}
+	if last.IsZero() {
+		last = s.now()
+	}
+	last = last.In(s.opts.Location)
+
+	for ctx.Err() == nil {
+		now := s.now()
+		if due, skipped := j.due(last, now); len(due) > 0 {
+			s.dispatch(ctx, j, due, skipped, now)
+			last = due[len(due)-1]
+			continue
+		}
+
+		next := j.schedule.Next(now)
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// due возвращает времена расписания после last до now, последние maxCatchUp из них,
+// и сколько более старых пропущено.
+func (j *job) due(last, now time.Time) ([]time.Time, int) {
+	var times []time.Time
+	skipped := 0
+	for t := j.schedule.Next(last); !t.IsZero() && !t.After(now); t = j.schedule.Next(t) {
+		if len(times) == maxCatchUp {
+			times = times[1:]
+			skipped++
+		}
+		times = append(times, t)
+	}
+	return times, skipped
+}
+
+// dispatch выполняет последнее наступившее время, если оно вовремя, и пропущенные до него
+// по правилу наверстывания задачи.
+func (s *Scheduler) dispatch(ctx context.Context, j *job, due []time.Time, skipped int, now time.Time) {
+	catchUp, onTime, dropped := j.plan(due, skipped, now)
+	if dropped > 0 {
+		logfmt.Event("missed", "job", j.Name, "runs", dropped, "catch_up", j.CatchUp)
+	}
+
+	for _, t := range catchUp {
+		if ctx.Err() != nil {
+			return
+		}
+		s.execute(ctx, j, t, TriggerCatchUp)
+	}
+	for _, t := range onTime {
+		if ctx.Err() != nil {
+			return
+		}
+		s.execute(ctx, j, t, TriggerSchedule)
+	}
+}
+
+// plan делит наступившие времена на наверстываемые и время, которое ещё вовремя, и считает
+// отброшенные, вместе с пропущенными due.
+func (j *job) plan(due []time.Time, skipped int, now time.Time) (catchUp, onTime []time.Time, dropped int) {
+	missed := due
+	if latest := due[len(due)-1]; now.Sub(latest) <= grace {
+		missed, onTime = due[:len(due)-1], due[len(due)-1:]
+	}
+
+	switch j.CatchUp {
+	case CatchUpAll:
+		catchUp = missed
+	case CatchUpOnce:
+		if len(missed) > 0 && len(onTime) == 0 {
+			catchUp = missed[len(missed)-1:]
+		}
+	}
+	return catchUp, onTime, skipped + len(missed) - len(catchUp)
+}
+
+// execute выполняет задачу за время расписания, если другая реплика не держит её блокировку
+// и ещё не записала запуск.
+func (s *Scheduler) execute(ctx context.Context, j *job, at time.Time, trigger string) {
+	if !s.enter() {
+		return
+	}
+	run, err := s.start(ctx, j, at, trigger)
+	if err != nil {
+		s.runs.Done()
+		if !errors.Is(err, ErrLocked) && !errors.Is(err, errRecorded) && ctx.Err() == nil {
+			logfmt.Event("start failed", "job", j.Name, "scheduled_at", at.Format(time.RFC3339), "error", err)
+		}
+		return
+	}
+	s.run(j, run)
+}
+
+// Trigger запускает задачу на этой реплике сейчас, если её не выполняет какая-то реплика. Запуск
+// идёт в фоне, возвращаемая запись - его начало.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*JobRun, error) {
+	j, ok := s.names[name]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	if !s.enter() {
+		return nil, ErrClosed
+	}
+	run, err := s.start(ctx, j, s.now(), TriggerManual)
+	if err != nil {
+		s.runs.Done()
+		return nil, err
+	}
+	go s.run(j, run)
+	return run, nil
+}
+
+// enter учитывает начинающийся запуск, false после закрытия планировщика.
+func (s *Scheduler) enter() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.runs.Add(1)
+	return true
+}
+
+// start берёт блокировку задачи и записывает запуск. Держа блокировку, отмечает ещё идущие запуски
+// старых токенов брошенными: их реплики потеряли блокировку, не завершив их.
+func (s *Scheduler) start(ctx context.Context, j *job, at time.Time, trigger string) (*JobRun, error) {
+	token, err := s.locker.Acquire(ctx, j.Name, s.opts.LockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, ErrLocked
+	}
+
+	run := &JobRun{
+		Job:          j.Name,
+		ScheduledAt:  at,
+		Trigger:      trigger,
+		Status:       StatusRunning,
+		StartedAt:    time.Now(),
+		FencingToken: token,
+		Replica:      s.replica,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&JobRun{}).
+			Where("job = ? and status = ? and fencing_token < ?", j.Name, StatusRunning, token).
+			Updates(map[string]interface{}{
+				"status":      StatusAbandoned,
+				"finished_at": run.StartedAt,
+				"error":       ErrLockLost.Error(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if trigger == TriggerManual {
+			return tx.Create(run).Error
+		}
+		// условие задано литералом: postgres выводит из него частичный индекс до привязки параметров
+		res = tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "job"}, {Name: "scheduled_at"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "trigger <> '" + TriggerManual + "'"}}},
+			DoNothing:   true,
+		}).Create(run)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errRecorded
+		}
+		return nil
+	})
+	if err != nil {
+		s.release(j.Name, token)
+		return nil, err
+	}
+	return run, nil
+}
+
+// run выполняет задачу с токеном в контексте, удерживая её блокировку, затем записывает окончание
+// запуска и освобождает блокировку.
+func (s *Scheduler) run(j *job, run *JobRun) {
+	defer s.runs.Done()
+
+	ctx, lost := context.WithCancelCause(s.ctx)
+	defer lost(nil)
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	ctx = context.WithValue(ctx, tokenKey{}, run.FencingToken)
+
+	stop := s.keepLock(lost, j.Name, run.FencingToken)
+	err := protect(func() error {
+		return j.Run(ctx)
+	})
+	stop()
+
+	if err != nil && ctx.Err() != nil {
+		// отличаем запуск, остановленный потерей блокировки или времени, от отменённого остановкой
+		if cause := context.Cause(ctx); cause != nil {
+			err = cause
+		}
+	}
+	s.finish(run, err)
+	s.release(j.Name, run.FencingToken)
+}
+
+// keepLock продлевает блокировку запуска до вызова stop. Блокировка, перехваченная другой
+// репликой или не продлённая в течение целого TTL, отменяет запуск с ErrLockLost.
+func (s *Scheduler) keepLock(lost context.CancelCauseFunc, name string, token int64) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.opts.LockTTL / 3)
+		defer ticker.Stop()
+
+		held := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), s.opts.LockTTL/3)
+			ok, err := s.locker.Refresh(ctx, name, token, s.opts.LockTTL)
+			cancel()
+			switch {
+			case err == nil && ok:
+				held = time.Now()
+				continue
+			case err != nil && time.Since(held) < s.opts.LockTTL:
+				logfmt.Event("lock refresh failed", "job", name, "token", token, "error", err)
+				continue
+			}
+			logfmt.Event("lock lost", "job", name, "token", token)
+			lost(ErrLockLost)
+			return
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// finish записывает окончание запуска, если следующий владелец блокировки не отметил его брошенным.
+func (s *Scheduler) finish(run *JobRun, err error) {
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = StatusSucceeded
+	run.Error = ""
+	fields := []interface{}{"job", run.Job, "trigger", run.Trigger, "token", run.FencingToken, "duration", finished.Sub(run.StartedAt)}
+	switch {
+	case err == nil:
+		logfmt.Event("succeeded", fields...)
+	case errors.Is(err, context.Canceled):
+		run.Status = StatusCancelled
+		run.Error = err.Error()
+		logfmt.Event("cancelled", fields...)
+	default:
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		logfmt.Event("failed", append(fields, "error", err)...)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	res := s.db.WithContext(ctx).Model(&JobRun{}).
+		Where("id = ? and status = ?", run.ID, StatusRunning).
+		Updates(map[string]interface{}{
+			"status":      run.Status,
+			"finished_at": finished,
+			"error":       run.Error,
+		})
+	if res.Error != nil {
+		logfmt.Event("record failed", "job", run.Job, "id", run.ID, "error", res.Error)
+	}
+}
+
+func (s *Scheduler) release(name string, token int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	if err := s.locker.Release(ctx, name, token); err != nil {
+		logfmt.Event("release failed", "job", name, "token", token, "error", err)
+	}
+}
+
+// protect выполняет fn, превращая панику в ошибку.
+func protect(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+type tokenKey struct{}
+
+// Token возвращает fencing токен запуска, которому принадлежит ctx. Задача, пишущая туда, куда реплика,
+// потерявшая блокировку, писать не должна, передаёт его, чтобы хранилище отклоняло записи
+// токена меньше последнего принятого.
+func Token(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(tokenKey{}).(int64)
+	return token, ok
+}
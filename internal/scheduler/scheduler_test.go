@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testJob(t *testing.T, expr string, catchUp CatchUp) *job {
+	t.Helper()
+	s, err := Parse(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &job{Job: Job{Name: "test", Schedule: expr, CatchUp: catchUp}, schedule: s}
+}
+
+func TestJobDue(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		last, now time.Time
+		first     time.Time
+		count     int
+		skipped   int
+	}{
+		{"not yet", "*/10 * * * *", date(2024, 6, 1, 10, 0), date(2024, 6, 1, 10, 5), time.Time{}, 0, 0},
+		{"exactly now", "*/10 * * * *", date(2024, 6, 1, 10, 0), date(2024, 6, 1, 10, 10), date(2024, 6, 1, 10, 10), 1, 0},
+		{"several", "*/10 * * * *", date(2024, 6, 1, 10, 0), date(2024, 6, 1, 10, 35), date(2024, 6, 1, 10, 10), 3, 0},
+		// 150 пропущенных минут: остаются последние maxCatchUp
+		{"more than maxCatchUp", "* * * * *", date(2024, 6, 1, 0, 0), date(2024, 6, 1, 2, 30), date(2024, 6, 1, 0, 51), maxCatchUp, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, skipped := testJob(t, tt.expr, CatchUpSkip).due(tt.last, tt.now)
+			if len(due) != tt.count || skipped != tt.skipped {
+				t.Fatalf("due %d times, skipped %d, want %d and %d", len(due), skipped, tt.count, tt.skipped)
+			}
+			if tt.count > 0 && !due[0].Equal(tt.first) {
+				t.Errorf("first due = %s, want %s", due[0], tt.first)
+			}
+			if tt.count > 0 && due[len(due)-1].After(tt.now) {
+				t.Errorf("last due = %s is after now %s", due[len(due)-1], tt.now)
+			}
+		})
+	}
+}
+
+func TestJobPlan(t *testing.T) {
+	due := []time.Time{date(2024, 6, 1, 10, 10), date(2024, 6, 1, 10, 20), date(2024, 6, 1, 10, 30)}
+	onTime := date(2024, 6, 1, 10, 30).Add(30 * time.Second)
+	late := date(2024, 6, 1, 10, 35)
+
+	tests := []struct {
+		name        string
+		catchUp     CatchUp
+		skipped     int
+		now         time.Time
+		wantCatchUp []time.Time
+		wantOnTime  []time.Time
+		wantDropped int
+	}{
+		{"skip on time", CatchUpSkip, 0, onTime, nil, due[2:], 2},
+		{"skip late", CatchUpSkip, 0, late, nil, nil, 3},
+		{"once on time", CatchUpOnce, 0, onTime, nil, due[2:], 2},
+		{"once late", CatchUpOnce, 0, late, due[2:], nil, 2},
+		{"all on time", CatchUpAll, 0, onTime, due[:2], due[2:], 0},
+		{"all late", CatchUpAll, 0, late, due, nil, 0},
+		// время, отстающее ровно на grace, ещё вовремя
+		{"grace boundary", CatchUpSkip, 0, due[2].Add(grace), nil, due[2:], 2},
+		{"just past grace", CatchUpSkip, 0, due[2].Add(grace + time.Second), nil, nil, 3},
+		// пропущенные сверх maxCatchUp отброшены при любом правиле
+		{"all beyond maxCatchUp", CatchUpAll, 5, late, due, nil, 5},
+		{"once beyond maxCatchUp", CatchUpOnce, 5, late, due[2:], nil, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catchUp, onTime, dropped := testJob(t, "*/10 * * * *", tt.catchUp).plan(due, tt.skipped, tt.now)
+			if !reflect.DeepEqual(catchUp, tt.wantCatchUp) {
+				t.Errorf("catch up = %v, want %v", catchUp, tt.wantCatchUp)
+			}
+			if !reflect.DeepEqual(onTime, tt.wantOnTime) {
+				t.Errorf("on time = %v, want %v", onTime, tt.wantOnTime)
+			}
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
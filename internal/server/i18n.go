@@ -0,0 +1,41 @@
+package server
+
+import (
+	"application_template/utils"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+// LoadBundle читает все файлы сообщений locales/<lang>.json, запасной язык - английский.
+func LoadBundle(dir string) (*i18n.Bundle, error) {
+	bundle := i18n.NewBundle(language.English)
+	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if _, err := bundle.LoadMessageFile(file); err != nil {
+			return nil, fmt.Errorf("failed to load messages %s: %w", file, err)
+		}
+	}
+
+	return bundle, nil
+}
+
+// Localizer сохраняет в контексте локализатор для Accept-Language запроса.
+func Localizer(bundle *i18n.Bundle) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		lang := utils.GetLanguageFromHeader(ctx.GetHeader("Accept-Language"))
+		ctx.Set("lang", lang)
+		ctx.Set("localizer", i18n.NewLocalizer(bundle, lang, language.English.String()))
+		ctx.Next()
+	}
+}
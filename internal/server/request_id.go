@@ -0,0 +1,40 @@
+package server
+
+import (
+	"application_template/utils"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIdHeader = "X-Request-Id"
+
+// RequestId сохраняет id запроса, присланный клиентом или прокси, или создаёт его, кладёт его в
+// контекст и возвращает в ответе.
+func RequestId() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(requestIdHeader)
+		if !validRequestId(id) {
+			raw := make([]byte, 16)
+			_, _ = rand.Read(raw)
+			id = hex.EncodeToString(raw)
+		}
+
+		ctx.Set(utils.RequestIdKey, id)
+		ctx.Header(requestIdHeader, id)
+		ctx.Next()
+	}
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,399 @@
+package server
+
+import (
+	"application_template/internal/app"
+	"application_template/internal/base/base_postgres"
+	"application_template/internal/config"
+	"application_template/internal/database/cache"
+	"application_template/internal/database/connect"
+	"application_template/internal/database/inbox"
+	"application_template/internal/database/migrations"
+	"application_template/internal/database/outbox"
+	"application_template/internal/database/postgres"
+	"application_template/internal/database/redis"
+	"application_template/internal/database/retention"
+	"application_template/internal/integrations/rabbitmq"
+	"application_template/internal/scheduler"
+	"application_template/internal/worker"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Server struct {
+	Srv     *http.Server
+	conf    *config.Config
+	modules []app.Module
+	workers *worker.Runtime
+	// relay равен nil без RabbitMQ или при выключенном OUTBOX_INTERVAL
+	relay *outbox.Relay
+	// scheduler равен nil, если не задан SCHEDULER_ENABLED
+	scheduler *scheduler.Scheduler
+
+	stopJobs context.CancelFunc
+	jobs     sync.WaitGroup
+}
+
+// Init настраивает приложение и строит роутер с маршрутами всех модулей.
+func (s *Server) Init() (*gin.Engine, error) {
+	if err := s.Setup(); err != nil {
+		return nil, err
+	}
+	conf := s.conf
+
+	bundle, err := LoadBundle(conf.AppLocales)
+	if err != nil {
+		return nil, err
+	}
+
+	r := gin.New()
+	r.Use(RequestId(), gin.Logger(), gin.Recovery(), Localizer(bundle))
+
+	api := r.Group("")
+	for _, m := range s.modules {
+		m.Register(api)
+	}
+	if s.scheduler != nil {
+		// маршруты задач не подключаются без модуля, который их защищает
+		if guards, ok := app.Guards(s.modules, "jobs"); ok {
+			h := scheduler.NewHandler(s.scheduler)
+			h.Middlewares = guards
+			h.Register(api, "jobs")
+		}
+	}
+
+	s.Srv = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", conf.AppHost, conf.AppPort),
+		Handler: r,
+	}
+
+	return r, nil
+}
+
+// Setup загружает конфигурацию, открывает соединения, инициализирует модули и регистрирует
+// их воркеры и задачи; это всё, что нужно cmd/worker.
+func (s *Server) Setup() error {
+	conf, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	s.conf = conf
+
+	if err := base_postgres.RegisterValidators(); err != nil {
+		return fmt.Errorf("failed to register validators: %w", err)
+	}
+
+	s.modules = app.Modules()
+	app.RegisterSeeds(s.modules)
+
+	db, err := postgres.Connect(conf.DB)
+	if err != nil {
+		return err
+	}
+	connect.PostgresDB = db
+
+	if conf.DBAutoMigrate {
+		if err := s.migrate(db); err != nil {
+			return err
+		}
+	}
+
+	// планировщик блокирует запуски задач в redis, какой бы ни был драйвер кеша
+	if conf.CacheDriver == cache.DriverRedis || conf.CacheDriver == cache.DriverTiered || conf.SchedulerEnabled {
+		connect.RedisDB = redis.New(conf.Redis)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := connect.RedisDB.Ping(ctx).Err(); err != nil {
+			log.Printf("redis ping %s failed: %s\n", conf.RedisAddr, err)
+		}
+	}
+
+	connect.Cache, err = cache.New(cache.Options{
+		Driver:       conf.CacheDriver,
+		TTL:          time.Duration(conf.CacheTTL) * time.Second,
+		MemorySize:   conf.CacheMemorySize,
+		LocalTTL:     time.Duration(conf.CacheLocalTTL) * time.Second,
+		RefreshAhead: conf.CacheRefreshAhead,
+		Channel:      conf.CacheChannel,
+	}, connect.RedisDB)
+	if err != nil {
+		return err
+	}
+	connect.Cache.Publish("cache")
+
+	if conf.RabbitMQEnabled {
+		// модули объявляют свои exchange и очереди в Init, клиент объявляет их после подключения
+		connect.RabbitMQ = rabbitmq.New(rabbitmq.Dial(conf.RabbitMQ), rabbitmq.Options{
+			PoolSize:       conf.RabbitMQPoolSize,
+			ConfirmTimeout: time.Duration(conf.RabbitMQConfirmTimeout) * time.Second,
+		})
+		connect.RabbitMQ.Start()
+	}
+
+	for _, m := range s.modules {
+		if err := m.Init(conf); err != nil {
+			return fmt.Errorf("failed to init module %s: %w", m.Name(), err)
+		}
+	}
+
+	s.workers = worker.New(connect.RabbitMQ, worker.Options{
+		Concurrency:  conf.WorkerConcurrency,
+		MaxAttempts:  conf.WorkerMaxAttempts,
+		Backoff:      time.Duration(conf.WorkerBackoff) * time.Second,
+		MaxBackoff:   time.Duration(conf.WorkerMaxBackoff) * time.Second,
+		DrainTimeout: time.Duration(conf.WorkerDrainTimeout) * time.Second,
+	})
+	if s.relay, err = s.outboxRelay(); err != nil {
+		return err
+	}
+	if err := s.workers.Register(app.Workers(s.modules)...); err != nil {
+		return err
+	}
+
+	if conf.SchedulerEnabled {
+		if err := s.setupScheduler(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupScheduler регистрирует служебные задачи и задачи модулей.
+func (s *Server) setupScheduler() error {
+	conf := s.conf
+	location, err := time.LoadLocation(conf.SchedulerTimezone)
+	if err != nil {
+		return fmt.Errorf("failed to load SCHEDULER_TIMEZONE: %w", err)
+	}
+
+	s.scheduler = scheduler.New(connect.PostgresDB, scheduler.NewRedisLocker(connect.RedisDB, ""), scheduler.Options{
+		Location:     location,
+		LockTTL:      time.Duration(conf.SchedulerLockTTL) * time.Second,
+		DrainTimeout: time.Duration(conf.WorkerDrainTimeout) * time.Second,
+	})
+
+	for _, jobs := range [][]scheduler.Job{
+		s.retentionJobs(),
+		s.outboxJobs(),
+		s.inboxJobs(),
+		s.schedulerJobs(),
+		app.Jobs(s.modules),
+	} {
+		if err := s.scheduler.Register(jobs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retentionJobs очищают истёкшие мягко удалённые строки по RETENTION_SCHEDULE.
+func (s *Server) retentionJobs() []scheduler.Job {
+	if s.conf.RetentionSchedule == "" {
+		return nil
+	}
+
+	purger := retention.New(connect.PostgresDB, s.conf.RetentionArchiveDir, s.conf.RetentionBatchSize)
+	models := app.Models(s.modules)
+	return []scheduler.Job{
+		{
+			Name:     "retention",
+			Schedule: s.conf.RetentionSchedule,
+			CatchUp:  scheduler.CatchUpOnce,
+			Run: func(ctx context.Context) error {
+				reports, err := purger.Run(ctx, models)
+				retention.Log(reports)
+				return err
+			},
+		},
+	}
+}
+
+// outboxRelay возвращает ретранслятор ожидающих доменных событий в RabbitMQ, опрашиваемый каждые
+// OUTBOX_INTERVAL вместе с воркерами. Без RabbitMQ события остаются ожидающими.
+func (s *Server) outboxRelay() (*outbox.Relay, error) {
+	if connect.RabbitMQ == nil || s.conf.OutboxInterval <= 0 {
+		return nil, nil
+	}
+
+	relay := outbox.NewRelay(connect.PostgresDB, connect.RabbitMQ, s.conf.OutboxExchange, s.conf.OutboxBatchSize)
+	if err := connect.RabbitMQ.Declare(relay.Topology()); err != nil {
+		return nil, err
+	}
+	return relay, nil
+}
+
+// outboxJobs удаляют события, отправленные раньше, чем OUTBOX_RETENTION назад.
+func (s *Server) outboxJobs() []scheduler.Job {
+	if s.conf.OutboxRetention <= 0 {
+		return nil
+	}
+
+	retention := time.Duration(s.conf.OutboxRetention) * time.Second
+	return []scheduler.Job{
+		{
+			Name:     "outbox.clean",
+			Schedule: "@hourly",
+			CatchUp:  scheduler.CatchUpOnce,
+			Run: func(ctx context.Context) error {
+				_, err := outbox.Clean(ctx, connect.PostgresDB, time.Now().Add(-retention))
+				return err
+			},
+		},
+	}
+}
+
+// inboxJobs удаляют id сообщений, обработанных раньше, чем INBOX_RETENTION назад.
+func (s *Server) inboxJobs() []scheduler.Job {
+	if s.conf.InboxRetention <= 0 {
+		return nil
+	}
+
+	retention := time.Duration(s.conf.InboxRetention) * time.Second
+	return []scheduler.Job{
+		{
+			Name:     "inbox.clean",
+			Schedule: "@hourly",
+			CatchUp:  scheduler.CatchUpOnce,
+			Run: func(ctx context.Context) error {
+				_, err := inbox.Clean(ctx, connect.PostgresDB, time.Now().Add(-retention))
+				return err
+			},
+		},
+	}
+}
+
+// schedulerJobs удаляют запуски задач старше SCHEDULER_HISTORY_RETENTION.
+func (s *Server) schedulerJobs() []scheduler.Job {
+	if s.conf.SchedulerHistoryRetention <= 0 {
+		return nil
+	}
+
+	retention := time.Duration(s.conf.SchedulerHistoryRetention) * time.Second
+	return []scheduler.Job{
+		{
+			Name:     "scheduler.clean",
+			Schedule: "@daily",
+			CatchUp:  scheduler.CatchUpOnce,
+			Run: func(ctx context.Context) error {
+				_, err := scheduler.Clean(ctx, connect.PostgresDB, time.Now().Add(-retention))
+				return err
+			},
+		},
+	}
+}
+
+// Run запускает фоновые задачи, воркеры и планировщик, если не выключен WORKER_EMBEDDED,
+// и обслуживает API.
+func (s *Server) Run(r *gin.Engine) {
+	s.startJobs(s.conf.WorkerEmbedded)
+
+	go func() {
+		log.Printf("listening on %s\n", s.Srv.Addr)
+		if err := s.Srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("listen: %s\n", err)
+		}
+	}()
+}
+
+// Work запускает фоновые задачи, воркеры и планировщик без обслуживания API, для cmd/worker.
+func (s *Server) Work() {
+	s.startJobs(true)
+}
+
+// startJobs запускает фоновые задачи, останавливаемые CloseAll.
+func (s *Server) startJobs(workers bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopJobs = cancel
+
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		connect.Cache.Listen(ctx)
+	}()
+
+	if workers {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.workers.Run(ctx)
+		}()
+	}
+
+	if workers && s.relay != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.relay.Poll(ctx, time.Duration(s.conf.OutboxInterval)*time.Second)
+		}()
+	}
+
+	if workers && s.scheduler != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.scheduler.Run(ctx)
+		}()
+	}
+}
+
+// CloseAll останавливает фоновые задачи, дожидаясь дренажа воркеров и запусков задач, освобождает ресурсы модулей в обратном порядке регистрации,
+// затем соединения с брокером и базой данных.
+func (s *Server) CloseAll() {
+	if s.stopJobs != nil {
+		s.stopJobs()
+	}
+	// планировщик выполняет ручные запуски даже там, где ничего не планирует
+	if s.scheduler != nil {
+		s.scheduler.Close()
+	}
+	s.jobs.Wait()
+
+	for i := len(s.modules) - 1; i >= 0; i-- {
+		if c, ok := s.modules[i].(app.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("failed to close module %s: %s\n", s.modules[i].Name(), err)
+			}
+		}
+	}
+
+	if connect.RabbitMQ != nil {
+		if err := connect.RabbitMQ.Close(); err != nil {
+			log.Printf("failed to close rabbitmq: %s\n", err)
+		}
+	}
+
+	if connect.RedisDB != nil {
+		if err := connect.RedisDB.Close(); err != nil {
+			log.Printf("failed to close redis: %s\n", err)
+		}
+	}
+
+	if connect.PostgresDB != nil {
+		if sqlDb, err := connect.PostgresDB.DB(); err == nil {
+			if err := sqlDb.Close(); err != nil {
+				log.Printf("failed to close postgres: %s\n", err)
+			}
+		}
+	}
+}
+
+// migrate применяет ожидающие миграции и сиды; advisory lock позволяет репликам стартовать вместе.
+func (s *Server) migrate(db *gorm.DB) error {
+	applied, err := migrations.New(db, s.conf.DBMigrations).Up(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		log.Printf("migration %d_%s applied\n", m.Version, m.Name)
+	}
+
+	return postgres.Initialize(db)
+}
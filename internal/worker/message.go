@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"application_template/internal/integrations/rabbitmq"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNoBroker возвращает Enqueue, когда RabbitMQ не включён.
+var ErrNoBroker = errors.New("worker: rabbitmq is not enabled")
+
+// Message - сообщение, доставленное воркеру очереди.
+type Message struct {
+	Id         string
+	Queue      string
+	RoutingKey string
+	Headers    amqp.Table
+	Body       []byte
+	// Attempt считает запуски сообщения, начиная с 1. Сообщение, доставленное повторно после того, как
+	// его воркер остановился, не подтвердив его, при падении или потере соединения, учитывает и потерянный запуск.
+	Attempt  int
+	Delivery amqp.Delivery
+}
+
+func newMessage(queue string, d amqp.Delivery) *Message {
+	return &Message{
+		Id:         d.MessageId,
+		Queue:      queue,
+		RoutingKey: d.RoutingKey,
+		Headers:    d.Headers,
+		Body:       d.Body,
+		Attempt:    attempt(d.Headers),
+		Delivery:   d,
+	}
+}
+
+// Decode декодирует JSON тело в v. Тело, которое нельзя декодировать, не декодируется никогда, поэтому ошибка - poison.
+func (m *Message) Decode(v interface{}) error {
+	if err := json.Unmarshal(m.Body, v); err != nil {
+		return Poison(err)
+	}
+	return nil
+}
+
+type poisonError struct {
+	err error
+}
+
+func (e poisonError) Error() string {
+	return e.err.Error()
+}
+
+func (e poisonError) Unwrap() error {
+	return e.err
+}
+
+// Poison отмечает сообщение, которое не обработает ни один повтор: оно сразу уходит в очередь dead letter.
+func Poison(err error) error {
+	return poisonError{err: err}
+}
+
+func IsPoison(err error) bool {
+	var p poisonError
+	return errors.As(err, &p)
+}
+
+// Enqueue публикует v в JSON в очередь воркера.
+func Enqueue(ctx context.Context, client *rabbitmq.Client, queue string, v interface{}) error {
+	if client == nil {
+		return ErrNoBroker
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return client.Publish(ctx, "", queue, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    newId(),
+		Timestamp:    time.Now(),
+		Body:         body,
+	})
+}
+
+func newId() string {
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"application_template/internal/integrations/rabbitmq"
+	"application_template/pkg/logfmt"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	headerAttempt = "x-attempt"
+	headerWorker  = "x-worker"
+	headerReason  = "x-dead-reason"
+	headerError   = "x-error"
+	headerDeadAt  = "x-dead-at"
+)
+
+// Причины перемещения сообщения в очередь dead letter.
+const (
+	reasonPoison    = "poison"
+	reasonExhausted = "exhausted"
+	reasonCrashed   = "crashed"
+)
+
+// republishTimeout ограничивает перемещение сообщения в очередь повтора или dead letter; он не связан
+// с контекстом обработчика, поэтому остановка не теряет перемещаемые сообщения.
+const republishTimeout = 10 * time.Second
+
+func deadQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+// retryQueue называется по своей задержке, поэтому изменение backoff объявляет новые очереди, а не
+// конфликтует с аргументами существующих.
+func retryQueue(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queue, delay)
+}
+
+// delay - пауза перед запуском, следующим за attempt.
+func (w Worker) delay(attempt int) time.Duration {
+	d := w.Backoff
+	for i := 1; i < attempt && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.MaxBackoff {
+		d = w.MaxBackoff
+	}
+	return d
+}
+
+// topology объявляет очередь, её очередь dead letter и очередь повтора на каждый шаг backoff. Очередь
+// повтора держит сообщения свою задержку, затем отправляет их через dead letter обратно в очередь. Сообщения,
+// отклонённые очередью иначе, попадают в очередь dead letter.
+func (w Worker) topology() rabbitmq.Topology {
+	t := rabbitmq.Topology{
+		Queues: []rabbitmq.Queue{
+			{
+				Name:    w.Queue,
+				Durable: true,
+				Args: amqp.Table{
+					rabbitmq.ArgDeadLetterExchange:   "",
+					rabbitmq.ArgDeadLetterRoutingKey: deadQueue(w.Queue),
+				},
+				Bindings: w.Bindings,
+			},
+			{Name: deadQueue(w.Queue), Durable: true},
+		},
+	}
+
+	declared := map[time.Duration]bool{}
+	for attempt := 1; attempt < w.MaxAttempts; attempt++ {
+		delay := w.delay(attempt)
+		if declared[delay] {
+			continue
+		}
+		declared[delay] = true
+		t.Queues = append(t.Queues, rabbitmq.Queue{
+			Name:    retryQueue(w.Queue, delay),
+			Durable: true,
+			Args: amqp.Table{
+				"x-message-ttl":                  int64(delay / time.Millisecond),
+				rabbitmq.ArgDeadLetterExchange:   "",
+				rabbitmq.ArgDeadLetterRoutingKey: w.Queue,
+			},
+		})
+	}
+	return t
+}
+
+func (r *Runtime) consume(ctx context.Context, w Worker) {
+	err := r.client.Consume(ctx, rabbitmq.ConsumerOptions{
+		Queue:        w.Queue,
+		Name:         w.Name,
+		Concurrency:  w.Concurrency,
+		DrainTimeout: r.opts.DrainTimeout,
+	}, func(ctx context.Context, d amqp.Delivery) error {
+		return r.handle(ctx, w, newMessage(w.Queue, d))
+	})
+	if err != nil {
+		logfmt.Event("consumer stopped", "worker", w.Name, "queue", w.Queue, "error", err)
+	}
+}
+
+// handle выполняет обработчик и завершает сообщение: подтверждает обработанное, отправляет в очередь
+// повтора упавшее, в очередь dead letter - poison или исчерпавшее попытки. Возвращённая здесь ошибка
+// означает, что сообщение не удалось переместить, и оно возвращается в очередь.
+func (r *Runtime) handle(ctx context.Context, w Worker, msg *Message) error {
+	if msg.Delivery.Redelivered {
+		return r.redelivered(w, msg)
+	}
+
+	start := time.Now()
+	err := protect(func() error {
+		return w.Handle(ctx, msg)
+	})
+	fields := []interface{}{"worker", w.Name, "queue", w.Queue, "id", msg.Id, "attempt", msg.Attempt, "duration", time.Since(start)}
+
+	switch {
+	case err == nil:
+		logfmt.Event("handled", fields...)
+		return nil
+	case IsPoison(err):
+		return r.dead(w, msg, reasonPoison, err, fields)
+	case msg.Attempt >= w.MaxAttempts:
+		return r.dead(w, msg, reasonExhausted, err, fields)
+	}
+
+	delay := w.delay(msg.Attempt)
+	logfmt.Event("retry", append(fields, "delay", delay, "error", err)...)
+	return r.republish(retryQueue(w.Queue, delay), msg, amqp.Table{headerAttempt: int64(msg.Attempt + 1)})
+}
+
+// redelivered обрабатывает сообщение, запуск которого остановился, не подтвердив его. Потерянный запуск
+// считается попыткой, поэтому сообщение, роняющее каждый взявший его воркер, попадает в очередь dead letter, а не
+// ходит по кругу вечно; иначе оно возвращается в очередь с учтённой попыткой.
+func (r *Runtime) redelivered(w Worker, msg *Message) error {
+	fields := []interface{}{"worker", w.Name, "queue", w.Queue, "id", msg.Id, "attempt", msg.Attempt}
+	if msg.Attempt >= w.MaxAttempts {
+		return r.dead(w, msg, reasonCrashed, fmt.Errorf("redelivered after %d runs", msg.Attempt), fields)
+	}
+
+	logfmt.Event("redelivered", fields...)
+	return r.republish(w.Queue, msg, amqp.Table{headerAttempt: int64(msg.Attempt + 1)})
+}
+
+func (r *Runtime) dead(w Worker, msg *Message, reason string, err error, fields []interface{}) error {
+	logfmt.Event("dead", append(fields, "reason", reason, "error", err)...)
+	return r.republish(deadQueue(w.Queue), msg, amqp.Table{
+		headerWorker: w.Name,
+		headerReason: reason,
+		headerError:  err.Error(),
+		headerDeadAt: time.Now().UTC(),
+	})
+}
+
+// republish публикует копию сообщения в очередь с заданными заголовками, сохраняя его
+// id, чтобы потребители могли понять, что копия - то же сообщение.
+func (r *Runtime) republish(queue string, msg *Message, headers amqp.Table) error {
+	d := msg.Delivery
+	h := amqp.Table{}
+	for k, v := range d.Headers {
+		h[k] = v
+	}
+	for k, v := range headers {
+		h[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), republishTimeout)
+	defer cancel()
+	err := r.client.Publish(ctx, "", queue, amqp.Publishing{
+		Headers:         h,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	})
+	if err != nil {
+		logfmt.Event("republish failed", "queue", queue, "id", msg.Id, "error", err)
+	}
+	return err
+}
+
+// attempt читает заголовок попытки, 1 для сообщения, которое не повторялось.
+func attempt(headers amqp.Table) int {
+	switch v := headers[headerAttempt].(type) {
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	case int:
+		return v
+	}
+	return 1
+}
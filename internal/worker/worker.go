@@ -0,0 +1,161 @@
+// Package worker выполняет воркеры очередей модулей. Воркер обрабатывает сообщения очереди
+// RabbitMQ, повторяя неудачи с backoff и перемещая сообщения, которые продолжают падать, в очередь
+// dead letter. Работа по расписанию оставлена пакету scheduler. Среда выполнения работает внутри
+// процесса API или отдельно в cmd/worker.
+package worker
+
+import (
+	"application_template/internal/integrations/rabbitmq"
+	"application_template/pkg/logfmt"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency  = 1
+	defaultMaxAttempts  = 5
+	defaultBackoff      = 5 * time.Second
+	defaultMaxBackoff   = 5 * time.Minute
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// Handler обрабатывает одно сообщение воркера очереди. Ошибка повторяет сообщение, если она не
+// обёрнута Poison и попытки не исчерпаны.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Worker - именованный потребитель очереди. Нулевые ограничения берут значения среды выполнения по умолчанию.
+type Worker struct {
+	Name string
+
+	Queue string
+	// Bindings направляют в очередь сообщения exchange, помимо публикации в неё через Enqueue.
+	Bindings    []rabbitmq.Binding
+	Handle      Handler
+	Concurrency int
+	MaxAttempts int
+	// Backoff - пауза перед первым повтором, удваивается для каждого следующего до MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+type Options struct {
+	Concurrency int
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// DrainTimeout - сколько работа, идущая при остановке, может завершаться, прежде чем её контекст отменят.
+	DrainTimeout time.Duration
+}
+
+type Runtime struct {
+	client  *rabbitmq.Client
+	opts    Options
+	workers []Worker
+	names   map[string]bool
+}
+
+// New возвращает среду выполнения, потребляющую через client. Без клиента воркеры пропускаются.
+func New(client *rabbitmq.Client, opts Options) *Runtime {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultBackoff
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = defaultDrainTimeout
+	}
+
+	return &Runtime{
+		client: client,
+		opts:   opts,
+		names:  map[string]bool{},
+	}
+}
+
+// Register добавляет воркеры и объявляет их очереди.
+func (r *Runtime) Register(workers ...Worker) error {
+	for _, w := range workers {
+		if err := r.validate(w); err != nil {
+			return err
+		}
+		r.names[w.Name] = true
+
+		if r.client == nil {
+			logfmt.Event("skipped", "worker", w.Name, "queue", w.Queue, "reason", "rabbitmq is not enabled")
+			continue
+		}
+
+		w = r.defaults(w)
+		if err := r.client.Declare(w.topology()); err != nil {
+			return fmt.Errorf("worker %s: %w", w.Name, err)
+		}
+		r.workers = append(r.workers, w)
+	}
+	return nil
+}
+
+func (r *Runtime) validate(w Worker) error {
+	switch {
+	case w.Name == "":
+		return errors.New("worker without a name")
+	case r.names[w.Name]:
+		return fmt.Errorf("worker %s registered twice", w.Name)
+	case w.Queue == "":
+		return fmt.Errorf("worker %s without a queue", w.Name)
+	case w.Handle == nil:
+		return fmt.Errorf("worker %s without Handle", w.Name)
+	}
+	return nil
+}
+
+func (r *Runtime) defaults(w Worker) Worker {
+	if w.Concurrency <= 0 {
+		w.Concurrency = r.opts.Concurrency
+	}
+	if w.MaxAttempts <= 0 {
+		w.MaxAttempts = r.opts.MaxAttempts
+	}
+	if w.Backoff <= 0 {
+		w.Backoff = r.opts.Backoff
+	}
+	if w.MaxBackoff < w.Backoff {
+		w.MaxBackoff = r.opts.MaxBackoff
+	}
+	return w
+}
+
+// Run выполняет воркеры, пока не завершится ctx, затем ждёт окончания идущей работы.
+func (r *Runtime) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, w := range r.workers {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.consume(ctx, w)
+		}()
+	}
+	logfmt.Event("started", "workers", len(r.workers))
+	wg.Wait()
+	logfmt.Event("stopped", "workers", len(r.workers))
+}
+
+// protect выполняет fn, превращая панику в poison ошибку.
+func protect(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Poison(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	return fn()
+}
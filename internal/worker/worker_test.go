@@ -0,0 +1,274 @@
+package worker
+
+import (
+	"application_template/internal/integrations/rabbitmq"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const testQueue = "tasks"
+
+// testRuntime запускает среду выполнения с воркером w на брокере в памяти до конца теста.
+func testRuntime(t *testing.T, m *rabbitmq.Memory, w Worker) *rabbitmq.Client {
+	t.Helper()
+	client := rabbitmq.New(m, rabbitmq.Options{ReconnectDelay: 10 * time.Millisecond})
+	client.Start()
+
+	r := New(client, Options{MaxAttempts: 3, Backoff: 20 * time.Millisecond, MaxBackoff: 40 * time.Millisecond})
+	if err := r.Register(w); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = client.Close()
+	})
+	return client
+}
+
+func enqueue(t *testing.T, client *rabbitmq.Client, v interface{}) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := Enqueue(ctx, client, testQueue, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// attempts записывает номера попыток, с которыми обработчик получил сообщения.
+type attempts struct {
+	mu   sync.Mutex
+	seen []int
+}
+
+func (a *attempts) add(msg *Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seen = append(a.seen, msg.Attempt)
+}
+
+func (a *attempts) get() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]int(nil), a.seen...)
+}
+
+// eventually ждёт cond и проваливает тест, если условие не выполнилось за несколько секунд.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// dead забирает первое сообщение очереди dead letter.
+func dead(t *testing.T, client *rabbitmq.Client) amqp.Delivery {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := make(chan amqp.Delivery, 1)
+	go func() {
+		_ = client.Consume(ctx, rabbitmq.ConsumerOptions{Queue: deadQueue(testQueue)}, func(ctx context.Context, d amqp.Delivery) error {
+			select {
+			case got <- d:
+			default:
+			}
+			return nil
+		})
+	}()
+	select {
+	case d := <-got:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the dead letter")
+	}
+	return amqp.Delivery{}
+}
+
+func TestRetry(t *testing.T) {
+	m := rabbitmq.NewMemory()
+	var a attempts
+	client := testRuntime(t, m, Worker{
+		Name:  "retry",
+		Queue: testQueue,
+		Handle: func(ctx context.Context, msg *Message) error {
+			a.add(msg)
+			if msg.Attempt < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		},
+	})
+
+	enqueue(t, client, "task")
+	eventually(t, "the third attempt", func() bool { return len(a.get()) == 3 })
+	// последняя попытка успешна: сообщение подтверждено, а не отправлено в dead letter
+	time.Sleep(50 * time.Millisecond)
+	if got, want := a.get(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("attempts = %v, want %v", got, want)
+	}
+	if n := m.Len(deadQueue(testQueue)); n != 0 {
+		t.Errorf("dead letter queue holds %d messages, want 0", n)
+	}
+}
+
+func TestRetryQueues(t *testing.T) {
+	w := Worker{Queue: testQueue, MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 3 * time.Second}
+
+	var names []string
+	for _, q := range w.topology().Queues {
+		names = append(names, q.Name)
+	}
+	// задержки 1s, 2s, 3s, 3s: очередь повтора одна на задержку
+	want := []string{"tasks", "tasks.dlq", "tasks.retry.1s", "tasks.retry.2s", "tasks.retry.3s"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("queues = %v, want %v", names, want)
+	}
+}
+
+func TestDead(t *testing.T) {
+	tests := []struct {
+		name     string
+		handle   func(msg *Message) error
+		attempts []int
+		reason   string
+	}{
+		{
+			name:     "exhausted",
+			handle:   func(*Message) error { return errors.New("always") },
+			attempts: []int{1, 2, 3},
+			reason:   reasonExhausted,
+		},
+		{
+			name:     "poison",
+			handle:   func(*Message) error { return Poison(errors.New("bad body")) },
+			attempts: []int{1},
+			reason:   reasonPoison,
+		},
+		{
+			name: "undecodable body",
+			handle: func(msg *Message) error {
+				var n int
+				return msg.Decode(&n)
+			},
+			attempts: []int{1},
+			reason:   reasonPoison,
+		},
+		{
+			name:     "panic",
+			handle:   func(*Message) error { panic("boom") },
+			attempts: []int{1},
+			reason:   reasonPoison,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := rabbitmq.NewMemory()
+			var a attempts
+			client := testRuntime(t, m, Worker{
+				Name:  "dead",
+				Queue: testQueue,
+				Handle: func(ctx context.Context, msg *Message) error {
+					a.add(msg)
+					return tt.handle(msg)
+				},
+			})
+
+			enqueue(t, client, "task")
+			d := dead(t, client)
+			if got := a.get(); !reflect.DeepEqual(got, tt.attempts) {
+				t.Errorf("attempts = %v, want %v", got, tt.attempts)
+			}
+			if got := d.Headers[headerReason]; got != tt.reason {
+				t.Errorf("%s = %v, want %s", headerReason, got, tt.reason)
+			}
+			if got := d.Headers[headerWorker]; got != "dead" {
+				t.Errorf("%s = %v, want dead", headerWorker, got)
+			}
+			if got := attempt(d.Headers); got != tt.attempts[len(tt.attempts)-1] {
+				t.Errorf("%s = %d, want %d", headerAttempt, got, tt.attempts[len(tt.attempts)-1])
+			}
+			if d.MessageId == "" {
+				t.Error("the dead letter lost the message id")
+			}
+		})
+	}
+}
+
+func TestRedeliveryCountsAsAttempt(t *testing.T) {
+	m := rabbitmq.NewMemory()
+	var a attempts
+	release := make(chan struct{})
+	client := testRuntime(t, m, Worker{
+		Name:  "redelivery",
+		Queue: testQueue,
+		Handle: func(ctx context.Context, msg *Message) error {
+			a.add(msg)
+			if msg.Attempt == 1 {
+				<-release
+			}
+			return nil
+		},
+	})
+
+	enqueue(t, client, "task")
+	eventually(t, "the first run", func() bool { return len(a.get()) == 1 })
+	// соединение теряется посреди запуска: брокер возвращает неподтверждённое сообщение как redelivered
+	m.Disconnect()
+	close(release)
+
+	eventually(t, "the second run", func() bool { return len(a.get()) == 2 })
+	if got, want := a.get(), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("attempts = %v, want %v", got, want)
+	}
+}
+
+func TestRedeliveredOutOfAttempts(t *testing.T) {
+	m := rabbitmq.NewMemory()
+	client := rabbitmq.New(m, rabbitmq.Options{ReconnectDelay: 10 * time.Millisecond})
+	client.Start()
+	t.Cleanup(func() { _ = client.Close() })
+
+	r := New(client, Options{MaxAttempts: 3})
+	w := Worker{
+		Name:  "crashed",
+		Queue: testQueue,
+		Handle: func(context.Context, *Message) error {
+			t.Error("a message redelivered after its last attempt must not run again")
+			return nil
+		},
+	}
+	if err := r.Register(w); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := newMessage(testQueue, amqp.Delivery{
+		MessageId:   "m",
+		Redelivered: true,
+		Headers:     amqp.Table{headerAttempt: int64(3)},
+	})
+	if err := r.handle(context.Background(), r.workers[0], msg); err != nil {
+		t.Fatal(err)
+	}
+	d := dead(t, client)
+	if got := d.Headers[headerReason]; got != reasonCrashed {
+		t.Errorf("%s = %v, want %s", headerReason, got, reasonCrashed)
+	}
+}
@@ -0,0 +1,65 @@
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_name text,
+    user_password text,
+    active boolean
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_unique ON users (user_name) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text
+);
+CREATE INDEX IF NOT EXISTS idx_roles_deleted_at ON roles (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_role_unique ON roles (name) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    id_role bigint,
+    type bigint,
+    target text,
+    value bigint,
+    CONSTRAINT fk_roles_permissions FOREIGN KEY (id_role) REFERENCES roles (id),
+    CONSTRAINT cons_uniq UNIQUE (id_role, type, target)
+);
+CREATE INDEX IF NOT EXISTS idx_permissions_deleted_at ON permissions (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permission_unique ON permissions (id_role, type, target) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    id_user bigint,
+    id_role bigint,
+    created_at timestamptz,
+    PRIMARY KEY (id_user, id_role),
+    CONSTRAINT fk_user_roles_user FOREIGN KEY (id_user) REFERENCES users (id),
+    CONSTRAINT fk_user_roles_role FOREIGN KEY (id_role) REFERENCES roles (id)
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    id_user bigint,
+    jti text,
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (id_user) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_id_user ON refresh_tokens (id_user);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_token_jti ON refresh_tokens (jti);
+
+CREATE TABLE IF NOT EXISTS seed_history (
+    name text PRIMARY KEY,
+    applied_at timestamptz
+);
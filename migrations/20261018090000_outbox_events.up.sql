@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id bigserial PRIMARY KEY,
+    event_id text NOT NULL,
+    event_type text NOT NULL,
+    event_version bigint NOT NULL DEFAULT 0,
+    payload jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    sent_at timestamptz,
+    attempts bigint NOT NULL DEFAULT 0,
+    last_error text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_events_event_id ON outbox_events (event_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (id) WHERE sent_at IS NULL;
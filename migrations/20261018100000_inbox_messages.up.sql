@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS inbox_messages (
+    message_id text NOT NULL,
+    consumer text NOT NULL,
+    processed_at timestamptz NOT NULL,
+    PRIMARY KEY (message_id, consumer)
+);
+CREATE INDEX IF NOT EXISTS idx_inbox_messages_processed_at ON inbox_messages (processed_at);
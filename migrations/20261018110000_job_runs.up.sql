@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS job_runs (
+    id bigserial PRIMARY KEY,
+    job text NOT NULL,
+    scheduled_at timestamptz NOT NULL,
+    trigger text NOT NULL,
+    status text NOT NULL,
+    started_at timestamptz NOT NULL,
+    finished_at timestamptz,
+    error text,
+    fencing_token bigint NOT NULL,
+    replica text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_runs_slot ON job_runs (job, scheduled_at) WHERE trigger <> 'manual';
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_started_at ON job_runs (job, started_at);
@@ -0,0 +1,2 @@
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS fk_refresh_tokens_user;
+ALTER TABLE refresh_tokens ADD CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (id_user) REFERENCES users (id) ON DELETE CASCADE;
@@ -0,0 +1,41 @@
+// Package logfmt пишет строки лога парами ключ/значение в формате logfmt, чтобы логи воркеров
+// и планировщика можно было фильтровать по воркеру, очереди, задаче или id сообщения.
+package logfmt
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event пишет событие и его пары ключ/значение одной строкой.
+func Event(event string, kv ...interface{}) {
+	log.Println(Line(event, kv...))
+}
+
+// Line форматирует событие и его пары ключ/значение; ключ в конце без значения отбрасывается.
+func Line(event string, kv ...interface{}) string {
+	var b strings.Builder
+	b.WriteString("event=")
+	b.WriteString(value(event))
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%s", kv[i], value(kv[i+1]))
+	}
+	return b.String()
+}
+
+func value(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case time.Duration:
+		s = v.Round(time.Microsecond).String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
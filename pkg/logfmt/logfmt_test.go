@@ -0,0 +1,32 @@
+package logfmt
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		kv    []interface{}
+		want  string
+	}{
+		{"event only", "started", nil, "event=started"},
+		{"quoted event", "consumer stopped", nil, `event="consumer stopped"`},
+		{"pairs", "handled", []interface{}{"queue", "mail", "attempt", 2}, "event=handled queue=mail attempt=2"},
+		{"duration", "handled", []interface{}{"took", 1500 * time.Nanosecond}, "event=handled took=2µs"},
+		{"error with spaces", "failed", []interface{}{"error", errors.New("connection reset")}, `event=failed error="connection reset"`},
+		{"equals and quotes", "failed", []interface{}{"reason", `a="b"`}, `event=failed reason="a=\"b\""`},
+		{"empty value", "failed", []interface{}{"reason", ""}, `event=failed reason=""`},
+		{"key without a value", "failed", []interface{}{"job", "purge", "dangling"}, "event=failed job=purge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Line(tt.event, tt.kv...); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+	"application_template/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
+type Claims struct {
+	IdUser uint      `json:"id_user"`
+	Roles  []string  `json:"roles"`
+	Type   TokenType `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+func NewToken(secret string, ttl time.Duration, t TokenType, idUser uint, roles []string) (string, *Claims, error) {
+	jti, err := newTokenId()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		IdUser: idUser,
+		Roles:  roles,
+		Type:   t,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(idUser), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return signed, claims, nil
+}
+
+func ParseToken(secret string, token string, t TokenType) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, utils.NewLocalizeError(err, "exception:token-expired", nil)
+		}
+		return nil, utils.NewLocalizeError(err, "exception:invalid-token", nil)
+	}
+
+	if claims.Type != t {
+		return nil, utils.NewLocalizeError(nil, "exception:invalid-token", nil)
+	}
+
+	return claims, nil
+}
+
+func newTokenId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,127 @@
+package security
+
+import (
+	"application_template/utils"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func TestNewToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   TokenType
+		ttl   time.Duration
+		roles []string
+	}{
+		{"access", AccessToken, time.Minute, []string{"admin"}},
+		{"refresh", RefreshToken, time.Hour, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, claims, err := NewToken(testSecret, tt.ttl, tt.typ, 42, tt.roles)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := ParseToken(testSecret, signed, tt.typ)
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if parsed.IdUser != 42 || parsed.Subject != "42" {
+				t.Errorf("user = %d, subject %q; want 42", parsed.IdUser, parsed.Subject)
+			}
+			if parsed.ID == "" || parsed.ID != claims.ID {
+				t.Errorf("jti = %q, want %q", parsed.ID, claims.ID)
+			}
+			if strings.Join(parsed.Roles, ",") != strings.Join(tt.roles, ",") {
+				t.Errorf("roles = %v, want %v", parsed.Roles, tt.roles)
+			}
+			if got := parsed.ExpiresAt.Sub(parsed.IssuedAt.Time); got != tt.ttl {
+				t.Errorf("lifetime = %v, want %v", got, tt.ttl)
+			}
+		})
+	}
+}
+
+func TestNewTokenUniqueId(t *testing.T) {
+	_, first, err := NewToken(testSecret, time.Minute, RefreshToken, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := NewToken(testSecret, time.Minute, RefreshToken, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID {
+		t.Errorf("two tokens share the jti %q", first.ID)
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	access, _, err := NewToken(testSecret, time.Minute, AccessToken, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, _, err := NewToken(testSecret, time.Minute, RefreshToken, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := NewToken(testSecret, -time.Minute, RefreshToken, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, _, err := NewToken("other-secret", time.Minute, RefreshToken, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, &Claims{IdUser: 1, Type: RefreshToken}).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(refresh, ".")
+	tampered := parts[0] + "." + parts[1] + "x." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		typ   TokenType
+		want  string
+	}{
+		{"access as access", access, AccessToken, ""},
+		{"refresh as refresh", refresh, RefreshToken, ""},
+		{"access as refresh", access, RefreshToken, "exception:invalid-token"},
+		{"refresh as access", refresh, AccessToken, "exception:invalid-token"},
+		{"expired", expired, RefreshToken, "exception:token-expired"},
+		{"other secret", foreign, RefreshToken, "exception:invalid-token"},
+		{"alg none", unsigned, RefreshToken, "exception:invalid-token"},
+		{"tampered", tampered, RefreshToken, "exception:invalid-token"},
+		{"garbage", "not a token", RefreshToken, "exception:invalid-token"},
+		{"empty", "", RefreshToken, "exception:invalid-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseToken(testSecret, tt.token, tt.typ)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("ParseToken: %v", err)
+				}
+				return
+			}
+
+			var localized *utils.LocalizeError
+			if !errors.As(err, &localized) {
+				t.Fatalf("err = %v, want %s", err, tt.want)
+			}
+			if localized.Message != tt.want {
+				t.Errorf("err = %s, want %s", localized.Message, tt.want)
+			}
+		})
+	}
+}
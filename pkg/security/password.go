@@ -0,0 +1,167 @@
+package security
+
+import (
+	"application_template/utils"
+	"errors"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordMismatch = errors.New("password mismatch")
+
+type PasswordPolicy struct {
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+}
+
+var (
+	mu       sync.RWMutex
+	hashCost = bcrypt.DefaultCost
+	policy   = PasswordPolicy{
+		MinLength:    8,
+		RequireLower: true,
+		RequireDigit: true,
+	}
+)
+
+// Configure задаёт стоимость bcrypt для новых хешей и политику, которую проверяет ValidatePassword.
+// Нулевая стоимость оставляет bcrypt.DefaultCost.
+func Configure(p PasswordPolicy, cost int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	hashCost = cost
+	policy = p
+}
+
+func Policy() PasswordPolicy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return policy
+}
+
+func cost() int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return hashCost
+}
+
+func Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), cost())
+}
+
+// IsHashed сообщает, является ли s хешем bcrypt, а не паролем в открытом виде.
+func IsHashed(s string) bool {
+	if len(s) != 60 {
+		return false
+	}
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
+
+// VerifyPassword сравнивает сохранённый хеш bcrypt с кандидатом. Сохранённое значение, не
+// являющееся хешем, не совпадает ни с чем.
+func VerifyPassword(stored, password string) error {
+	if !IsHashed(stored) {
+		CompareDummy(password)
+		return ErrPasswordMismatch
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+var (
+	dummyMu   sync.Mutex
+	dummyHash []byte
+)
+
+// CompareDummy сверяет password с хешем текущей стоимости, который не совпадает ни с чем, чтобы
+// вход неизвестного пользователя занимал столько же времени, сколько вход с неверным паролем.
+func CompareDummy(password string) {
+	c := cost()
+
+	dummyMu.Lock()
+	if stored, err := bcrypt.Cost(dummyHash); err != nil || stored != c {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), c)
+	}
+	hashed := dummyHash
+	dummyMu.Unlock()
+
+	_ = bcrypt.CompareHashAndPassword(hashed, []byte(password))
+}
+
+// NeedsRehash сообщает, нужно ли перехешировать сохранённый пароль с текущими настройками.
+func NeedsRehash(stored string) bool {
+	if !IsHashed(stored) {
+		return true
+	}
+
+	c, err := bcrypt.Cost([]byte(stored))
+	if err != nil {
+		return true
+	}
+	return c != cost()
+}
+
+func ValidatePassword(password string) error {
+	p := Policy()
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return utils.NewLocalizeError(nil, "exception:password-too-short", map[string]interface{}{
+			"Min": p.MinLength,
+		})
+	}
+
+	var upper, lower, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			special = true
+		}
+	}
+
+	switch {
+	case p.RequireUpper && !upper:
+		return utils.NewLocalizeError(nil, "exception:password-requires-uppercase", nil)
+	case p.RequireLower && !lower:
+		return utils.NewLocalizeError(nil, "exception:password-requires-lowercase", nil)
+	case p.RequireDigit && !digit:
+		return utils.NewLocalizeError(nil, "exception:password-requires-digit", nil)
+	case p.RequireSpecial && !special:
+		return utils.NewLocalizeError(nil, "exception:password-requires-special", nil)
+	}
+
+	return nil
+}
+
+// HashValidPassword проверяет пароль по политике и возвращает его хеш.
+func HashValidPassword(password string) (string, error) {
+	if err := ValidatePassword(password); err != nil {
+		return "", err
+	}
+
+	hashed, err := Hash(password)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
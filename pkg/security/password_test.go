@@ -0,0 +1,178 @@
+package security
+
+import (
+	"application_template/utils"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// configure задаёт политику и стоимость на время теста и восстанавливает прежние после него.
+func configure(t *testing.T, p PasswordPolicy, c int) {
+	t.Helper()
+	prevPolicy, prevCost := Policy(), cost()
+	Configure(p, c)
+	t.Cleanup(func() { Configure(prevPolicy, prevCost) })
+}
+
+func TestValidatePassword(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:      8,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+	}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     string
+	}{
+		{"empty policy", PasswordPolicy{}, "", ""},
+		{"too short", strict, "Ab1!", "exception:password-too-short"},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "пароль", ""},
+		{"multibyte too short", PasswordPolicy{MinLength: 7}, "пароль", "exception:password-too-short"},
+		{"no upper", strict, "abcdef1!", "exception:password-requires-uppercase"},
+		{"no lower", strict, "ABCDEF1!", "exception:password-requires-lowercase"},
+		{"no digit", strict, "Abcdefg!", "exception:password-requires-digit"},
+		{"no special", strict, "Abcdefg1", "exception:password-requires-special"},
+		{"symbol is special", strict, "Abcdef1+", ""},
+		{"strict ok", strict, "Abcdef1!", ""},
+		{"non latin letters", strict, "Пароль1!", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configure(t, tt.policy, bcrypt.MinCost)
+
+			err := ValidatePassword(tt.password)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("ValidatePassword: %v", err)
+				}
+				return
+			}
+
+			var localized *utils.LocalizeError
+			if !errors.As(err, &localized) {
+				t.Fatalf("err = %v, want %s", err, tt.want)
+			}
+			if localized.Message != tt.want {
+				t.Errorf("err = %s, want %s", localized.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashValidPassword(t *testing.T) {
+	configure(t, PasswordPolicy{MinLength: 8, RequireDigit: true}, bcrypt.MinCost)
+
+	if _, err := HashValidPassword("password"); err == nil {
+		t.Fatal("a password against the policy is hashed")
+	}
+
+	hashed, err := HashValidPassword("password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsHashed(hashed) {
+		t.Fatalf("%q is not a bcrypt hash", hashed)
+	}
+	if c, _ := bcrypt.Cost([]byte(hashed)); c != bcrypt.MinCost {
+		t.Errorf("cost = %d, want %d", c, bcrypt.MinCost)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	configure(t, PasswordPolicy{}, bcrypt.MinCost)
+
+	hashed, err := Hash("secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		stored   string
+		password string
+		want     error
+	}{
+		{"match", string(hashed), "secret1", nil},
+		{"mismatch", string(hashed), "secret2", ErrPasswordMismatch},
+		{"empty candidate", string(hashed), "", ErrPasswordMismatch},
+		{"plain text stored", "secret1", "secret1", ErrPasswordMismatch},
+		{"empty stored", "", "", ErrPasswordMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyPassword(tt.stored, tt.password); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHashed(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		s    string
+		want bool
+	}{
+		{"bcrypt", string(hashed), true},
+		{"2b prefix", "$2b$" + string(hashed[4:]), true},
+		{"plain", "secret", false},
+		{"prefix only", "$2a$10$", false},
+		{"unknown prefix", "$1$" + string(hashed[3:]), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsHashed(tt.s); got != tt.want {
+				t.Errorf("IsHashed(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	configure(t, PasswordPolicy{}, bcrypt.MinCost+1)
+
+	current, err := Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cheaper, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		stored string
+		want   bool
+	}{
+		{"current cost", string(current), false},
+		{"other cost", string(cheaper), true},
+		{"not a hash", "secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.stored); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureDefaultCost(t *testing.T) {
+	configure(t, PasswordPolicy{}, 0)
+
+	if c := cost(); c != bcrypt.DefaultCost {
+		t.Errorf("cost = %d, want %d", c, bcrypt.DefaultCost)
+	}
+}
@@ -17,6 +17,11 @@ func isValidKyrgyzPhoneNumber(number string) bool {
 	return reg.MatchString(number)
 }
 
+// ValidPhoneNumber сообщает, является ли number киргизским номером телефона, с форматированием или без.
+func ValidPhoneNumber(number string) bool {
+	return isValidKyrgyzPhoneNumber(number)
+}
+
 func NewPhoneNumber(number string, validate bool) (*PhoneNumber, error) {
 	if validate {
 		if !(isValidKyrgyzPhoneNumber(number)) {
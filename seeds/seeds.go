@@ -0,0 +1,133 @@
+package seeds
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Seed заполняет базу данными, нужными модулю. Run должен быть идемпотентным:
+// он выполняется в транзакции и может быть применён повторно с force.
+type Seed struct {
+	Name      string
+	DependsOn []string
+	Run       func(tx *gorm.DB) error
+}
+
+// SeedHistory записывает каждый применённый к базе seed.
+type SeedHistory struct {
+	Name      string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+func (SeedHistory) TableName() string {
+	return "seed_history"
+}
+
+var registry = map[string]Seed{}
+
+func Register(seeds ...Seed) {
+	for _, s := range seeds {
+		if _, exists := registry[s.Name]; exists {
+			panic(fmt.Sprintf("seed %s registered twice", s.Name))
+		}
+		registry[s.Name] = s
+	}
+}
+
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunSeeds применяет указанные seeds и их зависимости или все зарегистрированные, если
+// names пуст. Seeds, уже записанные в seed_history, пропускаются, если не задан force.
+func RunSeeds(db *gorm.DB, names []string, force bool) error {
+	if len(names) == 0 {
+		names = Names()
+	}
+
+	plan, err := resolve(names)
+	if err != nil {
+		return err
+	}
+
+	var applied []string
+	if res := db.Model(&SeedHistory{}).Pluck("name", &applied); res.Error != nil {
+		return res.Error
+	}
+	done := map[string]bool{}
+	for _, name := range applied {
+		done[name] = true
+	}
+
+	for _, s := range plan {
+		if done[s.Name] && !force {
+			log.Printf("seed %s already applied, skipping\n", s.Name)
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := s.Run(tx); err != nil {
+				return err
+			}
+			return tx.Save(&SeedHistory{Name: s.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("seed %s failed: %w", s.Name, err)
+		}
+		log.Printf("seed %s applied\n", s.Name)
+	}
+
+	return nil
+}
+
+// resolve упорядочивает seeds так, чтобы каждый шёл после своих зависимостей.
+func resolve(names []string) ([]Seed, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var plan []Seed
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("seed dependency cycle: %v -> %s", path, name)
+		}
+
+		s, exists := registry[name]
+		if !exists {
+			return fmt.Errorf("seed %s is not registered", name)
+		}
+
+		state[name] = visiting
+		for _, dep := range s.DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		plan = append(plan, s)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
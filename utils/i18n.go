@@ -43,6 +43,10 @@ func (e LocalizeError) Error() string {
 	return e.Source.Error()
 }
 
+func (e LocalizeError) Unwrap() error {
+	return e.Source
+}
+
 func NewLocalizeError(err error, message string, data interface{}) *LocalizeError {
 	return &LocalizeError{
 		Source:  err,
@@ -55,6 +59,24 @@ func (e LocalizeError) Localize(ctx *gin.Context) string {
 	return Localize(ctx, e.Message, e.Data)
 }
 
+// LocalizeOr локализует с data, которая может быть nil, первое из найденных сообщений или
+// возвращает fallback, если не найдено ни одно.
+func LocalizeOr(ctx *gin.Context, fallback string, data interface{}, messageIDs ...string) string {
+	l, exists := ctx.Get("localizer")
+	if !exists {
+		return fallback
+	}
+
+	localizer := l.(*i18n.Localizer)
+	for _, id := range messageIDs {
+		message, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: id, TemplateData: data})
+		if err == nil {
+			return message
+		}
+	}
+	return fallback
+}
+
 func DefaultError(ctx *gin.Context) string {
 	return Localize(ctx, "exception:default-message", nil)
 }
@@ -1 +1,10 @@
 package utils
+
+import "github.com/gin-gonic/gin"
+
+// RequestIdKey - ключ id запроса в контексте.
+const RequestIdKey = "request_id"
+
+func GetRequestId(ctx *gin.Context) string {
+	return ctx.GetString(RequestIdKey)
+}